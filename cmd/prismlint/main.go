@@ -0,0 +1,12 @@
+// Command prismlint checks that Go code uses the Prism logging API correctly.
+package main
+
+import (
+	"github.com/snowmerak/useful-genkit/utils/prism/lint"
+
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(lint.Analyzer)
+}
@@ -0,0 +1,51 @@
+package flows
+
+import (
+	"fmt"
+
+	"github.com/snowmerak/useful-genkit/utils/checkpoint"
+	fileutil "github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/state"
+)
+
+// openCheckpoint opens the manifest of the given run, starting a new run when runID is empty.
+func openCheckpoint(runID, flow string) (string, *checkpoint.Manifest, error) {
+	if runID == "" {
+		runID = state.NewRunID()
+	}
+	manifest, err := checkpoint.Open(runID, flow)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	return runID, manifest, nil
+}
+
+// resumed reports whether the file was already handled by an earlier attempt of the run.
+// Otherwise it remembers the content hash so the result can be checkpointed.
+func (r *FileResult) resumed(manifest *checkpoint.Manifest, content []byte, promptVersion string) bool {
+	hash := fileutil.Hash(content)
+	if manifest.Done(r.File, hash, promptVersion) {
+		return true
+	}
+	r.inputHash = hash
+	r.promptVersion = promptVersion
+	return false
+}
+
+// recordCheckpoint stores a finished file in the manifest. Failed files are not
+// recorded so that they are retried when the run is resumed.
+func recordCheckpoint(manifest *checkpoint.Manifest, res FileResult) error {
+	if res.Status == FileStatusFailed || res.inputHash == "" {
+		return nil
+	}
+	if err := manifest.Record(checkpoint.Entry{
+		File:          res.File,
+		Status:        string(res.Status),
+		InputHash:     res.inputHash,
+		OutputHash:    res.outputHash,
+		PromptVersion: res.promptVersion,
+	}); err != nil {
+		return fmt.Errorf("failed to record checkpoint for %s: %w", res.File, err)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/logic"
+	"github.com/snowmerak/useful-genkit/models"
+	"github.com/snowmerak/useful-genkit/prompts"
+	"github.com/snowmerak/useful-genkit/utils/checkpoint"
+	fileutil "github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/git"
+	"github.com/snowmerak/useful-genkit/utils/gosource"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/language"
+)
+
+type ErrorContextFlowInput struct {
+	WrapGoErrorInput
+	// Languages restricts processing to these languages. All supported languages are processed when empty.
+	// The "chunked" and "deterministic" strategies only apply to Go; other languages use "full" instead.
+	Languages []language.Language `json:"languages,omitempty"`
+	// AllowUnvalidated writes rewrites whose validation cannot run, such as Python files
+	// without python3 on the PATH, instead of failing them. Such files report the skip in
+	// their Validation field.
+	AllowUnvalidated bool `json:"allow_unvalidated,omitempty"`
+}
+
+const ErrorContextFlowName = "ErrorContextFlow"
+
+// errorContextLanguage is how the error context flow handles a language other than Go.
+// The languages the flow supports are listed in the language package; each one but Go
+// has an entry in errorContextLanguages.
+type errorContextLanguage struct {
+	promptName string
+	// library returns the error library the file's project uses, or "" when it has none.
+	library func(base, file string) (string, error)
+	// validate checks a rewritten file before it is written. It returns errValidationSkipped
+	// when the check cannot run.
+	validate func(ctx context.Context, file string, before, after []byte) error
+}
+
+var errorContextLanguages = map[language.Language]errorContextLanguage{
+	language.Rust:       {promptName: prompts.ErrorContextRustPromptName, library: rustErrorLibrary, validate: validateRust},
+	language.Python:     {promptName: prompts.ErrorContextPythonPromptName, validate: validatePython},
+	language.TypeScript: {promptName: prompts.ErrorContextTypeScriptPromptName, validate: validateTypeScript},
+	language.Tsx:        {promptName: prompts.ErrorContextTypeScriptPromptName, validate: validateTypeScript},
+}
+
+// ErrorContextFlow adds context to propagated errors in Go, Rust, Python and TypeScript files,
+// picking the language from the file extension. Go files are handled as in WrapGoErrorFlow.
+func ErrorContextFlow(g *genkit.Genkit) {
+	genkit.DefineFlow(g, ErrorContextFlowName, func(ctx context.Context, input ErrorContextFlowInput) (WrapGoErrorOutput, error) {
+		return runErrorContext(ctx, ErrorContextFlowName, input.WrapGoErrorInput, errorContextProcessor(g, input))
+	})
+}
+
+// errorContextProcessor hands each file to the handling of its language, skipping files
+// that are not source files of a supported and selected language.
+func errorContextProcessor(g *genkit.Genkit, input ErrorContextFlowInput) func(ctx context.Context, manifest *checkpoint.Manifest, file string) FileResult {
+	return func(ctx context.Context, manifest *checkpoint.Manifest, file string) FileResult {
+		fileLang, ok := language.FromSourceExtension(file)
+		if !ok || !language.Supports(fileLang, language.ErrorContextFlow) {
+			return newFileResult(file).finish(FileStatusSkipped, "unsupported language")
+		}
+		if !input.allows(fileLang) {
+			return newFileResult(file).finish(FileStatusSkipped, "language not selected")
+		}
+		if fileLang == language.Go {
+			return wrapGoErrorFile(ctx, g, input.WrapGoErrorInput, manifest, file)
+		}
+		return errorContextFile(ctx, g, input, manifest, file, errorContextLanguages[fileLang])
+	}
+}
+
+func (in ErrorContextFlowInput) allows(lang language.Language) bool {
+	if len(in.Languages) == 0 {
+		return true
+	}
+	// TSX is handled by the TypeScript prompt, so selecting TypeScript selects it too.
+	return slices.Contains(in.Languages, lang) || (lang == language.Tsx && slices.Contains(in.Languages, language.TypeScript))
+}
+
+func errorContextFile(ctx context.Context, g *genkit.Genkit, input ErrorContextFlowInput, manifest *checkpoint.Manifest, file string, lang errorContextLanguage) FileResult {
+	res := newFileResult(file)
+
+	strategy := input.Strategy
+	if strategy == RewriteStrategyChunked || strategy == RewriteStrategyDeterministic {
+		strategy = RewriteStrategyFull
+	}
+
+	contentBytes, err := os.ReadFile(file)
+	if err != nil {
+		return res.fail(fmt.Errorf("failed to read file %s: %w", file, err))
+	}
+	content := string(contentBytes)
+	if res.resumed(manifest, contentBytes, strategy.checkpointVersion(lang.promptName)) {
+		return res.finish(FileStatusSkipped, "already processed in run "+manifest.RunID)
+	}
+	if strings.TrimSpace(content) == "" {
+		return res.finish(FileStatusSkipped, "empty file")
+	}
+
+	var library string
+	if lang.library != nil {
+		library, err = lang.library(input.Path, file)
+		if err != nil {
+			return res.fail(err)
+		}
+		if library == "" {
+			return res.finish(FileStatusSkipped, "no supported error library in the project")
+		}
+	}
+
+	var lines []string
+	if input.ChangedHunksOnly {
+		hunks, err := git.ChangedHunks(ctx, input.Path, input.BaseRef, file)
+		if err != nil {
+			return res.fail(fmt.Errorf("failed to get changed hunks of %s: %w", file, err))
+		}
+		if len(hunks) == 0 {
+			return res.finish(FileStatusSkipped, "no changed lines")
+		}
+		for _, h := range hunks {
+			lines = append(lines, fmt.Sprintf("%d-%d", h.Start, h.End))
+		}
+	}
+
+	model, err := models.GetOllamaDevstralSmall2(g)
+	if err != nil {
+		return res.fail(fmt.Errorf("failed to get model: %w", err))
+	}
+	res.Model = model.Name()
+
+	promptInput := prompts.ErrorContextInput{
+		Code:     content,
+		BasePath: input.Path,
+		FilePath: file,
+		Library:  library,
+		Lines:    strings.Join(lines, ", "),
+	}
+	newCode, err := errorContextGenerate(ctx, g, res, model, strategy, lang.promptName, promptInput)
+	if err != nil {
+		return res.fail(err)
+	}
+
+	if newCode == "" {
+		return res.fail(fmt.Errorf("model returned empty code for %s", file))
+	}
+	if newCode == strings.TrimSpace(content) || newCode == content {
+		return res.finish(FileStatusUnchanged, "model returned the code unchanged")
+	}
+	switch err := lang.validate(ctx, file, contentBytes, []byte(newCode)); {
+	case errors.Is(err, errValidationSkipped) && input.AllowUnvalidated:
+		res.Validation = err.Error()
+	case errors.Is(err, errValidationSkipped):
+		return res.fail(fmt.Errorf("refusing to write unvalidated rewrite of %s: %w; set allow_unvalidated to write it anyway", file, err))
+	case err != nil:
+		return res.fail(fmt.Errorf("rejected rewrite of %s: %w", file, err))
+	}
+
+	if err := journal.WriteFile(ctx, file, []byte(newCode), 0644); err != nil {
+		return res.fail(fmt.Errorf("failed to write file %s: %w", file, err))
+	}
+	res.outputHash = fileutil.Hash([]byte(newCode))
+
+	return res.finish(FileStatusModified, "")
+}
+
+// errorContextGenerate renders the language's prompt for the strategy and returns the rewritten code.
+func errorContextGenerate(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, strategy RewriteStrategy, promptName string, input prompts.ErrorContextInput) (string, error) {
+	prompt := genkit.LookupPrompt(g, promptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", promptName)
+	}
+
+	content := input.Code
+	switch strategy {
+	case RewriteStrategyLineEdits:
+		input.Code = string(fileutil.AttachLineNumbers([]byte(content)))
+		input.LineEdits = true
+	case RewriteStrategySearchReplace:
+		input.SearchReplace = true
+	}
+
+	req, err := prompt.Render(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	switch strategy {
+	case RewriteStrategyLineEdits:
+		result, usage, err := logic.GenerateDataWithToolUsage[prompts.CodeEditsOutput](ctx, g, ai.WithTools(codeSearchTools(g)...), req.Messages, ai.WithModel(model))
+		res.addUsage(usage)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate edits for %s: %w", input.FilePath, err)
+		}
+		newCode, err := applyLineEdits([]byte(content), result)
+		if err != nil {
+			return "", fmt.Errorf("failed to apply edits to %s: %w", input.FilePath, err)
+		}
+		return newCode, nil
+	case RewriteStrategySearchReplace:
+		result, usage, err := logic.GenerateDataWithToolUsage[prompts.SearchReplaceOutput](ctx, g, ai.WithTools(codeSearchTools(g)...), req.Messages, ai.WithModel(model))
+		res.addUsage(usage)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate blocks for %s: %w", input.FilePath, err)
+		}
+		newCode, err := applySearchReplace(res, content, result)
+		if err != nil {
+			return "", fmt.Errorf("failed to apply blocks to %s: %w", input.FilePath, err)
+		}
+		return newCode, nil
+	default:
+		result, usage, err := logic.GenerateDataWithToolUsage[prompts.ErrorContextOutput](ctx, g, ai.WithTools(codeSearchTools(g)...), req.Messages, ai.WithModel(model))
+		res.addUsage(usage)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate code for %s: %w", input.FilePath, err)
+		}
+		newCode := gosource.StripCodeFence(result.Code)
+		if newCode != "" && strings.HasSuffix(content, "\n") {
+			newCode += "\n"
+		}
+		return newCode, nil
+	}
+}
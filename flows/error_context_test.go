@@ -0,0 +1,50 @@
+package flows
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/useful-genkit/utils/language"
+)
+
+func TestErrorContextLanguages(t *testing.T) {
+	for _, lang := range language.Supported(language.ErrorContextFlow) {
+		if _, ok := errorContextLanguages[lang]; !ok && lang != language.Go {
+			t.Errorf("%s is supported but has no error context handling", lang)
+		}
+	}
+	for lang := range errorContextLanguages {
+		if !language.Supports(lang, language.ErrorContextFlow) {
+			t.Errorf("%s has error context handling but is not listed as supported", lang)
+		}
+	}
+}
+
+func TestErrorContextSkipsNonSourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	process := errorContextProcessor(nil, ErrorContextFlowInput{Languages: []language.Language{language.Python}})
+	tests := []struct {
+		file   string
+		reason string
+	}{
+		{"go.mod", "unsupported language"},
+		{"go.sum", "unsupported language"},
+		{"BUILD", "unsupported language"},
+		{"BUILD.bazel", "unsupported language"},
+		{"defs.bzl", "unsupported language"},
+		{"types.pyi", "unsupported language"},
+		{"index.d.ts", "unsupported language"},
+		{"main.c", "unsupported language"},
+		{"main.go", "language not selected"},
+		{"lib.rs", "language not selected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			res := process(context.Background(), nil, filepath.Join(dir, tt.file))
+			if res.Status != FileStatusSkipped || res.Reason != tt.reason {
+				t.Errorf("result = %s %q, want skipped %q", res.Status, res.Reason, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package flows
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	cargoErrorLibrary = regexp.MustCompile(`(?m)^\s*(?:\[(?:workspace\.)?dependencies\.)?(anyhow|eyre|color-eyre)\s*[=\]]`)
+	rustContextCall   = regexp.MustCompile(`\.(?:with_context|context|wrap_err|wrap_err_with)\(`)
+	rustContextTrait  = regexp.MustCompile(`\b(?:anyhow|eyre)::(?:\{[^}]*\b(?:Context|WrapErr|ContextCompat)\b[^}]*\}|Context|WrapErr|ContextCompat|\*)`)
+	tsThrow           = regexp.MustCompile(`\bthrow\b`)
+	tsCatch           = regexp.MustCompile(`\bcatch\b`)
+)
+
+// rustErrorLibrary finds anyhow or eyre in the nearest Cargo.toml that declares either,
+// looking from the file's directory up to base.
+func rustErrorLibrary(base, file string) (string, error) {
+	root, err := filepath.Abs(base)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", base, err)
+	}
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+
+	for {
+		manifest, err := os.ReadFile(filepath.Join(dir, "Cargo.toml"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to read Cargo.toml in %s: %w", dir, err)
+		}
+		if m := cargoErrorLibrary.FindSubmatch(manifest); m != nil {
+			if string(m[1]) == "anyhow" {
+				return "anyhow", nil
+			}
+			return "eyre", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir || !strings.HasPrefix(parent, root) {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// validateRust checks that the rewrite keeps every "?", keeps brackets balanced and
+// imports the extension trait its context calls need.
+func validateRust(_ context.Context, _ string, before, after []byte) error {
+	if n, m := bytes.Count(before, []byte("?")), bytes.Count(after, []byte("?")); m < n {
+		return fmt.Errorf("%d \"?\" operators removed", n-m)
+	}
+	if err := checkBrackets(before, after, `"`); err != nil {
+		return err
+	}
+	if rustContextCall.Match(after) && !rustContextCall.Match(before) && !rustContextTrait.Match(after) {
+		return fmt.Errorf("context is added without importing anyhow::Context or eyre::WrapErr")
+	}
+	return nil
+}
+
+// validateTypeScript checks that the rewrite keeps every throw and catch and keeps
+// brackets balanced.
+func validateTypeScript(_ context.Context, _ string, before, after []byte) error {
+	keywords := []struct {
+		name string
+		re   *regexp.Regexp
+	}{{"throw", tsThrow}, {"catch", tsCatch}}
+	for _, kw := range keywords {
+		if n, m := len(kw.re.FindAll(before, -1)), len(kw.re.FindAll(after, -1)); m < n {
+			return fmt.Errorf("%d %q statements removed", n-m, kw.name)
+		}
+	}
+	return checkBrackets(before, after, "\"'`")
+}
+
+// pythonCheck parses both versions and reports the functions and classes the rewrite removed.
+const pythonCheck = `import ast, json, sys
+src = json.load(sys.stdin)
+def names(code, filename):
+    return {n.name for n in ast.walk(ast.parse(code, filename)) if isinstance(n, (ast.FunctionDef, ast.AsyncFunctionDef, ast.ClassDef))}
+before = names(src["before"], sys.argv[1])
+after = names(src["after"], sys.argv[1])
+missing = sorted(before - after)
+if missing:
+    sys.exit("removed: " + ", ".join(missing))
+`
+
+// errValidationSkipped is returned by a validator that cannot run in this environment.
+var errValidationSkipped = errors.New("validation skipped")
+
+// validatePython checks that the rewrite parses and keeps every function and class.
+// It needs python3 on the PATH and returns errValidationSkipped without it.
+func validatePython(ctx context.Context, file string, before, after []byte) error {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		return fmt.Errorf("%w: python3 not found", errValidationSkipped)
+	}
+
+	input, err := json.Marshal(map[string]string{"before": string(before), "after": string(after)})
+	if err != nil {
+		return fmt.Errorf("failed to encode python check input: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, python, "-c", pythonCheck, file)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		return fmt.Errorf("python check failed: %s", lines[len(lines)-1])
+	}
+	return nil
+}
+
+// checkBrackets rejects a rewrite that unbalances brackets, unless the original was
+// already unbalanced by this scanner's reckoning.
+func checkBrackets(before, after []byte, quotes string) error {
+	if bracketsBalanced(before, quotes) && !bracketsBalanced(after, quotes) {
+		return fmt.Errorf("brackets are not balanced")
+	}
+	return nil
+}
+
+// bracketsBalanced scans C-like source, skipping comments and the given string quotes.
+func bracketsBalanced(src []byte, quotes string) bool {
+	pairs := map[byte]byte{')': '(', ']': '[', '}': '{'}
+	var stack []byte
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return false
+			}
+			i += end + 3
+		case strings.IndexByte(quotes, c) >= 0:
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+		case c == '(' || c == '[' || c == '{':
+			stack = append(stack, c)
+		case c == ')' || c == ']' || c == '}':
+			if len(stack) == 0 || stack[len(stack)-1] != pairs[c] {
+				return false
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return len(stack) == 0
+}
@@ -0,0 +1,145 @@
+package flows
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/snowmerak/useful-genkit/utils/goguard"
+	"github.com/snowmerak/useful-genkit/utils/patch"
+	"github.com/snowmerak/useful-genkit/utils/prism/lint"
+)
+
+// FileStatus describes what happened to a single file during a code flow run.
+type FileStatus string
+
+const (
+	FileStatusModified  FileStatus = "modified"
+	FileStatusUnchanged FileStatus = "unchanged"
+	FileStatusSkipped   FileStatus = "skipped"
+	FileStatusFailed    FileStatus = "failed"
+)
+
+// RunStatus describes the overall outcome of a code flow run.
+type RunStatus string
+
+const (
+	RunStatusSucceeded RunStatus = "succeeded"
+	RunStatusPartial   RunStatus = "partial"
+	RunStatusFailed    RunStatus = "failed"
+)
+
+// FileResult is the per-file entry of a code flow report.
+type FileResult struct {
+	File         string     `json:"file"`
+	Status       FileStatus `json:"status"`
+	Reason       string     `json:"reason,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	InputTokens  int        `json:"input_tokens"`
+	OutputTokens int        `json:"output_tokens"`
+	DurationMs   int64      `json:"duration_ms"`
+	// Patches reports how each search/replace block was matched, with its confidence.
+	Patches []patch.Result `json:"patches,omitempty"`
+	// Violations explains why a rewrite was rejected for changing more than the flow allows.
+	Violations goguard.Violations `json:"violations,omitempty"`
+	// Lint lists the findings of the verification step run after the rewrite, if any.
+	Lint []lint.Diagnostic `json:"lint,omitempty"`
+	// Validation explains why a rewrite was written without being validated.
+	Validation string `json:"validation,omitempty"`
+
+	start         time.Time
+	err           error
+	inputHash     string
+	outputHash    string
+	promptVersion string
+}
+
+func newFileResult(file string) *FileResult {
+	return &FileResult{
+		File:  file,
+		start: time.Now(),
+	}
+}
+
+func (r *FileResult) addUsage(usage *ai.GenerationUsage) {
+	if usage == nil {
+		return
+	}
+	r.InputTokens += usage.InputTokens
+	r.OutputTokens += usage.OutputTokens
+}
+
+func (r *FileResult) finish(status FileStatus, reason string) FileResult {
+	r.Status = status
+	r.Reason = reason
+	r.DurationMs = time.Since(r.start).Milliseconds()
+	return *r
+}
+
+func (r *FileResult) fail(err error) FileResult {
+	r.err = err
+	return r.finish(FileStatusFailed, err.Error())
+}
+
+// RunSummary aggregates the per-file results of a code flow run.
+type RunSummary struct {
+	Status       RunStatus `json:"status"`
+	Total        int       `json:"total"`
+	Modified     int       `json:"modified"`
+	Unchanged    int       `json:"unchanged"`
+	Skipped      int       `json:"skipped"`
+	Failed       int       `json:"failed"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	DurationMs   int64     `json:"duration_ms"`
+}
+
+func summarize(results []FileResult) RunSummary {
+	summary := RunSummary{Total: len(results)}
+	for _, r := range results {
+		switch r.Status {
+		case FileStatusModified:
+			summary.Modified++
+		case FileStatusUnchanged:
+			summary.Unchanged++
+		case FileStatusSkipped:
+			summary.Skipped++
+		case FileStatusFailed:
+			summary.Failed++
+		}
+		summary.InputTokens += r.InputTokens
+		summary.OutputTokens += r.OutputTokens
+		summary.DurationMs += r.DurationMs
+	}
+
+	switch {
+	case summary.Failed == 0:
+		summary.Status = RunStatusSucceeded
+	case summary.Modified+summary.Unchanged > 0:
+		summary.Status = RunStatusPartial
+	default:
+		summary.Status = RunStatusFailed
+	}
+	return summary
+}
+
+// modifiedFiles returns the paths of the files that were rewritten.
+func modifiedFiles(results []FileResult) []string {
+	var files []string
+	for _, r := range results {
+		if r.Status == FileStatusModified {
+			files = append(files, r.File)
+		}
+	}
+	return files
+}
+
+// abortError is returned by a flow when a file fails and ContinueOnError is not set.
+// It names the run so that the caller can resume it.
+func abortError(runID string, r FileResult) error {
+	err := r.err
+	if err == nil {
+		err = fmt.Errorf("failed to process %s: %s", r.File, r.Reason)
+	}
+	return fmt.Errorf("run %s aborted: %w", runID, err)
+}
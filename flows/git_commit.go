@@ -0,0 +1,148 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/git"
+)
+
+// CommitGrouping selects how rewritten files are split into commits.
+type CommitGrouping string
+
+const (
+	CommitPerFile    CommitGrouping = "file"
+	CommitPerPackage CommitGrouping = "package"
+)
+
+// GitCommitOptions makes a code flow work on a new branch and commit its rewrites.
+type GitCommitOptions struct {
+	// Branch is the branch to create. Defaults to "<flow>/<run id>".
+	Branch string `json:"branch,omitempty"`
+	// Per is either "file" (default) or "package".
+	Per CommitGrouping `json:"per,omitempty"`
+}
+
+// GitCommitResult describes the branch and commits created by a code flow.
+type GitCommitResult struct {
+	Branch  string   `json:"branch"`
+	Commits []string `json:"commits"`
+}
+
+// startGitBranch refuses a dirty working tree and switches to a new branch for the run.
+// A resumed run switches back to its branch when it already exists.
+func startGitBranch(ctx context.Context, path, flow, runID string, resume bool, opts *GitCommitOptions) (string, error) {
+	switch opts.Per {
+	case "", CommitPerFile, CommitPerPackage:
+	default:
+		return "", fmt.Errorf("unknown commit grouping %q", opts.Per)
+	}
+
+	clean, err := git.IsClean(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if !clean {
+		return "", fmt.Errorf("refusing to start %s: working tree at %s has uncommitted changes", flow, path)
+	}
+
+	branch := opts.Branch
+	if branch == "" {
+		branch = flow + "/" + runID
+	}
+	if resume {
+		exists, err := git.BranchExists(ctx, path, branch)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			if err := git.Checkout(ctx, path, branch); err != nil {
+				return "", err
+			}
+			return branch, nil
+		}
+	}
+	if err := git.CreateBranch(ctx, path, branch); err != nil {
+		return "", err
+	}
+	return branch, nil
+}
+
+// commitResults commits the modified files one file or one package at a time, followed by
+// a final commit for anything else the run changed (for example files written by tools).
+func commitResults(ctx context.Context, path, flow, action string, opts *GitCommitOptions, runID string, results []FileResult) ([]string, error) {
+	groups := make(map[string][]FileResult)
+	var keys []string
+	for _, r := range results {
+		if r.Status != FileStatusModified {
+			continue
+		}
+		key := r.File
+		if opts.Per == CommitPerPackage {
+			key = filepath.Dir(r.File)
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+	slices.Sort(keys)
+
+	var commits []string
+	for _, key := range keys {
+		group := groups[key]
+
+		var files, models []string
+		for _, r := range group {
+			files = append(files, repoPath(path, r.File))
+			if r.Model != "" && !slices.Contains(models, r.Model) {
+				models = append(models, r.Model)
+			}
+		}
+
+		target := repoPath(path, key)
+		message := fmt.Sprintf("%s: %s in %s\n\nModel: %s\nRun: %s\n", flow, action, filepath.ToSlash(target), strings.Join(models, ", "), runID)
+
+		hash, err := git.Commit(ctx, path, message, files...)
+		if err != nil {
+			return commits, fmt.Errorf("failed to commit %s: %w", target, err)
+		}
+		commits = append(commits, hash)
+	}
+
+	dirty, err := git.HasChanges(ctx, path)
+	if err != nil {
+		return commits, err
+	}
+	if dirty {
+		message := fmt.Sprintf("%s: add supporting files\n\nRun: %s\n", flow, runID)
+		hash, err := git.Commit(ctx, path, message)
+		if err != nil {
+			return commits, fmt.Errorf("failed to commit supporting files: %w", err)
+		}
+		commits = append(commits, hash)
+	}
+
+	return commits, nil
+}
+
+// repoPath returns file relative to dir, the directory git runs in. File paths in results
+// are relative to the process working directory, which need not be dir.
+func repoPath(dir, file string) string {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return file
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil {
+		return absFile
+	}
+	return rel
+}
@@ -0,0 +1,78 @@
+package flows
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/useful-genkit/utils/git"
+)
+
+// initRepo makes dir a git repository with one commit of everything in it.
+func initRepo(t *testing.T, dir string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found on PATH")
+	}
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(env, "test")
+	}
+	for _, env := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(env, "test@example.com")
+	}
+	ctx := context.Background()
+	for _, args := range [][]string{{"init", "-q", "-b", "main"}, {"add", "-A"}, {"commit", "-q", "-m", "initial"}} {
+		if _, err := git.Run(ctx, dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunErrorContextResumesOnItsBranch(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, "a.go", "b.go", "c.go")
+	initRepo(t, dir)
+	ctx := context.Background()
+
+	input := WrapGoErrorInput{Path: dir, Commit: &GitCommitOptions{}}
+	out, err := runErrorContext(ctx, "TestFlow", input, fakeProcessor(map[string]bool{"b.go": true}))
+	if err == nil {
+		t.Fatal("run did not abort")
+	}
+	if out.Git == nil || len(out.Git.Commits) != 1 {
+		t.Fatalf("aborted run git result = %+v, want one commit", out.Git)
+	}
+	branch := out.Git.Branch
+
+	input.RunID = out.RunID
+	out, err = runErrorContext(ctx, "TestFlow", input, fakeProcessor(nil))
+	if err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	if out.Git == nil || out.Git.Branch != branch || len(out.Git.Commits) != 2 {
+		t.Fatalf("resumed run git result = %+v, want two commits on %s", out.Git, branch)
+	}
+
+	current, err := git.Run(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(current) != branch {
+		t.Errorf("on branch %s, want %s", strings.TrimSpace(current), branch)
+	}
+	count, err := git.Run(ctx, dir, "rev-list", "--count", "main.."+branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(count) != "3" {
+		t.Errorf("branch has %s commits over main, want 3", strings.TrimSpace(count))
+	}
+
+	// A new run must not reuse another run's branch name by accident.
+	input.RunID = ""
+	input.Commit = &GitCommitOptions{Branch: branch}
+	if _, err := runErrorContext(ctx, "TestFlow", input, fakeProcessor(nil)); err == nil {
+		t.Error("a new run switched to an existing branch")
+	}
+}
@@ -0,0 +1,73 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+
+	"github.com/snowmerak/useful-genkit/utils/git"
+	"github.com/snowmerak/useful-genkit/utils/gosource"
+)
+
+// filterChangedFiles keeps only the files changed since the merge base of baseRef.
+func filterChangedFiles(ctx context.Context, path, baseRef string, files []string) ([]string, error) {
+	changed, err := git.ChangedFiles(ctx, path, baseRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed since %s: %w", baseRef, err)
+	}
+
+	set := make(map[string]struct{}, len(changed))
+	for _, f := range changed {
+		set[f] = struct{}{}
+	}
+
+	var filtered []string
+	for _, f := range files {
+		// git reports paths under the symlink-free repository root.
+		resolved, err := filepath.EvalSymlinks(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", f, err)
+		}
+		abs, err := filepath.Abs(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", f, err)
+		}
+		if _, ok := set[abs]; ok {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered, nil
+}
+
+// changedFunctions returns the names of the function declarations overlapping the hunks.
+// Methods are named as Receiver.Method.
+func changedFunctions(file string, src []byte, hunks []git.Hunk) ([]string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	var names []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		start := fset.Position(fn.Pos()).Line
+		if fn.Doc != nil {
+			start = fset.Position(fn.Doc.Pos()).Line
+		}
+		end := fset.Position(fn.End()).Line
+		for _, h := range hunks {
+			if h.Overlaps(start, end) {
+				names = append(names, gosource.FuncName(fn))
+				break
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,40 @@
+package flows
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/snowmerak/useful-genkit/utils/goguard"
+)
+
+// wrapGoErrorPolicy only lets error wrapping add the imports it needs.
+var wrapGoErrorPolicy = goguard.Policy{
+	AllowedImports: []string{"fmt", "errors"},
+}
+
+// logPrismPolicy lets instrumentation add imports and helpers, thread a context through
+// unexported functions and add fields to types, but not change the exported API.
+var logPrismPolicy = goguard.Policy{
+	AllowAnyImport:                  true,
+	AllowNewDecls:                   true,
+	AllowUnexportedSignatureChanges: true,
+	AllowTypeChanges:                true,
+}
+
+// guardRewrite rejects a rewritten Go file that changes more than the policy allows.
+// Other files are not checked.
+func guardRewrite(res *FileResult, before, after []byte, policy goguard.Policy) error {
+	if filepath.Ext(res.File) != ".go" {
+		return nil
+	}
+
+	violations, err := goguard.Check(res.File, before, after, policy)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		res.Violations = violations
+		return fmt.Errorf("rejected rewrite of %s: %s", res.File, violations)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
@@ -12,26 +13,56 @@ import (
 	"github.com/snowmerak/useful-genkit/models"
 	"github.com/snowmerak/useful-genkit/prompts"
 	"github.com/snowmerak/useful-genkit/tools"
+	"github.com/snowmerak/useful-genkit/utils/checkpoint"
+	fileutil "github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/language"
+	"github.com/snowmerak/useful-genkit/utils/loglib"
+	"github.com/snowmerak/useful-genkit/utils/prism"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
 )
 
 type LogPrismFlowInput struct {
 	Path string `json:"path"`
+	// ContinueOnError keeps processing the remaining files when one of them fails.
+	ContinueOnError bool `json:"continue_on_error,omitempty"`
+	// RunID resumes an earlier run, skipping files it already handled and that are unchanged since.
+	// A new run ID is generated when empty.
+	RunID string `json:"run_id,omitempty"`
+	// Commit creates a working branch before the run and commits the rewritten files.
+	Commit *GitCommitOptions `json:"commit,omitempty"`
+	// Strategy selects how code is exchanged with the model: "full" (default), "line_edits"
+	// or "search_replace".
+	Strategy RewriteStrategy `json:"strategy,omitempty"`
+	// Workspace adds read-only and deny rules to the file tools, which are always confined to Path.
+	Workspace workspace.Options `json:"workspace,omitempty"`
+	// Verify runs the Prism linter on the rewritten Go files and reports its findings per file.
+	Verify bool `json:"verify,omitempty"`
 }
 
 type LogPrismFlowOutput struct {
-	ProcessedFiles []string `json:"processed_files"`
+	RunID          string           `json:"run_id"`
+	ProcessedFiles []string         `json:"processed_files"`
+	Files          []FileResult     `json:"files"`
+	Summary        RunSummary       `json:"summary"`
+	Git            *GitCommitResult `json:"git,omitempty"`
+	// Scaffolds reports the Prism logging package generated for each Go module.
+	Scaffolds []PrismScaffold `json:"scaffolds,omitempty"`
 }
 
-func LogPrismFlow(g *genkit.Genkit) {
-	genkit.DefineFlow(g, "LogPrismFlow", func(ctx context.Context, input LogPrismFlowInput) (LogPrismFlowOutput, error) {
-		var processedFiles []string
+const LogPrismFlowName = "LogPrismFlow"
 
+func LogPrismFlow(g *genkit.Genkit) {
+	genkit.DefineFlow(g, LogPrismFlowName, func(ctx context.Context, input LogPrismFlowInput) (LogPrismFlowOutput, error) {
 		// 1. List all files in the directory recursively
 		var files []string
 		err := filepath.Walk(input.Path, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
+			if info.IsDir() && info.Name() == ".git" {
+				return filepath.SkipDir
+			}
 			if !info.IsDir() {
 				files = append(files, path)
 			}
@@ -41,103 +72,322 @@ func LogPrismFlow(g *genkit.Genkit) {
 			return LogPrismFlowOutput{}, fmt.Errorf("failed to walk directory: %w", err)
 		}
 
-		// 2. Process each Go file
-		for _, file := range files {
-			// Read file content
-			contentBytes, err := os.ReadFile(file)
-			if err != nil {
-				return LogPrismFlowOutput{}, fmt.Errorf("failed to read file %s: %w", file, err)
-			}
-			content := string(contentBytes)
+		if err := input.Strategy.validate(LogPrismFlowName, RewriteStrategyFull, RewriteStrategyLineEdits, RewriteStrategySearchReplace); err != nil {
+			return LogPrismFlowOutput{}, err
+		}
 
-			// Generate modified code
-			prompt := genkit.LookupPrompt(g, prompts.LogPrismPromptName)
-			if prompt == nil {
-				return LogPrismFlowOutput{}, fmt.Errorf("prompt %s not found", prompts.LogPrismPromptName)
-			}
+		runID, manifest, err := openCheckpoint(input.RunID, LogPrismFlowName)
+		if err != nil {
+			return LogPrismFlowOutput{}, err
+		}
 
-			// Use a model to generate the response
-			model, err := models.GetOpenRouterQwen3Coder(g)
-			if err != nil {
-				return LogPrismFlowOutput{}, fmt.Errorf("failed to get model: %w", err)
-			}
+		ctx, err = withJournal(ctx, runID)
+		if err != nil {
+			return LogPrismFlowOutput{}, err
+		}
+
+		ctx, err = withWorkspace(ctx, input.Path, input.Workspace)
+		if err != nil {
+			return LogPrismFlowOutput{}, err
+		}
 
-			req, err := prompt.Render(ctx, prompts.LogPrismInput{
-				Code:     content,
-				BasePath: input.Path,
-				FilePath: file,
-			})
+		var branch string
+		if input.Commit != nil {
+			branch, err = startGitBranch(ctx, input.Path, LogPrismFlowName, runID, input.RunID != "", input.Commit)
 			if err != nil {
-				return LogPrismFlowOutput{}, fmt.Errorf("failed to render prompt: %w", err)
+				return LogPrismFlowOutput{}, err
 			}
+		}
 
-			// Add tools to the request (optional, but good for context if needed)
-			findDefTool := genkit.LookupTool(g, tools.FindDefinitionTool)
-			findUsageTool := genkit.LookupTool(g, tools.FindUsageTool)
-			findStructsTool := genkit.LookupTool(g, tools.FindStructsTool)
-			readFileTool := genkit.LookupTool(g, tools.ReadFileTool)
-			writeFileTool := genkit.LookupTool(g, tools.WriteFileTool)
-			listFilesTool := genkit.LookupTool(g, tools.ListFilesTool)
-			createDirectoryTool := genkit.LookupTool(g, tools.CreateDirectoryTool)
-			deleteDirectoryTool := genkit.LookupTool(g, tools.DeleteDirectoryTool)
-			walkDirectoryTool := genkit.LookupTool(g, tools.WalkDirectoryTool)
-
-			var toolRefs []ai.ToolRef
-			if findDefTool != nil {
-				toolRefs = append(toolRefs, findDefTool)
-			}
-			if findUsageTool != nil {
-				toolRefs = append(toolRefs, findUsageTool)
-			}
-			if findStructsTool != nil {
-				toolRefs = append(toolRefs, findStructsTool)
-			}
-			if readFileTool != nil {
-				toolRefs = append(toolRefs, readFileTool)
-			}
-			if writeFileTool != nil {
-				toolRefs = append(toolRefs, writeFileTool)
-			}
-			if listFilesTool != nil {
-				toolRefs = append(toolRefs, listFilesTool)
-			}
-			if createDirectoryTool != nil {
-				toolRefs = append(toolRefs, createDirectoryTool)
-			}
-			if deleteDirectoryTool != nil {
-				toolRefs = append(toolRefs, deleteDirectoryTool)
+		// 2. Generate the Prism logging package of each Go module from templates
+		apis, scaffolds, err := scaffoldPrism(ctx, input.Path, files)
+		if err != nil {
+			return LogPrismFlowOutput{}, err
+		}
+
+		// 3. Process each file
+		var results []FileResult
+		var abort error
+		for _, file := range files {
+			res := logPrismFile(ctx, g, input, manifest, file, apis[file])
+			if err := recordCheckpoint(manifest, res); err != nil {
+				return LogPrismFlowOutput{RunID: runID}, err
 			}
-			if walkDirectoryTool != nil {
-				toolRefs = append(toolRefs, walkDirectoryTool)
+			results = append(results, res)
+			if res.Status == FileStatusFailed && !input.ContinueOnError {
+				abort = abortError(runID, res)
+				break
 			}
+		}
 
-			result, err := logic.GenerateDataWithTool[prompts.LogPrismOutput](
-				ctx,
-				g,
-				ai.WithTools(toolRefs...),
-				req.Messages,
-				ai.WithModel(model),
-			)
-			if err != nil {
-				// Log error but continue processing other files? Or fail?
-				// For now, let's log and continue or return error.
-				// wrap_go_error.go seems to return error inside the loop in my reading, but let's check logic.GenerateDataWithTool usage.
-				// It returns result and error.
-				return LogPrismFlowOutput{}, fmt.Errorf("failed to generate code for %s: %w", file, err)
+		// 4. Check the instrumentation of the rewritten Go files
+		if input.Verify {
+			if err := verifyPrism(input.Path, results); err != nil {
+				return LogPrismFlowOutput{}, err
 			}
+		}
+
+		output := LogPrismFlowOutput{
+			RunID:          runID,
+			ProcessedFiles: modifiedFiles(results),
+			Files:          results,
+			Summary:        summarize(results),
+			Scaffolds:      scaffolds,
+		}
 
-			// Write back to file
-			if result.Code != "" && result.Code != content {
-				err = os.WriteFile(file, []byte(result.Code), 0644)
-				if err != nil {
-					return LogPrismFlowOutput{}, fmt.Errorf("failed to write file %s: %w", file, err)
-				}
-				processedFiles = append(processedFiles, file)
+		// Commit what was rewritten even when the run aborts, so the work is kept on the branch.
+		if input.Commit != nil {
+			commits, err := commitResults(ctx, input.Path, LogPrismFlowName, "add Prism logging", input.Commit, runID, results)
+			if err != nil {
+				return output, fmt.Errorf("run %s: %w", runID, err)
 			}
+			output.Git = &GitCommitResult{Branch: branch, Commits: commits}
 		}
 
-		return LogPrismFlowOutput{
-			ProcessedFiles: processedFiles,
-		}, nil
+		// The output carries the run ID and the results so far, for resuming the run.
+		if abort != nil {
+			return output, abort
+		}
+		return output, nil
 	})
 }
+
+// logPrismPrompts are the Prism prompts of the languages the flow supports, as listed in
+// the language package.
+var logPrismPrompts = map[language.Language]string{
+	language.Go:         prompts.LogPrismGoPromptName,
+	language.Python:     prompts.LogPrismPythonPromptName,
+	language.TypeScript: prompts.LogPrismTypeScriptPromptName,
+	language.Tsx:        prompts.LogPrismTypeScriptPromptName,
+	language.JavaScript: prompts.LogPrismTypeScriptPromptName,
+	language.Rust:       prompts.LogPrismRustPromptName,
+}
+
+// logPrismFile instruments a single file. prismAPI describes the generated Prism package
+// of the file's module, if there is one.
+func logPrismFile(ctx context.Context, g *genkit.Genkit, input LogPrismFlowInput, manifest *checkpoint.Manifest, file, prismAPI string) FileResult {
+	res := newFileResult(file)
+
+	lang, ok := language.FromSourceExtension(file)
+	if !ok || !language.Supports(lang, language.LogPrismFlow) {
+		return res.finish(FileStatusSkipped, "unsupported language")
+	}
+
+	promptName := logPrismPrompts[lang]
+
+	// Read file content
+	contentBytes, err := os.ReadFile(file)
+	if err != nil {
+		return res.fail(fmt.Errorf("failed to read file %s: %w", file, err))
+	}
+	content := string(contentBytes)
+	if res.resumed(manifest, contentBytes, input.Strategy.checkpointVersion(promptName)) {
+		return res.finish(FileStatusSkipped, "already processed in run "+manifest.RunID)
+	}
+	if strings.TrimSpace(content) == "" {
+		return res.finish(FileStatusSkipped, "empty file")
+	}
+	if _, ok := prism.IsGenerated(contentBytes); ok {
+		return res.finish(FileStatusSkipped, "generated Prism package")
+	}
+
+	library, err := loglib.Detect(input.Path, file, lang)
+	if err != nil {
+		return res.fail(fmt.Errorf("failed to detect logging library for %s: %w", file, err))
+	}
+
+	// Use a model to generate the response
+	model, err := models.GetOpenRouterQwen3Coder(g)
+	if err != nil {
+		return res.fail(fmt.Errorf("failed to get model: %w", err))
+	}
+	res.Model = model.Name()
+
+	promptInput := prompts.LogPrismInput{
+		Code:            content,
+		BasePath:        input.Path,
+		FilePath:        file,
+		Library:         library.Name,
+		LibraryDetected: library.Detected,
+		PrismAPI:        prismAPI,
+	}
+
+	var newCode string
+	switch input.Strategy {
+	case RewriteStrategyLineEdits:
+		newCode, err = logPrismLineEdits(ctx, g, res, model, promptName, promptInput)
+	case RewriteStrategySearchReplace:
+		newCode, err = logPrismSearchReplace(ctx, g, res, model, promptName, promptInput)
+	default:
+		newCode, err = logPrismFullFile(ctx, g, res, model, promptName, promptInput)
+	}
+	if err != nil {
+		return res.fail(err)
+	}
+
+	if newCode == "" || newCode == content {
+		return res.finish(FileStatusUnchanged, "model returned no changes")
+	}
+	if err := guardRewrite(res, contentBytes, []byte(newCode), logPrismPolicy); err != nil {
+		return res.fail(err)
+	}
+
+	// Write back to file
+	if err := journal.WriteFile(ctx, file, []byte(newCode), 0644); err != nil {
+		return res.fail(fmt.Errorf("failed to write file %s: %w", file, err))
+	}
+	res.outputHash = fileutil.Hash([]byte(newCode))
+
+	return res.finish(FileStatusModified, "")
+}
+
+// logPrismFullFile sends the whole file and returns the whole instrumented file.
+func logPrismFullFile(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, promptName string, input prompts.LogPrismInput) (string, error) {
+	prompt := genkit.LookupPrompt(g, promptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", promptName)
+	}
+
+	req, err := prompt.Render(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	result, usage, err := logic.GenerateDataWithToolUsage[prompts.LogPrismOutput](
+		ctx,
+		g,
+		ai.WithTools(logPrismTools(g)...),
+		req.Messages,
+		ai.WithModel(model),
+	)
+	res.addUsage(usage)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code for %s: %w", input.FilePath, err)
+	}
+	return result.Code, nil
+}
+
+// logPrismLineEdits sends a line-numbered file and applies the returned edit operations.
+func logPrismLineEdits(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, promptName string, input prompts.LogPrismInput) (string, error) {
+	prompt := genkit.LookupPrompt(g, promptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", promptName)
+	}
+
+	content := []byte(input.Code)
+	input.Code = string(fileutil.AttachLineNumbers(content))
+	input.LineEdits = true
+	req, err := prompt.Render(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	result, usage, err := logic.GenerateDataWithToolUsage[prompts.CodeEditsOutput](
+		ctx,
+		g,
+		ai.WithTools(logPrismTools(g)...),
+		req.Messages,
+		ai.WithModel(model),
+	)
+	res.addUsage(usage)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate edits for %s: %w", input.FilePath, err)
+	}
+
+	newCode, err := applyLineEdits(content, result)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply edits to %s: %w", input.FilePath, err)
+	}
+	return newCode, nil
+}
+
+// logPrismSearchReplace sends the whole file and applies the returned search/replace blocks.
+func logPrismSearchReplace(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, promptName string, input prompts.LogPrismInput) (string, error) {
+	prompt := genkit.LookupPrompt(g, promptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", promptName)
+	}
+
+	input.SearchReplace = true
+	req, err := prompt.Render(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	result, usage, err := logic.GenerateDataWithToolUsage[prompts.SearchReplaceOutput](
+		ctx,
+		g,
+		ai.WithTools(logPrismTools(g)...),
+		req.Messages,
+		ai.WithModel(model),
+	)
+	res.addUsage(usage)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate blocks for %s: %w", input.FilePath, err)
+	}
+
+	newCode, err := applySearchReplace(res, input.Code, result)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply blocks to %s: %w", input.FilePath, err)
+	}
+	return newCode, nil
+}
+
+// logPrismTools returns the registered tools the model may use while instrumenting a file.
+func logPrismTools(g *genkit.Genkit) []ai.ToolRef {
+	findDefTool := genkit.LookupTool(g, tools.FindDefinitionTool)
+	findUsageTool := genkit.LookupTool(g, tools.FindUsageTool)
+	findStructsTool := genkit.LookupTool(g, tools.FindStructsTool)
+	callGraphTool := genkit.LookupTool(g, tools.CallGraphTool)
+	readFileTool := genkit.LookupTool(g, tools.ReadFileTool)
+	readFileLinesTool := genkit.LookupTool(g, tools.ReadFileLinesTool)
+	writeFileTool := genkit.LookupTool(g, tools.WriteFileTool)
+	editFileTool := genkit.LookupTool(g, tools.EditFileTool)
+	applyPatchTool := genkit.LookupTool(g, tools.ApplyPatchTool)
+	listFilesTool := genkit.LookupTool(g, tools.ListFilesTool)
+	createDirectoryTool := genkit.LookupTool(g, tools.CreateDirectoryTool)
+	deleteDirectoryTool := genkit.LookupTool(g, tools.DeleteDirectoryTool)
+	walkDirectoryTool := genkit.LookupTool(g, tools.WalkDirectoryTool)
+
+	var toolRefs []ai.ToolRef
+	if findDefTool != nil {
+		toolRefs = append(toolRefs, findDefTool)
+	}
+	if findUsageTool != nil {
+		toolRefs = append(toolRefs, findUsageTool)
+	}
+	if findStructsTool != nil {
+		toolRefs = append(toolRefs, findStructsTool)
+	}
+	if callGraphTool != nil {
+		toolRefs = append(toolRefs, callGraphTool)
+	}
+	if readFileTool != nil {
+		toolRefs = append(toolRefs, readFileTool)
+	}
+	if readFileLinesTool != nil {
+		toolRefs = append(toolRefs, readFileLinesTool)
+	}
+	if writeFileTool != nil {
+		toolRefs = append(toolRefs, writeFileTool)
+	}
+	if editFileTool != nil {
+		toolRefs = append(toolRefs, editFileTool)
+	}
+	if applyPatchTool != nil {
+		toolRefs = append(toolRefs, applyPatchTool)
+	}
+	if listFilesTool != nil {
+		toolRefs = append(toolRefs, listFilesTool)
+	}
+	if createDirectoryTool != nil {
+		toolRefs = append(toolRefs, createDirectoryTool)
+	}
+	if deleteDirectoryTool != nil {
+		toolRefs = append(toolRefs, deleteDirectoryTool)
+	}
+	if walkDirectoryTool != nil {
+		toolRefs = append(toolRefs, walkDirectoryTool)
+	}
+	return toolRefs
+}
@@ -0,0 +1,34 @@
+package flows
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/useful-genkit/utils/language"
+)
+
+func TestLogPrismPrompts(t *testing.T) {
+	for _, lang := range language.Supported(language.LogPrismFlow) {
+		if _, ok := logPrismPrompts[lang]; !ok {
+			t.Errorf("%s is supported but has no Prism prompt", lang)
+		}
+	}
+	for lang := range logPrismPrompts {
+		if !language.Supports(lang, language.LogPrismFlow) {
+			t.Errorf("%s has a Prism prompt but is not listed as supported", lang)
+		}
+	}
+}
+
+func TestLogPrismSkipsNonSourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"go.mod", "go.work", "BUILD", "WORKSPACE", "defs.bzl", "types.pyi", "index.d.ts", "Dockerfile", "main.c"} {
+		t.Run(name, func(t *testing.T) {
+			res := logPrismFile(context.Background(), nil, LogPrismFlowInput{Path: dir}, nil, filepath.Join(dir, name), "")
+			if res.Status != FileStatusSkipped || res.Reason != "unsupported language" {
+				t.Errorf("result = %s %q, want skipped as an unsupported language", res.Status, res.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package flows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/models"
+	"github.com/snowmerak/useful-genkit/prompts"
+	"github.com/snowmerak/useful-genkit/utils/prism/logs"
+)
+
+type PrismLogAnalyzerFlowInput struct {
+	// Paths lists JSONL log files, or directories whose .jsonl, .ndjson, .json and .log files are read.
+	Paths []string `json:"paths"`
+	// Focus optionally tells the model what to investigate, such as a service or an alert.
+	Focus string `json:"focus,omitempty"`
+	// MaxTimelines caps the request timelines in the report. Defaults to 20.
+	MaxTimelines int `json:"max_timelines,omitempty"`
+	// SkipSummary only computes the report, without asking the model for an incident summary.
+	SkipSummary bool `json:"skip_summary,omitempty"`
+}
+
+type PrismLogAnalyzerFlowOutput struct {
+	Files        []string                     `json:"files"`
+	Report       logs.Report                  `json:"report"`
+	Incident     *prompts.PrismIncidentOutput `json:"incident,omitempty"`
+	Model        string                       `json:"model,omitempty"`
+	InputTokens  int                          `json:"input_tokens,omitempty"`
+	OutputTokens int                          `json:"output_tokens,omitempty"`
+}
+
+const PrismLogAnalyzerFlowName = "PrismLogAnalyzerFlow"
+
+var prismLogExtensions = map[string]bool{
+	".jsonl":  true,
+	".ndjson": true,
+	".json":   true,
+	".log":    true,
+}
+
+func PrismLogAnalyzerFlow(g *genkit.Genkit) {
+	genkit.DefineFlow(g, PrismLogAnalyzerFlowName, func(ctx context.Context, input PrismLogAnalyzerFlowInput) (PrismLogAnalyzerFlowOutput, error) {
+		// 1. Collect the log files
+		files, err := prismLogFiles(input.Paths)
+		if err != nil {
+			return PrismLogAnalyzerFlowOutput{}, err
+		}
+		if len(files) == 0 {
+			return PrismLogAnalyzerFlowOutput{}, fmt.Errorf("no log files found in %v", input.Paths)
+		}
+
+		// 2. Compute the facts
+		entries, stats, err := logs.ReadFiles(files...)
+		if err != nil {
+			return PrismLogAnalyzerFlowOutput{}, fmt.Errorf("failed to read logs: %w", err)
+		}
+		output := PrismLogAnalyzerFlowOutput{
+			Files:  files,
+			Report: logs.Analyze(entries, stats, logs.Options{MaxTimelines: input.MaxTimelines}),
+		}
+		if input.SkipSummary || len(entries) == 0 {
+			return output, nil
+		}
+
+		// 3. Let a model write the incident summary from the facts only
+		facts, err := json.MarshalIndent(output.Report, "", "  ")
+		if err != nil {
+			return PrismLogAnalyzerFlowOutput{}, fmt.Errorf("failed to encode report: %w", err)
+		}
+
+		model, err := models.GetOllamaGptOss20b(g)
+		if err != nil {
+			return PrismLogAnalyzerFlowOutput{}, fmt.Errorf("failed to get model: %w", err)
+		}
+		output.Model = model.Name()
+
+		prompt := genkit.LookupPrompt(g, prompts.PrismIncidentPromptName)
+		if prompt == nil {
+			return PrismLogAnalyzerFlowOutput{}, fmt.Errorf("prompt %s not found", prompts.PrismIncidentPromptName)
+		}
+		req, err := prompt.Render(ctx, prompts.PrismIncidentInput{Facts: string(facts), Focus: input.Focus})
+		if err != nil {
+			return PrismLogAnalyzerFlowOutput{}, fmt.Errorf("failed to render prompt: %w", err)
+		}
+
+		incident, resp, err := genkit.GenerateData[prompts.PrismIncidentOutput](ctx, g, ai.WithMessages(req.Messages...), ai.WithModel(model))
+		if err != nil {
+			return PrismLogAnalyzerFlowOutput{}, fmt.Errorf("failed to generate incident summary: %w", err)
+		}
+		if resp != nil && resp.Usage != nil {
+			output.InputTokens = resp.Usage.InputTokens
+			output.OutputTokens = resp.Usage.OutputTokens
+		}
+		output.Incident = incident
+		return output, nil
+	})
+}
+
+// prismLogFiles expands directories into the log files they contain, in walk order.
+func prismLogFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && prismLogExtensions[filepath.Ext(p)] {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk directory %s: %w", path, err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,156 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/language"
+	"github.com/snowmerak/useful-genkit/utils/loglib"
+	"github.com/snowmerak/useful-genkit/utils/prism"
+	"golang.org/x/mod/modfile"
+)
+
+// PrismScaffold reports the Prism logging package of one Go module.
+type PrismScaffold struct {
+	Module  string     `json:"module"`
+	Dir     string     `json:"dir"`
+	Library string     `json:"library,omitempty"`
+	Status  FileStatus `json:"status"`
+	Reason  string     `json:"reason,omitempty"`
+}
+
+// prismPackageDir is where the Prism logging package lives, relative to the module root.
+const prismPackageDir = "utils/log"
+
+// scaffoldPrism generates the Prism logging package from templates once for every Go module
+// under base that contains one of files. It returns the API description of the package for
+// each Go file whose module has a generated package.
+func scaffoldPrism(ctx context.Context, base string, files []string) (map[string]string, []PrismScaffold, error) {
+	roots := make(map[string][]string)
+	for _, file := range files {
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		root, err := goModuleRoot(base, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if root != "" {
+			roots[root] = append(roots[root], file)
+		}
+	}
+
+	apis := make(map[string]string)
+	var scaffolds []PrismScaffold
+	for _, root := range slices.Sorted(maps.Keys(roots)) {
+		scaffold, api, err := scaffoldPrismModule(ctx, base, root)
+		if err != nil {
+			return nil, nil, err
+		}
+		scaffolds = append(scaffolds, scaffold)
+		if api == "" {
+			continue
+		}
+		for _, file := range roots[root] {
+			apis[file] = api
+		}
+	}
+	return apis, scaffolds, nil
+}
+
+func scaffoldPrismModule(ctx context.Context, base, root string) (PrismScaffold, string, error) {
+	gomod := filepath.Join(root, "go.mod")
+	data, err := os.ReadFile(gomod)
+	if err != nil {
+		return PrismScaffold{}, "", fmt.Errorf("failed to read %s: %w", gomod, err)
+	}
+	module := modfile.ModulePath(data)
+	dir := filepath.Join(root, filepath.FromSlash(prismPackageDir))
+	importPath := path.Join(module, prismPackageDir)
+	scaffold := PrismScaffold{Module: module, Dir: dir}
+
+	if _, err := os.Stat(dir); err == nil {
+		library, ok := generatedPrismLibrary(dir)
+		if !ok {
+			scaffold.Status = FileStatusSkipped
+			scaffold.Reason = prismPackageDir + " already exists and was not generated"
+			return scaffold, "", nil
+		}
+		scaffold.Library = library
+		scaffold.Status = FileStatusUnchanged
+		scaffold.Reason = "already generated"
+		api, err := prism.API(library, dir, importPath)
+		return scaffold, api, err
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return PrismScaffold{}, "", fmt.Errorf("failed to stat %s: %w", dir, err)
+	}
+
+	library, err := loglib.Detect(base, gomod, language.Go)
+	if err != nil {
+		return PrismScaffold{}, "", fmt.Errorf("failed to detect logging library for %s: %w", module, err)
+	}
+	scaffold.Library = library.Name
+	if !prism.Supports(library.Name) {
+		scaffold.Status = FileStatusSkipped
+		scaffold.Reason = "no template for " + library.Name
+		return scaffold, "", nil
+	}
+
+	files, err := prism.Render(library.Name, path.Base(prismPackageDir))
+	if err != nil {
+		return PrismScaffold{}, "", err
+	}
+	if err := journal.MkdirAll(ctx, dir, 0755); err != nil {
+		return PrismScaffold{}, "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if err := journal.WriteFile(ctx, filepath.Join(dir, name), files[name], 0644); err != nil {
+			return PrismScaffold{}, "", fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	scaffold.Status = FileStatusModified
+	api, err := prism.API(library.Name, dir, importPath)
+	return scaffold, api, err
+}
+
+// generatedPrismLibrary reports whether dir holds a generated Prism package and for which library.
+func generatedPrismLibrary(dir string) (string, bool) {
+	src, err := os.ReadFile(filepath.Join(dir, "span.go"))
+	if err != nil {
+		return "", false
+	}
+	return prism.IsGenerated(src)
+}
+
+// goModuleRoot returns the directory of the go.mod nearest to file, looking up to base,
+// or "" when there is none.
+func goModuleRoot(base, file string) (string, error) {
+	root, err := filepath.Abs(base)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", base, err)
+	}
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir || !strings.HasPrefix(parent, root) {
+			return "", nil
+		}
+		dir = parent
+	}
+}
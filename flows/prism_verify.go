@@ -0,0 +1,47 @@
+package flows
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/snowmerak/useful-genkit/utils/prism/lint"
+)
+
+// verifyPrism runs the Prism linter on the modified Go files of results and attaches the
+// findings to them. Files are checked once per Go module; files outside a module are not checked.
+func verifyPrism(base string, results []FileResult) error {
+	byModule := make(map[string][]string)
+	byFile := make(map[string]*FileResult)
+	for i := range results {
+		res := &results[i]
+		if res.Status != FileStatusModified || filepath.Ext(res.File) != ".go" {
+			continue
+		}
+		root, err := goModuleRoot(base, res.File)
+		if err != nil {
+			return err
+		}
+		if root == "" {
+			continue
+		}
+		abs, err := filepath.Abs(res.File)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", res.File, err)
+		}
+		byModule[root] = append(byModule[root], abs)
+		byFile[abs] = res
+	}
+
+	for root, files := range byModule {
+		diags, err := lint.Run(files...)
+		if err != nil {
+			return fmt.Errorf("failed to verify Prism logging in %s: %w", root, err)
+		}
+		for _, d := range diags {
+			if res, ok := byFile[d.File]; ok {
+				res.Lint = append(res.Lint, d)
+			}
+		}
+	}
+	return nil
+}
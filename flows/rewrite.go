@@ -0,0 +1,88 @@
+package flows
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/prompts"
+	"github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/patch"
+)
+
+// RewriteStrategy selects how a code flow exchanges code with the model.
+type RewriteStrategy string
+
+const (
+	// RewriteStrategyFull sends the whole file and asks for the whole file back.
+	RewriteStrategyFull RewriteStrategy = "full"
+	// RewriteStrategyChunked sends only the functions that need work and splices the
+	// rewritten functions back into the file.
+	RewriteStrategyChunked RewriteStrategy = "chunked"
+	// RewriteStrategyLineEdits sends a line-numbered view of the file and applies the
+	// line-anchored edit operations the model returns.
+	RewriteStrategyLineEdits RewriteStrategy = "line_edits"
+	// RewriteStrategySearchReplace asks for SEARCH/REPLACE blocks and locates them in the
+	// file exactly, ignoring whitespace or fuzzily.
+	RewriteStrategySearchReplace RewriteStrategy = "search_replace"
+	// RewriteStrategyDeterministic finds and rewrites the code without the model, which
+	// is only asked for the text the rewrite needs.
+	RewriteStrategyDeterministic RewriteStrategy = "deterministic"
+)
+
+func (s RewriteStrategy) orDefault() RewriteStrategy {
+	if s == "" {
+		return RewriteStrategyFull
+	}
+	return s
+}
+
+// validate rejects strategies the flow does not implement.
+func (s RewriteStrategy) validate(flow string, supported ...RewriteStrategy) error {
+	if !slices.Contains(supported, s.orDefault()) {
+		return fmt.Errorf("%s does not support strategy %q", flow, s)
+	}
+	return nil
+}
+
+// checkpointVersion combines the prompt and the strategy, since both shape the output.
+func (s RewriteStrategy) checkpointVersion(promptName string) string {
+	return promptName + "/" + string(s.orDefault())
+}
+
+// applyLineEdits applies the model's edit operations to the original content.
+// Line numbers the model copied into the edit text are stripped first.
+func applyLineEdits(content []byte, result *prompts.CodeEditsOutput) (string, error) {
+	edits := make([]file.Edit, len(result.Edits))
+	for i, e := range result.Edits {
+		e.Text = file.StripLineNumbers(e.Text)
+		edits[i] = e
+	}
+
+	out, err := file.ApplyEdits(content, edits)
+	if err != nil {
+		return "", fmt.Errorf("invalid edits: %w", err)
+	}
+	return string(out), nil
+}
+
+// applySearchReplace applies the model's search/replace blocks to the original content and
+// records how each block was matched. No block is applied unless all of them match.
+func applySearchReplace(res *FileResult, content string, result *prompts.SearchReplaceOutput) (string, error) {
+	if len(result.Blocks) == 0 {
+		return content, nil
+	}
+
+	out, results, err := patch.Apply(content, result.Blocks, patch.Options{})
+	res.Patches = results
+	if err != nil {
+		var failed []string
+		for _, r := range results {
+			if !r.Applied {
+				failed = append(failed, fmt.Sprintf("block %d: %s", r.Block, r.Error))
+			}
+		}
+		return "", fmt.Errorf("invalid search/replace blocks: %s", strings.Join(failed, "; "))
+	}
+	return out, nil
+}
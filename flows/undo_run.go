@@ -0,0 +1,49 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/utils/checkpoint"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+)
+
+type UndoRunInput struct {
+	RunID string `json:"run_id"`
+}
+
+type UndoRunOutput struct {
+	RunID    string   `json:"run_id"`
+	Restored []string `json:"restored"`
+}
+
+const UndoRunFlowName = "UndoRunFlow"
+
+// UndoRunFlow restores every file touched by a code flow run, including files written
+// or deleted by tools, to its state before the run.
+func UndoRunFlow(g *genkit.Genkit) {
+	genkit.DefineFlow(g, UndoRunFlowName, func(ctx context.Context, input UndoRunInput) (UndoRunOutput, error) {
+		restored, err := journal.Undo(input.RunID)
+		if err != nil {
+			return UndoRunOutput{}, fmt.Errorf("failed to undo run %s: %w", input.RunID, err)
+		}
+
+		// The restored files match their checkpointed input hashes again, so the
+		// checkpoint must go as well or resuming the run would skip them.
+		if err := checkpoint.Discard(input.RunID); err != nil {
+			return UndoRunOutput{}, err
+		}
+
+		return UndoRunOutput{RunID: input.RunID, Restored: restored}, nil
+	})
+}
+
+// withJournal records every file mutation of the run, by the flow or by its tools, in the run's journal.
+func withJournal(ctx context.Context, runID string) (context.Context, error) {
+	j, err := journal.Open(runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	return journal.WithJournal(ctx, j), nil
+}
@@ -0,0 +1,29 @@
+package flows
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snowmerak/useful-genkit/utils/workspace"
+)
+
+// withWorkspace confines the file tools used during the run to path, or to its directory
+// when path is a file. The flow's own writes are not affected.
+func withWorkspace(ctx context.Context, path string, opts workspace.Options) (context.Context, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	root := path
+	if !info.IsDir() {
+		root = filepath.Dir(path)
+	}
+
+	w, err := workspace.New(root, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace: %w", err)
+	}
+	return workspace.WithWorkspace(ctx, w), nil
+}
@@ -3,8 +3,10 @@ package flows
 import (
 	"context"
 	"fmt"
+	"go/ast"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/firebase/genkit/go/ai"
@@ -13,113 +15,491 @@ import (
 	"github.com/snowmerak/useful-genkit/models"
 	"github.com/snowmerak/useful-genkit/prompts"
 	"github.com/snowmerak/useful-genkit/tools"
+	"github.com/snowmerak/useful-genkit/utils/checkpoint"
+	fileutil "github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/git"
+	"github.com/snowmerak/useful-genkit/utils/goerrwrap"
+	"github.com/snowmerak/useful-genkit/utils/gosource"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
 )
 
 type WrapGoErrorInput struct {
 	Path string `json:"path"`
+	// ContinueOnError keeps processing the remaining files when one of them fails.
+	ContinueOnError bool `json:"continue_on_error,omitempty"`
+	// RunID resumes an earlier run, skipping files it already handled and that are unchanged since.
+	// A new run ID is generated when empty.
+	RunID string `json:"run_id,omitempty"`
+	// Commit creates a working branch before the run and commits the rewritten files.
+	Commit *GitCommitOptions `json:"commit,omitempty"`
+	// BaseRef restricts processing to the Go files changed since the merge base of this git ref,
+	// including untracked files that are not ignored.
+	BaseRef string `json:"base_ref,omitempty"`
+	// ChangedHunksOnly further asks the model to touch only the functions overlapping changed hunks.
+	// It requires BaseRef.
+	ChangedHunksOnly bool `json:"changed_hunks_only,omitempty"`
+	// Strategy selects how code is exchanged with the model: "full" (default), "chunked",
+	// "line_edits", "search_replace" or "deterministic", which only asks the model for error messages.
+	Strategy RewriteStrategy `json:"strategy,omitempty"`
+	// Workspace adds read-only and deny rules to the file tools, which are always confined to Path.
+	Workspace workspace.Options `json:"workspace,omitempty"`
 }
 
 type WrapGoErrorOutput struct {
-	ProcessedFiles []string `json:"processed_files"`
+	RunID          string           `json:"run_id"`
+	ProcessedFiles []string         `json:"processed_files"`
+	Files          []FileResult     `json:"files"`
+	Summary        RunSummary       `json:"summary"`
+	Git            *GitCommitResult `json:"git,omitempty"`
 }
 
+const WrapGoErrorFlowName = "WrapGoErrorFlow"
+
 func WrapGoErrorFlow(g *genkit.Genkit) {
-	genkit.DefineFlow(g, "WrapGoErrorFlow", func(ctx context.Context, input WrapGoErrorInput) (WrapGoErrorOutput, error) {
-		var processedFiles []string
-
-		// 1. List all files in the directory recursively (Inline implementation)
-		var files []string
-		err := filepath.Walk(input.Path, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() {
-				files = append(files, path)
-			}
-			return nil
+	genkit.DefineFlow(g, WrapGoErrorFlowName, func(ctx context.Context, input WrapGoErrorInput) (WrapGoErrorOutput, error) {
+		return runErrorContext(ctx, WrapGoErrorFlowName, input, func(ctx context.Context, manifest *checkpoint.Manifest, file string) FileResult {
+			return wrapGoErrorFile(ctx, g, input, manifest, file)
 		})
+	})
+}
+
+// runErrorContext runs processFile over the files of an error wrapping flow, with
+// checkpoints, the undo journal and the optional git integration.
+func runErrorContext(ctx context.Context, flow string, input WrapGoErrorInput, processFile func(ctx context.Context, manifest *checkpoint.Manifest, file string) FileResult) (WrapGoErrorOutput, error) {
+	// 1. List all files in the directory recursively (Inline implementation)
+	var files []string
+	err := filepath.Walk(input.Path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return WrapGoErrorOutput{}, fmt.Errorf("failed to walk directory: %w", err)
+			return err
 		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return WrapGoErrorOutput{}, fmt.Errorf("failed to walk directory: %w", err)
+	}
 
-		// 2. Process each Go file
-		for _, file := range files {
-			// Read file content (Inline implementation)
-			contentBytes, err := os.ReadFile(file)
-			if err != nil {
-				return WrapGoErrorOutput{}, fmt.Errorf("failed to read file %s: %w", file, err)
-			}
-			content := string(contentBytes)
+	if err := input.Strategy.validate(flow, RewriteStrategyFull, RewriteStrategyChunked, RewriteStrategyLineEdits, RewriteStrategySearchReplace, RewriteStrategyDeterministic); err != nil {
+		return WrapGoErrorOutput{}, err
+	}
+	if input.ChangedHunksOnly && input.BaseRef == "" {
+		return WrapGoErrorOutput{}, fmt.Errorf("changed_hunks_only requires base_ref")
+	}
+	if input.BaseRef != "" {
+		files, err = filterChangedFiles(ctx, input.Path, input.BaseRef, files)
+		if err != nil {
+			return WrapGoErrorOutput{}, err
+		}
+	}
 
-			// Check if it needs wrapping (simple heuristic to save tokens)
-			// if !strings.Contains(content, "return err") && !strings.Contains(content, "return nil, err") {
-			// 	continue
-			// }
+	runID, manifest, err := openCheckpoint(input.RunID, flow)
+	if err != nil {
+		return WrapGoErrorOutput{}, err
+	}
 
-			// Generate modified code
-			prompt := genkit.LookupPrompt(g, prompts.WrapErrorPromptName)
-			if prompt == nil {
-				return WrapGoErrorOutput{}, fmt.Errorf("prompt %s not found", prompts.WrapErrorPromptName)
-			}
+	ctx, err = withJournal(ctx, runID)
+	if err != nil {
+		return WrapGoErrorOutput{}, err
+	}
 
-			// Use a model to generate the response
-			model, err := models.GetOllamaDevstralSmall2(g)
-			if err != nil {
-				return WrapGoErrorOutput{}, fmt.Errorf("failed to get model: %w", err)
-			}
+	ctx, err = withWorkspace(ctx, input.Path, input.Workspace)
+	if err != nil {
+		return WrapGoErrorOutput{}, err
+	}
 
-			req, err := prompt.Render(ctx, prompts.WrapErrorInput{
-				Code:     content,
-				BasePath: input.Path,
-				FilePath: file,
-			})
-			if err != nil {
-				return WrapGoErrorOutput{}, fmt.Errorf("failed to render prompt: %w", err)
-			}
+	var branch string
+	if input.Commit != nil {
+		branch, err = startGitBranch(ctx, input.Path, flow, runID, input.RunID != "", input.Commit)
+		if err != nil {
+			return WrapGoErrorOutput{}, err
+		}
+	}
 
-			// Add tools to the request
-			findDefTool := genkit.LookupTool(g, tools.FindDefinitionTool)
-			findUsageTool := genkit.LookupTool(g, tools.FindUsageTool)
-			findStructsTool := genkit.LookupTool(g, tools.FindStructsTool)
+	// 2. Process each file
+	var results []FileResult
+	var abort error
+	for _, file := range files {
+		res := processFile(ctx, manifest, file)
+		if err := recordCheckpoint(manifest, res); err != nil {
+			return WrapGoErrorOutput{RunID: runID}, err
+		}
+		results = append(results, res)
+		if res.Status == FileStatusFailed && !input.ContinueOnError {
+			abort = abortError(runID, res)
+			break
+		}
+	}
 
-			var toolRefs []ai.ToolRef
-			if findDefTool != nil {
-				toolRefs = append(toolRefs, findDefTool)
-			}
-			if findUsageTool != nil {
-				toolRefs = append(toolRefs, findUsageTool)
-			}
-			if findStructsTool != nil {
-				toolRefs = append(toolRefs, findStructsTool)
-			}
+	output := WrapGoErrorOutput{
+		RunID:          runID,
+		ProcessedFiles: modifiedFiles(results),
+		Files:          results,
+		Summary:        summarize(results),
+	}
 
-			result, err := logic.GenerateDataWithTool[prompts.WrapErrorOutput](
-				ctx,
-				g,
-				ai.WithTools(toolRefs...),
-				req.Messages,
-				ai.WithModel(model),
-				// ai.WithConfig(req.Config),
-			)
-			if err != nil {
-				return WrapGoErrorOutput{}, fmt.Errorf("failed to generate code for %s: %w", file, err)
-			}
+	// Commit what was rewritten even when the run aborts, so the work is kept on the branch.
+	if input.Commit != nil {
+		commits, err := commitResults(ctx, input.Path, flow, "wrap returned errors", input.Commit, runID, results)
+		if err != nil {
+			return output, fmt.Errorf("run %s: %w", runID, err)
+		}
+		output.Git = &GitCommitResult{Branch: branch, Commits: commits}
+	}
+
+	// The output carries the run ID and the results so far, for resuming the run.
+	if abort != nil {
+		return output, abort
+	}
+	return output, nil
+}
 
-			newCode := result.Code
-			// Clean up markdown code blocks if present
-			newCode = strings.TrimPrefix(newCode, "```go")
-			newCode = strings.TrimPrefix(newCode, "```")
-			newCode = strings.TrimSuffix(newCode, "```")
-			newCode = strings.TrimSpace(newCode)
+func wrapGoErrorFile(ctx context.Context, g *genkit.Genkit, input WrapGoErrorInput, manifest *checkpoint.Manifest, file string) FileResult {
+	res := newFileResult(file)
 
-			// Write back to file (Inline implementation)
-			if err := os.WriteFile(file, []byte(newCode), 0644); err != nil {
-				return WrapGoErrorOutput{}, fmt.Errorf("failed to write file %s: %w", file, err)
+	if filepath.Ext(file) != ".go" {
+		return res.finish(FileStatusSkipped, "not a Go file")
+	}
+
+	promptName := prompts.WrapErrorPromptName
+	switch input.Strategy {
+	case RewriteStrategyChunked:
+		promptName = prompts.WrapErrorFunctionsPromptName
+	case RewriteStrategyDeterministic:
+		promptName = prompts.WrapErrorMessagesPromptName
+	}
+
+	// Read file content
+	contentBytes, err := os.ReadFile(file)
+	if err != nil {
+		return res.fail(fmt.Errorf("failed to read file %s: %w", file, err))
+	}
+	content := string(contentBytes)
+	if res.resumed(manifest, contentBytes, input.Strategy.checkpointVersion(promptName)) {
+		return res.finish(FileStatusSkipped, "already processed in run "+manifest.RunID)
+	}
+
+	var functions []string
+	if input.ChangedHunksOnly {
+		hunks, err := git.ChangedHunks(ctx, input.Path, input.BaseRef, file)
+		if err != nil {
+			return res.fail(fmt.Errorf("failed to get changed hunks of %s: %w", file, err))
+		}
+		functions, err = changedFunctions(file, contentBytes, hunks)
+		if err != nil {
+			return res.fail(err)
+		}
+		if len(functions) == 0 {
+			return res.finish(FileStatusSkipped, "no changed functions")
+		}
+	}
+
+	var src *gosource.File
+	if input.Strategy == RewriteStrategyChunked {
+		src, err = gosource.Split(file, contentBytes, func(fn *ast.FuncDecl) bool {
+			if functions != nil && !slices.Contains(functions, gosource.FuncName(fn)) {
+				return false
 			}
+			return gosource.ReturnsBareError(fn)
+		})
+		if err != nil {
+			return res.fail(err)
+		}
+		if len(src.Chunks) == 0 {
+			return res.finish(FileStatusUnchanged, "no bare error returns")
+		}
+	}
 
-			processedFiles = append(processedFiles, file)
+	var sites *goerrwrap.File
+	if input.Strategy == RewriteStrategyDeterministic {
+		sites, err = goerrwrap.Analyze(file, contentBytes)
+		if err != nil {
+			return res.fail(err)
+		}
+		if functions != nil {
+			sites.Filter(func(s goerrwrap.Site) bool { return slices.Contains(functions, s.Func) })
 		}
+		if len(sites.Sites) == 0 {
+			return res.finish(FileStatusUnchanged, "no bare error returns")
+		}
+	}
+
+	// Use a model to generate the response
+	model, err := models.GetOllamaDevstralSmall2(g)
+	if err != nil {
+		return res.fail(fmt.Errorf("failed to get model: %w", err))
+	}
+	res.Model = model.Name()
+
+	var newCode string
+	switch {
+	case src != nil:
+		newCode, err = wrapGoErrorChunks(ctx, g, res, model, input, src)
+	case sites != nil:
+		newCode, err = wrapGoErrorDeterministic(ctx, g, res, model, input, sites)
+	case input.Strategy == RewriteStrategyLineEdits:
+		newCode, err = wrapGoErrorLineEdits(ctx, g, res, model, input, file, contentBytes, functions)
+	case input.Strategy == RewriteStrategySearchReplace:
+		newCode, err = wrapGoErrorSearchReplace(ctx, g, res, model, input, file, content, functions)
+	default:
+		newCode, err = wrapGoErrorFullFile(ctx, g, res, model, input, file, content, functions)
+	}
+	if err != nil {
+		return res.fail(err)
+	}
 
-		return WrapGoErrorOutput{ProcessedFiles: processedFiles}, nil
+	if newCode == "" {
+		return res.fail(fmt.Errorf("model returned empty code for %s", file))
+	}
+	if newCode == strings.TrimSpace(content) || newCode == content {
+		return res.finish(FileStatusUnchanged, "model returned the code unchanged")
+	}
+	if err := guardRewrite(res, contentBytes, []byte(newCode), wrapGoErrorPolicy); err != nil {
+		return res.fail(err)
+	}
+
+	// Write back to file
+	if err := journal.WriteFile(ctx, file, []byte(newCode), 0644); err != nil {
+		return res.fail(fmt.Errorf("failed to write file %s: %w", file, err))
+	}
+	res.outputHash = fileutil.Hash([]byte(newCode))
+
+	return res.finish(FileStatusModified, "")
+}
+
+// wrapGoErrorFullFile sends the whole file and returns the whole rewritten file.
+func wrapGoErrorFullFile(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, input WrapGoErrorInput, file, content string, functions []string) (string, error) {
+	prompt := genkit.LookupPrompt(g, prompts.WrapErrorPromptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", prompts.WrapErrorPromptName)
+	}
+
+	req, err := prompt.Render(ctx, prompts.WrapErrorInput{
+		Code:      content,
+		BasePath:  input.Path,
+		FilePath:  file,
+		Functions: strings.Join(functions, ", "),
 	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	result, usage, err := logic.GenerateDataWithToolUsage[prompts.WrapErrorOutput](
+		ctx,
+		g,
+		ai.WithTools(codeSearchTools(g)...),
+		req.Messages,
+		ai.WithModel(model),
+		// ai.WithConfig(req.Config),
+	)
+	res.addUsage(usage)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code for %s: %w", file, err)
+	}
+
+	newCode := result.Code
+	// Clean up markdown code blocks if present
+	newCode = strings.TrimPrefix(newCode, "```go")
+	newCode = strings.TrimPrefix(newCode, "```")
+	newCode = strings.TrimSuffix(newCode, "```")
+	return strings.TrimSpace(newCode), nil
+}
+
+// wrapGoErrorLineEdits sends a line-numbered file and applies the returned edit operations.
+func wrapGoErrorLineEdits(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, input WrapGoErrorInput, file string, content []byte, functions []string) (string, error) {
+	prompt := genkit.LookupPrompt(g, prompts.WrapErrorPromptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", prompts.WrapErrorPromptName)
+	}
+
+	req, err := prompt.Render(ctx, prompts.WrapErrorInput{
+		Code:      string(fileutil.AttachLineNumbers(content)),
+		BasePath:  input.Path,
+		FilePath:  file,
+		Functions: strings.Join(functions, ", "),
+		LineEdits: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	result, usage, err := logic.GenerateDataWithToolUsage[prompts.CodeEditsOutput](
+		ctx,
+		g,
+		ai.WithTools(codeSearchTools(g)...),
+		req.Messages,
+		ai.WithModel(model),
+	)
+	res.addUsage(usage)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate edits for %s: %w", file, err)
+	}
+
+	newCode, err := applyLineEdits(content, result)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply edits to %s: %w", file, err)
+	}
+	return newCode, nil
+}
+
+// wrapGoErrorSearchReplace sends the whole file and applies the returned search/replace blocks.
+func wrapGoErrorSearchReplace(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, input WrapGoErrorInput, file, content string, functions []string) (string, error) {
+	prompt := genkit.LookupPrompt(g, prompts.WrapErrorPromptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", prompts.WrapErrorPromptName)
+	}
+
+	req, err := prompt.Render(ctx, prompts.WrapErrorInput{
+		Code:          content,
+		BasePath:      input.Path,
+		FilePath:      file,
+		Functions:     strings.Join(functions, ", "),
+		SearchReplace: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	result, usage, err := logic.GenerateDataWithToolUsage[prompts.SearchReplaceOutput](
+		ctx,
+		g,
+		ai.WithTools(codeSearchTools(g)...),
+		req.Messages,
+		ai.WithModel(model),
+	)
+	res.addUsage(usage)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate blocks for %s: %w", file, err)
+	}
+
+	newCode, err := applySearchReplace(res, content, result)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply blocks to %s: %w", file, err)
+	}
+	return newCode, nil
+}
+
+// wrapGoErrorChunks sends only the selected functions and splices the rewritten ones
+// back into the original file, adding the fmt import when it becomes necessary.
+func wrapGoErrorChunks(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, input WrapGoErrorInput, src *gosource.File) (string, error) {
+	prompt := genkit.LookupPrompt(g, prompts.WrapErrorFunctionsPromptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", prompts.WrapErrorFunctionsPromptName)
+	}
+
+	functions := make([]prompts.WrapErrorFunction, 0, len(src.Chunks))
+	for _, c := range src.Chunks {
+		functions = append(functions, prompts.WrapErrorFunction{ID: c.ID, Name: c.Name, Code: c.Code})
+	}
+
+	req, err := prompt.Render(ctx, prompts.WrapErrorFunctionsInput{
+		Context:   src.Context(),
+		Functions: functions,
+		BasePath:  input.Path,
+		FilePath:  src.Name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	result, usage, err := logic.GenerateDataWithToolUsage[prompts.WrapErrorFunctionsOutput](
+		ctx,
+		g,
+		ai.WithTools(codeSearchTools(g)...),
+		req.Messages,
+		ai.WithModel(model),
+	)
+	res.addUsage(usage)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code for %s: %w", src.Name, err)
+	}
+
+	replacements := make(map[int]string, len(result.Functions))
+	for _, fn := range result.Functions {
+		replacements[fn.ID] = fn.Code
+	}
+	out, err := src.Splice(replacements)
+	if err != nil {
+		return "", fmt.Errorf("failed to splice functions into %s: %w", src.Name, err)
+	}
+
+	usesFmt, err := gosource.UsesPackage(out, "fmt")
+	if err != nil {
+		return "", fmt.Errorf("rewritten %s does not parse: %w", src.Name, err)
+	}
+	if usesFmt {
+		if out, err = gosource.AddImport(out, "fmt"); err != nil {
+			return "", fmt.Errorf("failed to add fmt import to %s: %w", src.Name, err)
+		}
+	}
+	return string(out), nil
+}
+
+// wrapGoErrorDeterministic asks the model for one message per site in a single call and
+// rewrites the sites itself. Sites the model leaves out get a message derived from the code.
+func wrapGoErrorDeterministic(ctx context.Context, g *genkit.Genkit, res *FileResult, model ai.Model, input WrapGoErrorInput, src *goerrwrap.File) (string, error) {
+	prompt := genkit.LookupPrompt(g, prompts.WrapErrorMessagesPromptName)
+	if prompt == nil {
+		return "", fmt.Errorf("prompt %s not found", prompts.WrapErrorMessagesPromptName)
+	}
+
+	sites := make([]prompts.WrapErrorSite, 0, len(src.Sites))
+	for _, s := range src.Sites {
+		sites = append(sites, prompts.WrapErrorSite{ID: s.ID, Func: s.Func, Call: s.Call, Snippet: s.Snippet})
+	}
+
+	req, err := prompt.Render(ctx, prompts.WrapErrorMessagesInput{
+		Sites:    sites,
+		BasePath: input.Path,
+		FilePath: src.Name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	result, resp, err := genkit.GenerateData[prompts.WrapErrorMessagesOutput](ctx, g, ai.WithMessages(req.Messages...), ai.WithModel(model))
+	if resp != nil {
+		res.addUsage(resp.Usage)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate messages for %s: %w", src.Name, err)
+	}
+
+	messages := make(map[int]string, len(result.Messages))
+	for _, m := range result.Messages {
+		messages[m.ID] = m.Message
+	}
+	out, err := src.Rewrite(messages)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap errors in %s: %w", src.Name, err)
+	}
+	return string(out), nil
+}
+
+// codeSearchTools returns the registered code search tools.
+func codeSearchTools(g *genkit.Genkit) []ai.ToolRef {
+	findDefTool := genkit.LookupTool(g, tools.FindDefinitionTool)
+	findUsageTool := genkit.LookupTool(g, tools.FindUsageTool)
+	findStructsTool := genkit.LookupTool(g, tools.FindStructsTool)
+	callGraphTool := genkit.LookupTool(g, tools.CallGraphTool)
+
+	var toolRefs []ai.ToolRef
+	if findDefTool != nil {
+		toolRefs = append(toolRefs, findDefTool)
+	}
+	if findUsageTool != nil {
+		toolRefs = append(toolRefs, findUsageTool)
+	}
+	if findStructsTool != nil {
+		toolRefs = append(toolRefs, findStructsTool)
+	}
+	if callGraphTool != nil {
+		toolRefs = append(toolRefs, callGraphTool)
+	}
+	return toolRefs
 }
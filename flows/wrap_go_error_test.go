@@ -0,0 +1,85 @@
+package flows
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/useful-genkit/utils/checkpoint"
+	fileutil "github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/state"
+)
+
+// fakeProcessor appends a comment to every file except those in fail, checkpointing
+// like the real flows do.
+func fakeProcessor(fail map[string]bool) func(ctx context.Context, manifest *checkpoint.Manifest, file string) FileResult {
+	return func(ctx context.Context, manifest *checkpoint.Manifest, file string) FileResult {
+		res := newFileResult(file)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return res.fail(err)
+		}
+		if res.resumed(manifest, content, "test") {
+			return res.finish(FileStatusSkipped, "already processed in run "+manifest.RunID)
+		}
+		if fail[filepath.Base(file)] {
+			return res.fail(errors.New("model failed"))
+		}
+		out := append(content, "// done\n"...)
+		if err := journal.WriteFile(ctx, file, out, 0644); err != nil {
+			return res.fail(err)
+		}
+		res.outputHash = fileutil.Hash(out)
+		return res.finish(FileStatusModified, "")
+	}
+}
+
+// writeTree creates the files under dir and points the run state at a temporary directory.
+func writeTree(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	t.Setenv(state.DirEnv, t.TempDir())
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("package a\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func statuses(results []FileResult) map[string]FileStatus {
+	m := make(map[string]FileStatus)
+	for _, r := range results {
+		m[filepath.Base(r.File)] = r.Status
+	}
+	return m
+}
+
+func TestRunErrorContextAbortKeepsOutput(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, "a.go", "b.go", "c.go")
+	ctx := context.Background()
+
+	out, err := runErrorContext(ctx, "TestFlow", WrapGoErrorInput{Path: dir}, fakeProcessor(map[string]bool{"b.go": true}))
+	if err == nil {
+		t.Fatal("run did not abort")
+	}
+	if out.RunID == "" || !strings.Contains(err.Error(), out.RunID) {
+		t.Fatalf("abort error %q does not name the run %q", err, out.RunID)
+	}
+	got := statuses(out.Files)
+	if len(got) != 2 || got["a.go"] != FileStatusModified || got["b.go"] != FileStatusFailed {
+		t.Fatalf("aborted run results = %v", got)
+	}
+
+	out, err = runErrorContext(ctx, "TestFlow", WrapGoErrorInput{Path: dir, RunID: out.RunID}, fakeProcessor(nil))
+	if err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	got = statuses(out.Files)
+	if got["a.go"] != FileStatusSkipped || got["b.go"] != FileStatusModified || got["c.go"] != FileStatusModified {
+		t.Fatalf("resumed run results = %v", got)
+	}
+}
@@ -2,7 +2,11 @@ module github.com/snowmerak/useful-genkit
 
 go 1.25.1
 
-require github.com/firebase/genkit/go v1.2.0
+require (
+	github.com/firebase/genkit/go v1.2.0
+	golang.org/x/mod v0.25.0
+	golang.org/x/tools v0.34.0
+)
 
 require (
 	cloud.google.com/go v0.120.0 // indirect
@@ -42,6 +46,7 @@ require (
 	go.opentelemetry.io/otel/trace v1.36.0 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
 	golang.org/x/net v0.41.0 // indirect
+	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/genai v1.30.0 // indirect
@@ -11,11 +11,21 @@ import (
 const MaxTurns = 100
 
 func GenerateDataWithTool[out any](ctx context.Context, g *genkit.Genkit, tools ai.CommonGenOption, messages []*ai.Message, opts ...ai.GenerateOption) (*out, error) {
+	result, _, err := GenerateDataWithToolUsage[out](ctx, g, tools, messages, opts...)
+	return result, err
+}
+
+// GenerateDataWithToolUsage works like GenerateDataWithTool but also returns the token usage
+// summed over the tool-calling pass and the final structured pass.
+func GenerateDataWithToolUsage[out any](ctx context.Context, g *genkit.Genkit, tools ai.CommonGenOption, messages []*ai.Message, opts ...ai.GenerateOption) (*out, *ai.GenerationUsage, error) {
+	usage := &ai.GenerationUsage{}
+
 	toolOpts := append([]ai.GenerateOption{tools, ai.WithMessages(messages...), ai.WithMaxTurns(MaxTurns)}, opts...)
 	resp, err := genkit.Generate(ctx, g, toolOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate: %w", err)
+		return nil, usage, fmt.Errorf("failed to generate: %w", err)
 	}
+	addUsage(usage, resp.Usage)
 
 	messages = resp.History()
 	messages = append(messages, &ai.Message{
@@ -27,10 +37,22 @@ func GenerateDataWithTool[out any](ctx context.Context, g *genkit.Genkit, tools
 		},
 	})
 
-	result, _, err := genkit.GenerateData[out](ctx, g, append(opts, ai.WithMessages(messages...))...)
+	result, dataResp, err := genkit.GenerateData[out](ctx, g, append(opts, ai.WithMessages(messages...))...)
+	if dataResp != nil {
+		addUsage(usage, dataResp.Usage)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate data: %w", err)
+		return nil, usage, fmt.Errorf("failed to generate data: %w", err)
 	}
 
-	return result, nil
+	return result, usage, nil
+}
+
+func addUsage(total, u *ai.GenerationUsage) {
+	if u == nil {
+		return
+	}
+	total.InputTokens += u.InputTokens
+	total.OutputTokens += u.OutputTokens
+	total.TotalTokens += u.TotalTokens
 }
@@ -81,13 +81,24 @@ func main() {
 
 	_ = prompts.TranslationPrompt(g)
 	_ = prompts.WrapErrorPrompt(g)
-	_ = prompts.LogPrismPrompt(g)
+	_ = prompts.WrapErrorFunctionsPrompt(g)
+	_ = prompts.WrapErrorMessagesPrompt(g)
+	_ = prompts.ErrorContextRustPrompt(g)
+	_ = prompts.ErrorContextPythonPrompt(g)
+	_ = prompts.ErrorContextTypeScriptPrompt(g)
+	_ = prompts.LogPrismGoPrompt(g)
+	_ = prompts.LogPrismPythonPrompt(g)
+	_ = prompts.LogPrismTypeScriptPrompt(g)
+	_ = prompts.LogPrismRustPrompt(g)
+	_ = prompts.PrismIncidentPrompt(g)
 
 	_ = tools.GetCurrentTime(g)
 	_ = tools.FindUsage(g)
 	_ = tools.FindDefinition(g)
 	_ = tools.FindStructs(g)
-	// _ = tools.FindInterfaceImplementations(g)
+	_ = tools.Grep(g)
+	_ = tools.FindInterfaceImplementations(g)
+	_ = tools.CallGraph(g)
 
 	_ = tools.ListFiles(g)
 	_ = tools.CreateDirectory(g)
@@ -95,11 +106,17 @@ func main() {
 	_ = tools.GetCurrentDirectory(g)
 	_ = tools.WalkDirectory(g)
 	_ = tools.ReadFile(g)
+	_ = tools.ReadFileLines(g)
 	_ = tools.WriteFile(g)
+	_ = tools.EditFile(g)
+	_ = tools.ApplyPatch(g)
 
 	flows.TranslationFlow(g)
 	flows.WrapGoErrorFlow(g)
+	flows.ErrorContextFlow(g)
 	flows.LogPrismFlow(g)
+	flows.PrismLogAnalyzerFlow(g)
+	flows.UndoRunFlow(g)
 
 	mux := http.NewServeMux()
 
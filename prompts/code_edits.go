@@ -0,0 +1,34 @@
+package prompts
+
+import (
+	"github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/patch"
+)
+
+// CodeEditsOutput is the output of the code prompts when they are rendered with line_edits.
+type CodeEditsOutput struct {
+	Edits []file.Edit `json:"edits"`
+}
+
+// SearchReplaceOutput is the output of the code prompts when they are rendered with search_replace.
+type SearchReplaceOutput struct {
+	Blocks []patch.Block `json:"blocks"`
+}
+
+// lineEditsInstructions replaces the request for the full source code in line edit mode.
+const lineEditsInstructions = `The code above is shown with line numbers ("  12: ..."); they are not part of the file.
+Do NOT return the full source code. Return a list of edit operations against these line numbers instead:
+- "replace": replace lines "start" to "end" (inclusive) with "text".
+- "insert_after": insert "text" after line "start". Use 0 to insert at the top of the file.
+- "remove": remove lines "start" to "end" (inclusive).
+All line numbers refer to the code as shown above, not to the code after other edits.
+Edits must not overlap. "text" may span several lines and must not contain line numbers.
+Keep indentation exact. Only edit lines that need to change; return an empty list when nothing needs to change.`
+
+// searchReplaceInstructions replaces the request for the full source code in search/replace mode.
+const searchReplaceInstructions = `Do NOT return the full source code. Return a list of search/replace blocks instead:
+- "search": a contiguous run of lines copied exactly from the code above, including indentation.
+- "replace": the lines that take their place.
+Each "search" must match exactly one place in the file; include a few surrounding lines when needed to make it unique.
+Keep blocks small and do not let them overlap. They are applied in order.
+Only change what needs to change; return an empty list when nothing needs to change.`
@@ -0,0 +1,92 @@
+package prompts
+
+import (
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+const (
+	ErrorContextRustPromptName       = "ErrorContextRustPrompt"
+	ErrorContextPythonPromptName     = "ErrorContextPythonPrompt"
+	ErrorContextTypeScriptPromptName = "ErrorContextTypeScriptPrompt"
+)
+
+// ErrorContextInput is the input of the error context prompts for languages other than Go.
+type ErrorContextInput struct {
+	Code     string `json:"code"`
+	BasePath string `json:"base_path"`
+	FilePath string `json:"file_path"`
+	// Library is the error library the project already depends on, such as "anyhow" or "eyre".
+	Library string `json:"library,omitempty"`
+	// Lines optionally restricts the rewrite to a comma-separated list of line ranges.
+	Lines string `json:"lines,omitempty"`
+	// LineEdits asks for CodeEditsOutput instead of ErrorContextOutput. Code must be line-numbered.
+	LineEdits bool `json:"line_edits,omitempty"`
+	// SearchReplace asks for SearchReplaceOutput instead of ErrorContextOutput.
+	SearchReplace bool `json:"search_replace,omitempty"`
+}
+
+type ErrorContextOutput struct {
+	Code string `json:"code"`
+}
+
+func ErrorContextRustPrompt(g *genkit.Genkit) ai.Prompt {
+	return defineErrorContextPrompt(g, ErrorContextRustPromptName, `You are a Rust expert. Your task is to refactor the given Rust code.
+The project uses the "{{library}}" crate for errors.
+Find all places where an error is propagated with "?" without context, e.g. "let data = fs::read(path)?;".
+Add context describing the operation that failed, based on the function and the call being made:
+- Use ".context(\"failed to read config file\")?" for static messages.
+- Use ".with_context(|| format!(\"failed to read {}\", path.display()))?" when the message needs values.
+{{#ifEquals library "eyre"}}- With eyre, ".wrap_err(...)" and ".wrap_err_with(...)" are also fine.
+{{/ifEquals}}Import the extension trait when it is not imported yet: "use anyhow::Context;" for anyhow, "use eyre::WrapErr;" for eyre.
+Keep every "?" operator. Only add context to Result values; do not add it where the error type is not compatible with {{library}}.
+Do NOT change any other logic.
+Do NOT add context where it is already present.`)
+}
+
+func ErrorContextPythonPrompt(g *genkit.Genkit) ai.Prompt {
+	return defineErrorContextPrompt(g, ErrorContextPythonPromptName, `You are a Python expert. Your task is to refactor the given Python code.
+Find all places where an exception is raised inside an "except" block without chaining the original exception,
+e.g. "raise ValueError(\"bad config\")" inside "except KeyError as e:".
+Chain it with "raise ... from e", binding the caught exception with "as e" when it is not bound yet.
+Where an exception is caught and re-raised unchanged only to add nothing, leave it as it is.
+Make the message describe the operation that failed, based on the function and the call being made.
+Do NOT change any other logic.
+Do NOT chain exceptions that are already chained with "from".`)
+}
+
+func ErrorContextTypeScriptPrompt(g *genkit.Genkit) ai.Prompt {
+	return defineErrorContextPrompt(g, ErrorContextTypeScriptPromptName, `You are a TypeScript expert. Your task is to refactor the given TypeScript code.
+Find all places where a caught error is rethrown directly or replaced by a new error that loses it,
+e.g. "catch (e) { throw e; }" or "catch (e) { throw new Error(\"failed\"); }".
+Wrap it with "throw new Error(\"failed to load user\", { cause: e });", describing the operation that failed
+based on the function and the call being made. Custom error classes that accept options may be used the same way.
+Bind the caught error in the catch clause when it is not bound yet.
+Do NOT change any other logic.
+Do NOT wrap errors that already carry a cause.`)
+}
+
+// defineErrorContextPrompt appends the code and the requested output format to the
+// language-specific instructions.
+func defineErrorContextPrompt(g *genkit.Genkit, name, instructions string) ai.Prompt {
+	return genkit.DefinePrompt(g, name, ai.WithPrompt(instructions+`
+{{#if lines}}
+Only modify the following lines, which were changed on this branch: {{lines}}.
+Leave every other line exactly as it is.
+{{/if}}
+
+Base Path: {{base_path}}
+File Path: {{file_path}}
+
+Here is the code:
+
+{{code}}
+
+{{#if line_edits}}
+`+lineEditsInstructions+`
+{{else}}{{#if search_replace}}
+`+searchReplaceInstructions+`
+{{else}}
+Return the FULL source code with the modifications applied. Do not omit any parts of the code.
+{{/if}}{{/if}}`), ai.WithInputType(&ErrorContextInput{}))
+}
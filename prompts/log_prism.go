@@ -5,30 +5,51 @@ import (
 	"github.com/firebase/genkit/go/genkit"
 )
 
-const LogPrismPromptName = "LogPrismPromptV2"
+const (
+	LogPrismGoPromptName         = "LogPrismGoPrompt"
+	LogPrismPythonPromptName     = "LogPrismPythonPrompt"
+	LogPrismTypeScriptPromptName = "LogPrismTypeScriptPrompt"
+	LogPrismRustPromptName       = "LogPrismRustPrompt"
+)
 
 type LogPrismInput struct {
 	Code     string `json:"code"`
 	BasePath string `json:"base_path"`
 	FilePath string `json:"file_path"`
+	// Library is the logging library to use, such as "slog", "zerolog" or "structlog".
+	Library string `json:"library"`
+	// LibraryDetected reports whether the project already depends on Library.
+	LibraryDetected bool `json:"library_detected,omitempty"`
+	// PrismAPI describes the generated Prism logging package the code must use, if there is one.
+	PrismAPI string `json:"prism_api,omitempty"`
+	// LineEdits asks for CodeEditsOutput instead of LogPrismOutput. Code must be line-numbered.
+	LineEdits bool `json:"line_edits,omitempty"`
+	// SearchReplace asks for SearchReplaceOutput instead of LogPrismOutput.
+	SearchReplace bool `json:"search_replace,omitempty"`
 }
 
 type LogPrismOutput struct {
 	Code string `json:"code"`
 }
 
-func LogPrismPrompt(g *genkit.Genkit) ai.Prompt {
-	return genkit.DefinePrompt(g, LogPrismPromptName, ai.WithPrompt(`You are a Devops expert. Your task is to add "Prism" style logging (Span and State) to the given code based on the concept below. You can use some log libraries:
-- Go: zerolog(github.com/rs/zerolog) or slog(standard library)
-- Python: structlog
-- TypeScript/JavaScript: logtape
-- Rust: tracing crate
+func LogPrismGoPrompt(g *genkit.Genkit) ai.Prompt {
+	return defineLogPrismPrompt(g, LogPrismGoPromptName, "Go", `Use {{library}} for logging ("log/slog" for slog, "github.com/rs/zerolog" for zerolog, "go.uber.org/zap" for zap, "github.com/sirupsen/logrus" for logrus).`, `4.  **Context**: Ensure "context.Context" is used to propagate RequestID.`)
+}
 
-The golang code below are examples, but you can adapt the concepts to other languages as well.  
-If you can create new files for logging utilities. Find the log library first, and create the necessary logging utility files under the "{{base_path}}/utils/log" directory.
-Do not escape from {{base_path}} .
+func LogPrismPythonPrompt(g *genkit.Genkit) ai.Prompt {
+	return defineLogPrismPrompt(g, LogPrismPythonPromptName, "Python", `Use {{library}} for logging. Use "with" blocks or try/except/finally so spans are completed or failed on every path.`, `4.  **Context**: Propagate RequestID with "contextvars.ContextVar", set at the entry point of each request.`)
+}
 
-## Prism
+func LogPrismTypeScriptPrompt(g *genkit.Genkit) ai.Prompt {
+	return defineLogPrismPrompt(g, LogPrismTypeScriptPromptName, "TypeScript/JavaScript", `Use {{library}} for logging. Use try/catch/finally so spans are completed or failed on every path, including rejected promises.`, `4.  **Context**: Propagate RequestID with "AsyncLocalStorage" from "node:async_hooks" (or the library's context support), set at the entry point of each request.`)
+}
+
+func LogPrismRustPrompt(g *genkit.Genkit) ai.Prompt {
+	return defineLogPrismPrompt(g, LogPrismRustPromptName, "Rust", `Use the {{library}} crate for logging. With tracing, prefer "#[tracing::instrument]" and span fields over manual timing where it fits the Prism format.`, `4.  **Context**: Carry RequestID in the span fields or an explicit context value passed to the functions that need it.`)
+}
+
+// prismConcept describes the Prism log types. The Go code is the reference for every language.
+const prismConcept = `## Prism
 
 I designed three log types:
 - **Span**: Declared at the start of a request, method, or API call, and output with "execution time" at the end.
@@ -118,10 +139,31 @@ Key features:
     - **In-Process**: "ctx" is passed as the first argument when calling functions to share the ID between goroutines.
     - **Cross-Process**: During communication between microservices, the ID is propagated via HTTP Header ("X-Request-ID"), enabling Distributed Tracing.
 3. **Utilization**: All loggers (Span, State, Trend) extract this ID from the Context and include it in the logs. This allows searching the entire flow of a specific request among tens of thousands of logs at once.
+`
+
+// defineLogPrismPrompt builds a Prism prompt for one language from its library guidance and
+// its context propagation instruction.
+func defineLogPrismPrompt(g *genkit.Genkit, name, lang, library, contextInstruction string) ai.Prompt {
+	return genkit.DefinePrompt(g, name, ai.WithPrompt(`You are a Devops expert. Your task is to add "Prism" style logging (Span and State) to the given `+lang+` code based on the concept below.
+`+library+`
+{{#if library_detected}}
+The project already depends on {{library}}. Build on it and do not add another logging library.
+{{else}}
+The project does not depend on a logging library yet. Add {{library}} as its logging library.
+{{/if}}
+
+The Go code below is the reference; adapt the concepts to `+lang+` where needed.
+{{#if prism_api}}
+{{prism_api}}
+{{else}}
+If you need new files for logging utilities, create them under the "{{base_path}}/utils/log" directory.
+{{/if}}
+Do not escape from {{base_path}} .
 
+`+prismConcept+`
 ## Instructions
 
-1.  **Analyze the Code**: Understand the flow of the provided Go code.
+1.  **Analyze the Code**: Understand the flow of the provided `+lang+` code.
 2.  **Add Span Logging**:
     *   Identify functions representing units of work.
     *   Initialize "Span" at the start.
@@ -129,7 +171,7 @@ Key features:
 3.  **Add State Logging**:
     *   Log state changes with "StateLogger.Transition".
     *   Log data snapshots with "StateLogger.Snapshot".
-4.  **Context**: Ensure "context.Context" is used to propagate RequestID.
+`+contextInstruction+`
 
 Base Path: {{base_path}}
 File Path: {{file_path}}
@@ -138,6 +180,12 @@ File Path: {{file_path}}
 
 {{code}}
 
+{{#if line_edits}}
+`+lineEditsInstructions+`
+{{else}}{{#if search_replace}}
+`+searchReplaceInstructions+`
+{{else}}
 Return the FULL source code with logging added. Do not omit any parts of the code.
+{{/if}}{{/if}}
 `), ai.WithInputType(&LogPrismInput{}))
 }
@@ -0,0 +1,55 @@
+package prompts
+
+import (
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+const PrismIncidentPromptName = "PrismIncidentPrompt"
+
+type PrismIncidentInput struct {
+	// Facts is the JSON report computed from the Prism logs.
+	Facts string `json:"facts"`
+	// Focus optionally names what the reader is investigating, such as a service or an alert.
+	Focus string `json:"focus,omitempty"`
+}
+
+type PrismIncidentOutput struct {
+	Title           string   `json:"title"`
+	Summary         string   `json:"summary"`
+	Impact          string   `json:"impact"`
+	Timeline        []string `json:"timeline"`
+	SuspectedCauses []string `json:"suspected_causes"`
+	NextSteps       []string `json:"next_steps"`
+}
+
+// PrismIncidentPrompt asks for an incident report written only from facts computed from
+// Prism logs, so the model never reads the raw logs.
+func PrismIncidentPrompt(g *genkit.Genkit) ai.Prompt {
+	return genkit.DefinePrompt(g, PrismIncidentPromptName, ai.WithPrompt(`You are a site reliability engineer. Write an incident-style report from the facts below,
+which were computed from "Prism" logs of our services.
+
+- Span logs record a request, method or API call with its status ("completed" or "failed") and "duration_ms".
+- State logs record a "transition" of an entity from one state to another, or a "snapshot" of its state.
+- Every log carries a "request_id" that links the logs of one request across services.
+
+The facts contain:
+- "latencies": span latency percentiles per service, scope and method, slowest p95 first.
+- "failures": failed spans grouped by span and error, most frequent first, with sample request IDs.
+- "anomalies": odd state transitions; "noop_transition" moves to the same state, "discontinuous_transition"
+  starts from a state other than the last one within the request, "rare_transition" is rare for its entity.
+- "timelines": the ordered logs of the failed, anomalous and slowest requests.
+{{#if focus}}
+
+Focus on: {{focus}}
+{{/if}}
+
+Only state what the facts support. Quote request IDs, services, methods, errors and numbers exactly.
+When the facts show no failures or anomalies, say so instead of inventing an incident.
+Keep the timeline to the key events in order, each with its time when known.
+Mark causes as suspected and explain which facts point to them.
+
+## Facts
+
+{{facts}}`), ai.WithInputType(&PrismIncidentInput{}))
+}
@@ -11,6 +11,12 @@ type WrapErrorInput struct {
 	Code     string `json:"code"`
 	BasePath string `json:"base_path"`
 	FilePath string `json:"file_path"`
+	// Functions optionally restricts the rewrite to a comma-separated list of functions.
+	Functions string `json:"functions,omitempty"`
+	// LineEdits asks for CodeEditsOutput instead of WrapErrorOutput. Code must be line-numbered.
+	LineEdits bool `json:"line_edits,omitempty"`
+	// SearchReplace asks for SearchReplaceOutput instead of WrapErrorOutput.
+	SearchReplace bool `json:"search_replace,omitempty"`
 }
 
 type WrapErrorOutput struct {
@@ -25,6 +31,10 @@ Use the "return fmt.Errorf(\"context message: %%w\", err)" pattern.
 Do NOT change any other logic.
 Do NOT wrap errors that are already wrapped or created with fmt.Errorf or errors.New.
 Only wrap raw "err" variables being returned.
+{{#if functions}}
+Only modify the following functions, which were changed on this branch: {{functions}}.
+Leave every other function exactly as it is.
+{{/if}}
 
 Base Path: {{base_path}}
 File Path: {{file_path}}
@@ -33,5 +43,107 @@ Here is the code:
 
 {{code}}
 
-Return the FULL source code with the modifications applied. Do not omit any parts of the code.`), ai.WithInputType(&WrapErrorInput{}))
+{{#if line_edits}}
+`+lineEditsInstructions+`
+{{else}}{{#if search_replace}}
+`+searchReplaceInstructions+`
+{{else}}
+Return the FULL source code with the modifications applied. Do not omit any parts of the code.
+{{/if}}{{/if}}`), ai.WithInputType(&WrapErrorInput{}))
+}
+
+const WrapErrorFunctionsPromptName = "WrapErrorFunctionsPrompt"
+
+type WrapErrorFunction struct {
+	// The handlebars tag is needed because templates title-case field names ("id" -> "Id").
+	ID   int    `json:"id" handlebars:"id"`
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+type WrapErrorFunctionsInput struct {
+	Context   string              `json:"context"`
+	Functions []WrapErrorFunction `json:"functions"`
+	BasePath  string              `json:"base_path"`
+	FilePath  string              `json:"file_path"`
+}
+
+type WrapErrorFunctionsOutput struct {
+	Functions []WrapErrorFunction `json:"functions"`
+}
+
+// WrapErrorFunctionsPrompt asks for error wrapping in selected functions only, so large
+// files do not have to be sent and regenerated as a whole.
+func WrapErrorFunctionsPrompt(g *genkit.Genkit) ai.Prompt {
+	return genkit.DefinePrompt(g, WrapErrorFunctionsPromptName, ai.WithPrompt(`You are a Go expert. Your task is to refactor the Go functions below.
+Find all occurrences where an error is returned directly (e.g., "return err", "return nil, err").
+Replace them with "fmt.Errorf" to wrap the error with meaningful context based on the function name and operation being performed.
+Use the "return fmt.Errorf(\"context message: %%w\", err)" pattern.
+Do NOT change any other logic.
+Do NOT wrap errors that are already wrapped or created with fmt.Errorf or errors.New.
+Only wrap raw "err" variables being returned.
+
+Base Path: {{base_path}}
+File Path: {{file_path}}
+
+The functions come from a file with the following package clause, imports and receiver types.
+This context is read-only; the "fmt" import is added automatically when needed.
+
+{{context}}
+
+Here are the functions:
+{{#each functions}}
+### Function {{id}}: {{name}}
+
+{{code}}
+{{/each}}
+
+Return every function with its id and its FULL rewritten declaration, starting at the "func" keyword.
+Do not rename functions, do not add doc comments and do not return any other code.`), ai.WithInputType(&WrapErrorFunctionsInput{}))
+}
+
+const WrapErrorMessagesPromptName = "WrapErrorMessagesPrompt"
+
+type WrapErrorSite struct {
+	// The handlebars tag is needed because templates title-case field names ("id" -> "Id").
+	ID      int    `json:"id" handlebars:"id"`
+	Func    string `json:"func"`
+	Call    string `json:"call,omitempty"`
+	Snippet string `json:"snippet"`
+}
+
+type WrapErrorMessagesInput struct {
+	Sites    []WrapErrorSite `json:"sites"`
+	BasePath string          `json:"base_path"`
+	FilePath string          `json:"file_path"`
+}
+
+type WrapErrorMessage struct {
+	ID      int    `json:"id"`
+	Message string `json:"message"`
+}
+
+type WrapErrorMessagesOutput struct {
+	Messages []WrapErrorMessage `json:"messages"`
+}
+
+// WrapErrorMessagesPrompt asks only for the context messages of error returns that are
+// found and rewritten deterministically.
+func WrapErrorMessagesPrompt(g *genkit.Genkit) ai.Prompt {
+	return genkit.DefinePrompt(g, WrapErrorMessagesPromptName, ai.WithPrompt(`You are a Go expert. The error returns below are going to be wrapped as
+"return fmt.Errorf(\"<message>: %%w\", err)". Your task is to write the message for each of them.
+The message describes the operation that failed, based on the function and the call the error came from,
+such as "failed to read config file". Keep it short and lower-case, without trailing punctuation.
+Use plain text only: no format verbs, no ": %%w" and no text of the error itself.
+
+Base Path: {{base_path}}
+File Path: {{file_path}}
+{{#each sites}}
+
+### Site {{id}} in {{func}}{{#if call}} (error from {{call}}){{/if}}
+
+{{snippet}}
+{{/each}}
+
+Return one message for every site, with its id.`), ai.WithInputType(&WrapErrorMessagesInput{}))
 }
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/patch"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
+)
+
+const ApplyPatchTool = "ApplyPatch"
+
+// ApplyPatchInput defines the input for the ApplyPatch tool.
+type ApplyPatchInput struct {
+	Path string `json:"path"`
+	// Patch holds SEARCH/REPLACE blocks in text form. It is used when Blocks is empty.
+	Patch  string        `json:"patch,omitempty"`
+	Blocks []patch.Block `json:"blocks,omitempty"`
+}
+
+// ApplyPatchOutput defines the output for the ApplyPatch tool.
+type ApplyPatchOutput struct {
+	Success bool           `json:"success"`
+	Results []patch.Result `json:"results,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// ApplyPatch creates a tool to apply search/replace blocks to a file.
+func ApplyPatch(g *genkit.Genkit) ai.Tool {
+	description := `Applies search/replace blocks to a file at the specified path.
+Each block's search text must be copied from the file and match exactly one place; it is located exactly first, then ignoring whitespace, then fuzzily.
+Blocks are given either as "blocks" or as "patch" text in this form:
+<<<<<<< SEARCH
+old lines
+=======
+new lines
+>>>>>>> REPLACE
+The file is only written when every block applies. The results report how and with what confidence each block matched.`
+
+	return genkit.DefineTool(g, ApplyPatchTool, description, func(ctx *ai.ToolContext, input ApplyPatchInput) (ApplyPatchOutput, error) {
+		path, err := workspace.Write(ctx, input.Path)
+		if err != nil {
+			return ApplyPatchOutput{Success: false, Error: err.Error()}, nil
+		}
+
+		blocks := input.Blocks
+		if len(blocks) == 0 {
+			blocks, err = patch.Parse(input.Patch)
+			if err != nil {
+				return ApplyPatchOutput{Success: false, Error: fmt.Sprintf("failed to parse patch: %v", err)}, nil
+			}
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return ApplyPatchOutput{Success: false}, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		// Mismatched blocks are reported back so the model can correct them.
+		newContent, results, err := patch.Apply(string(content), blocks, patch.Options{})
+		if err != nil {
+			return ApplyPatchOutput{Success: false, Results: results, Error: err.Error()}, nil
+		}
+
+		if err := journal.WriteFile(ctx, path, []byte(newContent), 0644); err != nil {
+			return ApplyPatchOutput{Success: false}, fmt.Errorf("failed to write file: %w", err)
+		}
+		return ApplyPatchOutput{Success: true, Results: results}, nil
+	})
+}
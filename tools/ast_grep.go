@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/snowmerak/useful-genkit/utils/astgrep"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
+)
+
+// searchRoot returns the directory or file a code search tool covers: basePath, or the
+// workspace root or current directory when it is empty, checked against the workspace.
+func searchRoot(ctx context.Context, basePath string) (string, error) {
+	if basePath == "" {
+		if w := workspace.FromContext(ctx); w != nil {
+			return w.Root, nil
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get current directory: %w", err)
+		}
+		return wd, nil
+	}
+	return workspace.Read(ctx, basePath)
+}
+
+// scanCode runs ast-grep rules over basePath, leaving out matches in files the workspace
+// does not allow reading. A failure, like ast-grep not being installed, is returned as a
+// message for the model.
+func scanCode(ctx context.Context, basePath string, configs ...astgrep.Config) ([]astgrep.Match, string) {
+	root, err := searchRoot(ctx, basePath)
+	if err != nil {
+		return nil, err.Error()
+	}
+	matches, err := astgrep.Scan(ctx, []string{root}, configs...)
+	if errors.Is(err, astgrep.ErrNotInstalled) {
+		return nil, err.Error()
+	}
+	if err != nil {
+		return nil, fmt.Sprintf("Error running ast-grep: %v", err)
+	}
+	matches = slices.DeleteFunc(matches, func(m astgrep.Match) bool {
+		_, err := workspace.Read(ctx, m.File)
+		return err != nil
+	})
+	return matches, ""
+}
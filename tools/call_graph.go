@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/utils/gocode"
+)
+
+const CallGraphTool = "CallGraph"
+
+const (
+	defaultCallGraphDepth = 2
+	maxCallGraphDepth     = 6
+	// maxCallGraphLines caps the printed tree; hubs like logging helpers have many callers.
+	maxCallGraphLines = 300
+)
+
+type CallGraphDirection string
+
+const (
+	CallGraphCallers CallGraphDirection = "callers"
+	CallGraphCallees CallGraphDirection = "callees"
+	CallGraphBoth    CallGraphDirection = "both"
+)
+
+type CallGraphInput struct {
+	// Function is a Go function or method, like "WriteFile", "journal.WriteFile" or "(*Journal).WriteFile".
+	Function string `json:"function"`
+	BasePath string `json:"base_path"`
+	// Direction is "callers", "callees" or "both", the default.
+	Direction CallGraphDirection `json:"direction,omitempty"`
+	// Depth is how many calls away to follow. Defaults to 2, at most 6.
+	Depth int `json:"depth,omitempty"`
+}
+
+type CallGraphOutput struct {
+	Result string `json:"result"`
+}
+
+func CallGraph(g *genkit.Genkit) ai.Tool {
+	description := `Shows who calls a Go function or method and what it calls, directly and transitively up to "depth" calls away, as a tree with the file and line of each call.
+Calls through interfaces are resolved to every method of the codebase that could be called (class hierarchy analysis) and marked dynamic. Calls through function values, like callbacks, are not shown; function literals count as part of the function declaring them. Functions outside the codebase are marked external and not followed.
+Use it to see where context.Context or an error has to be passed through, or what a change could affect.`
+
+	return genkit.DefineTool(g, CallGraphTool, description, func(ctx *ai.ToolContext, input CallGraphInput) (CallGraphOutput, error) {
+		if input.Function == "" {
+			return CallGraphOutput{Result: "function is required"}, nil
+		}
+		direction := input.Direction
+		switch direction {
+		case "":
+			direction = CallGraphBoth
+		case CallGraphCallers, CallGraphCallees, CallGraphBoth:
+		default:
+			return CallGraphOutput{Result: fmt.Sprintf("Unknown direction %q; use callers, callees or both.", direction)}, nil
+		}
+		depth := limit(input.Depth, defaultCallGraphDepth, maxCallGraphDepth)
+
+		program, msg := loadGoProgram(ctx, input.BasePath)
+		if program == nil {
+			return CallGraphOutput{Result: msg}, nil
+		}
+		funcs := readableGo(ctx, program, program.Lookup(input.Function), func(f *gocode.Func) gocode.Location { return f.Location })
+		if len(funcs) == 0 {
+			return CallGraphOutput{Result: fmt.Sprintf("No function or method named %s found.", input.Function)}, nil
+		}
+
+		w := &callTreeWriter{readable: func(file string) bool { return readableGoFile(ctx, program, file) }}
+		for _, f := range funcs {
+			if direction != CallGraphCallees {
+				fmt.Fprintf(&w.b, "Callers of %s (%s:%d):\n", f.Name, f.File, f.Line)
+				w.write(f, depth, true)
+				w.b.WriteString("\n")
+			}
+			if direction != CallGraphCallers {
+				fmt.Fprintf(&w.b, "Callees of %s (%s:%d):\n", f.Name, f.File, f.Line)
+				w.write(f, depth, false)
+				w.b.WriteString("\n")
+			}
+		}
+		if w.truncated {
+			fmt.Fprintf(&w.b, "Output stopped after %d lines; lower depth or pick a direction to see the rest.\n", maxCallGraphLines)
+		}
+		return CallGraphOutput{Result: w.b.String()}, nil
+	})
+}
+
+// callTreeWriter prints a call tree, expanding every function once.
+type callTreeWriter struct {
+	// readable reports whether calls in and into file may be shown.
+	readable  func(file string) bool
+	b         strings.Builder
+	lines     int
+	truncated bool
+}
+
+func (w *callTreeWriter) write(root *gocode.Func, depth int, callers bool) {
+	expanded := map[*gocode.Func]bool{root: true}
+	var walk func(f *gocode.Func, level int)
+	walk = func(f *gocode.Func, level int) {
+		calls := f.Callees
+		if callers {
+			calls = f.Callers
+		}
+		calls = slices.DeleteFunc(slices.Clone(calls), func(call *gocode.Call) bool {
+			return !w.readable(call.Caller.File) || !w.readable(call.Callee.File)
+		})
+		if level == 1 && len(calls) == 0 {
+			w.line(1, "(none)")
+		}
+		for _, group := range groupCalls(calls, callers) {
+			other := group[0].Callee
+			if callers {
+				other = group[0].Caller
+			}
+			sites := make([]string, len(group))
+			for i, call := range group {
+				sites[i] = fmt.Sprintf("%s:%d", call.Site.File, call.Site.Line)
+			}
+			var notes []string
+			if group[0].Dynamic {
+				notes = append(notes, "dynamic")
+			}
+			if other.External {
+				notes = append(notes, "external")
+			}
+			if level < depth && expanded[other] && other != root {
+				notes = append(notes, "expanded above")
+			}
+			text := fmt.Sprintf("%s at %s", other.Name, strings.Join(sites, ", "))
+			if len(notes) > 0 {
+				text += " [" + strings.Join(notes, ", ") + "]"
+			}
+			if !w.line(level, text) {
+				return
+			}
+			if level < depth && !expanded[other] && !other.External {
+				expanded[other] = true
+				walk(other, level+1)
+			}
+		}
+	}
+	walk(root, 1)
+}
+
+// line writes text indented by level, reporting false once the output is full.
+func (w *callTreeWriter) line(level int, text string) bool {
+	if w.lines >= maxCallGraphLines {
+		w.truncated = true
+		return false
+	}
+	w.lines++
+	fmt.Fprintf(&w.b, "%s%s\n", strings.Repeat("  ", level), text)
+	return true
+}
+
+// groupCalls groups calls by the function at the other end, keeping their order.
+func groupCalls(calls []*gocode.Call, callers bool) [][]*gocode.Call {
+	var groups [][]*gocode.Call
+	index := make(map[*gocode.Func]int)
+	for _, call := range calls {
+		other := call.Callee
+		if callers {
+			other = call.Caller
+		}
+		i, ok := index[other]
+		if !ok {
+			i = len(groups)
+			index[other] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], call)
+	}
+	return groups
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
 )
 
 const (
@@ -24,19 +26,29 @@ type ListFilesInput struct {
 
 // ListFilesOutput defines the output for the ListFiles tool.
 type ListFilesOutput struct {
-	Files []string `json:"files"`
+	Files []string `json:"files,omitempty"`
+	Error string   `json:"error,omitempty"`
 }
 
 // ListFiles creates a tool to list files in a directory.
 func ListFiles(g *genkit.Genkit) ai.Tool {
 	return genkit.DefineTool(g, ListFilesTool, "Lists files and directories in the specified path.", func(ctx *ai.ToolContext, input ListFilesInput) (ListFilesOutput, error) {
-		entries, err := os.ReadDir(input.Path)
+		path, err := workspace.Read(ctx, input.Path)
+		if err != nil {
+			return ListFilesOutput{Error: err.Error()}, nil
+		}
+
+		entries, err := os.ReadDir(path)
 		if err != nil {
 			return ListFilesOutput{}, fmt.Errorf("failed to read directory: %w", err)
 		}
 
 		files := make([]string, 0, len(entries))
 		for _, entry := range entries {
+			// Denied entries are hidden.
+			if _, err := workspace.Read(ctx, filepath.Join(path, entry.Name())); err != nil {
+				continue
+			}
 			name := entry.Name()
 			if entry.IsDir() {
 				name += "/"
@@ -55,13 +67,19 @@ type CreateDirectoryInput struct {
 
 // CreateDirectoryOutput defines the output for the CreateDirectory tool.
 type CreateDirectoryOutput struct {
-	Success bool `json:"success"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
 }
 
 // CreateDirectory creates a tool to create a new directory.
 func CreateDirectory(g *genkit.Genkit) ai.Tool {
 	return genkit.DefineTool(g, CreateDirectoryTool, "Creates a new directory at the specified path.", func(ctx *ai.ToolContext, input CreateDirectoryInput) (CreateDirectoryOutput, error) {
-		if err := os.MkdirAll(input.Path, 0755); err != nil {
+		path, err := workspace.Write(ctx, input.Path)
+		if err != nil {
+			return CreateDirectoryOutput{Success: false, Error: err.Error()}, nil
+		}
+
+		if err := journal.MkdirAll(ctx, path, 0755); err != nil {
 			return CreateDirectoryOutput{Success: false}, fmt.Errorf("failed to create directory: %w", err)
 		}
 		return CreateDirectoryOutput{Success: true}, nil
@@ -75,7 +93,8 @@ type DeleteDirectoryInput struct {
 
 // DeleteDirectoryOutput defines the output for the DeleteDirectory tool.
 type DeleteDirectoryOutput struct {
-	Success bool `json:"success"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
 }
 
 // DeleteDirectory creates a tool to delete a directory.
@@ -86,8 +105,13 @@ func DeleteDirectory(g *genkit.Genkit) ai.Tool {
 			return DeleteDirectoryOutput{Success: false}, fmt.Errorf("cannot delete root or current directory")
 		}
 
+		path, err := workspace.Remove(ctx, input.Path)
+		if err != nil {
+			return DeleteDirectoryOutput{Success: false, Error: err.Error()}, nil
+		}
+
 		// Check if it is a directory
-		info, err := os.Stat(input.Path)
+		info, err := os.Stat(path)
 		if err != nil {
 			return DeleteDirectoryOutput{Success: false}, fmt.Errorf("failed to stat path: %w", err)
 		}
@@ -95,7 +119,7 @@ func DeleteDirectory(g *genkit.Genkit) ai.Tool {
 			return DeleteDirectoryOutput{Success: false}, fmt.Errorf("path is not a directory")
 		}
 
-		if err := os.RemoveAll(input.Path); err != nil {
+		if err := journal.RemoveAll(ctx, path); err != nil {
 			return DeleteDirectoryOutput{Success: false}, fmt.Errorf("failed to remove directory: %w", err)
 		}
 		return DeleteDirectoryOutput{Success: true}, nil
@@ -112,7 +136,11 @@ type GetCurrentDirectoryOutput struct {
 
 // GetCurrentDirectory creates a tool to get the current working directory.
 func GetCurrentDirectory(g *genkit.Genkit) ai.Tool {
-	return genkit.DefineTool(g, GetCurrentDirectoryTool, "Gets the current working directory.", func(ctx *ai.ToolContext, _ GetCurrentDirectoryInput) (GetCurrentDirectoryOutput, error) {
+	return genkit.DefineTool(g, GetCurrentDirectoryTool, "Gets the current working directory, which is the workspace root when file access is confined to one.", func(ctx *ai.ToolContext, _ GetCurrentDirectoryInput) (GetCurrentDirectoryOutput, error) {
+		if w := workspace.FromContext(ctx); w != nil {
+			return GetCurrentDirectoryOutput{Path: w.Root}, nil
+		}
+
 		dir, err := os.Getwd()
 		if err != nil {
 			return GetCurrentDirectoryOutput{}, fmt.Errorf("failed to get current directory: %w", err)
@@ -128,17 +156,30 @@ type WalkDirectoryInput struct {
 
 // WalkDirectoryOutput defines the output for the WalkDirectory tool.
 type WalkDirectoryOutput struct {
-	Files []string `json:"files"`
+	Files []string `json:"files,omitempty"`
+	Error string   `json:"error,omitempty"`
 }
 
 // WalkDirectory creates a tool to recursively list all files in a directory.
 func WalkDirectory(g *genkit.Genkit) ai.Tool {
 	return genkit.DefineTool(g, WalkDirectoryTool, "Recursively lists all files in the specified directory.", func(ctx *ai.ToolContext, input WalkDirectoryInput) (WalkDirectoryOutput, error) {
+		root, err := workspace.Read(ctx, input.Path)
+		if err != nil {
+			return WalkDirectoryOutput{Error: err.Error()}, nil
+		}
+
 		var files []string
-		err := filepath.Walk(input.Path, func(path string, info os.FileInfo, err error) error {
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
+			// Denied paths are hidden.
+			if _, err := workspace.Read(ctx, path); err != nil {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			if !info.IsDir() {
 				files = append(files, path)
 			}
@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	fileutil "github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
+)
+
+const EditFileTool = "EditFile"
+
+const (
+	// editRegionContext is the number of unchanged lines shown around each edit.
+	editRegionContext = 2
+	// maxEditRegionLines caps the lines of the returned region.
+	maxEditRegionLines = 200
+)
+
+// EditFileInput defines the input for the EditFile tool.
+type EditFileInput struct {
+	Path string `json:"path"`
+	// Hash is the hash ReadFile or ReadFileLines returned for the content the edits refer to.
+	Hash  string          `json:"hash"`
+	Edits []fileutil.Edit `json:"edits"`
+}
+
+// EditFileOutput defines the output for the EditFile tool.
+type EditFileOutput struct {
+	Success bool `json:"success"`
+	// Hash identifies the edited file for further edits.
+	Hash string `json:"hash,omitempty"`
+	// Region shows the edited lines of the new file with line numbers and some context.
+	Region string `json:"region,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EditFile creates a tool to apply line edits to a file.
+func EditFile(g *genkit.Genkit) ai.Tool {
+	description := `Applies a batch of line edits to a file at the specified path.
+Edits use the 1-based line numbers of the file as read with ReadFile or ReadFileLines, and "hash" must be the hash that read returned; when the file changed since, nothing is written and it has to be read again.
+Operations: {"op":"insert_after","start":N,"text":...} inserts after line N (0 inserts at the top), {"op":"replace","start":N,"end":M,"text":...} replaces lines N to M, {"op":"remove","start":N,"end":M} removes lines N to M. "end" defaults to "start".
+All line numbers refer to the file before the batch, so edits must not overlap. Either every edit is applied or none.
+The result shows the edited region of the new file with line numbers, and the new hash for further edits.`
+
+	return genkit.DefineTool(g, EditFileTool, description, func(ctx *ai.ToolContext, input EditFileInput) (EditFileOutput, error) {
+		path, err := workspace.Write(ctx, input.Path)
+		if err != nil {
+			return EditFileOutput{Success: false, Error: err.Error()}, nil
+		}
+		if input.Hash == "" {
+			return EditFileOutput{Success: false, Error: "hash is required: read the file with ReadFile or ReadFileLines first"}, nil
+		}
+		if len(input.Edits) == 0 {
+			return EditFileOutput{Success: false, Error: "no edits given"}, nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return EditFileOutput{Success: false}, fmt.Errorf("failed to read file: %w", err)
+		}
+		if fileutil.Hash(content) != input.Hash {
+			return EditFileOutput{Success: false, Error: "the file changed since it was read: read it again and redo the edits against the new line numbers"}, nil
+		}
+		if fileutil.IsBinary(content) {
+			return EditFileOutput{Success: false, Error: fmt.Sprintf("%s is a binary file", input.Path)}, nil
+		}
+
+		// Invalid edits are reported back so the model can correct them.
+		newContent, err := fileutil.ApplyEdits(content, input.Edits)
+		if err != nil {
+			return EditFileOutput{Success: false, Error: err.Error()}, nil
+		}
+
+		if err := journal.WriteFile(ctx, path, newContent, 0644); err != nil {
+			return EditFileOutput{Success: false}, fmt.Errorf("failed to write file: %w", err)
+		}
+		return EditFileOutput{
+			Success: true,
+			Hash:    fileutil.Hash(newContent),
+			Region:  editRegion(fileutil.SplitLines(newContent), input.Edits),
+		}, nil
+	})
+}
+
+// lineSpan is a range of 1-based lines; End < Start for an empty span between lines.
+type lineSpan struct {
+	Start, End int
+}
+
+// editedSpans maps validated edits, which refer to the original lines, to the lines they
+// produced in the edited content, in order.
+func editedSpans(edits []fileutil.Edit) []lineSpan {
+	// Insertions after line N come after a replacement ending at N, as in ApplyEdits.
+	order := func(e fileutil.Edit) int {
+		if e.Op == fileutil.EditInsertAfter {
+			return 2*e.Start + 1
+		}
+		return 2 * e.Start
+	}
+	sorted := slices.Clone(edits)
+	slices.SortStableFunc(sorted, func(a, b fileutil.Edit) int { return order(a) - order(b) })
+
+	var spans []lineSpan
+	delta := 0
+	for _, e := range sorted {
+		n := len(fileutil.SplitLines([]byte(e.Text)))
+		if e.Text == "" {
+			n = 1
+		}
+		end := e.End
+		if end == 0 {
+			end = e.Start
+		}
+		switch e.Op {
+		case fileutil.EditInsertAfter:
+			spans = append(spans, lineSpan{e.Start + delta + 1, e.Start + delta + n})
+			delta += n
+		case fileutil.EditReplace:
+			spans = append(spans, lineSpan{e.Start + delta, e.Start + delta + n - 1})
+			delta += n - (end - e.Start + 1)
+		case fileutil.EditRemove:
+			spans = append(spans, lineSpan{e.Start + delta, e.Start + delta - 1})
+			delta -= end - e.Start + 1
+		}
+	}
+	return spans
+}
+
+// editRegion renders the edited lines with context, merging nearby edits.
+func editRegion(lines []string, edits []fileutil.Edit) string {
+	var merged []lineSpan
+	for _, s := range editedSpans(edits) {
+		s.Start = max(min(s.Start, s.End+1)-editRegionContext, 1)
+		s.End = min(max(s.End, s.Start)+editRegionContext, len(lines))
+		if n := len(merged); n > 0 && s.Start <= merged[n-1].End+1 {
+			merged[n-1].End = max(merged[n-1].End, s.End)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	var buf strings.Builder
+	shown := 0
+	for i, s := range merged {
+		if s.End < s.Start {
+			continue
+		}
+		if i > 0 {
+			buf.WriteString("     ...\n")
+		}
+		if shown+s.End-s.Start+1 > maxEditRegionLines {
+			s.End = s.Start + maxEditRegionLines - shown - 1
+			buf.Write(fileutil.AttachLineNumbersFrom(lines[s.Start-1:s.End], s.Start))
+			buf.WriteString("     ... (region truncated)\n")
+			break
+		}
+		buf.Write(fileutil.AttachLineNumbersFrom(lines[s.Start-1:s.End], s.Start))
+		shown += s.End - s.Start + 1
+	}
+	return buf.String()
+}
@@ -1,17 +1,25 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
+	fileutil "github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/journal"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
 )
 
 const (
-	ReadFileTool  = "ReadFile"
-	WriteFileTool = "WriteFile"
+	ReadFileTool      = "ReadFile"
+	ReadFileLinesTool = "ReadFileLines"
+	WriteFileTool     = "WriteFile"
 )
 
 // ReadFileInput defines the input for the ReadFile tool.
@@ -22,19 +30,146 @@ type ReadFileInput struct {
 // ReadFileOutput defines the output for the ReadFile tool.
 type ReadFileOutput struct {
 	Content string `json:"content"`
+	// Hash identifies this version of the file for EditFile.
+	Hash  string `json:"hash,omitempty"`
+	Error string `json:"error,omitempty"`
 }
 
 // ReadFile creates a tool to read the content of a file.
 func ReadFile(g *genkit.Genkit) ai.Tool {
-	return genkit.DefineTool(g, ReadFileTool, "Reads the content of a file at the specified path.", func(ctx *ai.ToolContext, input ReadFileInput) (ReadFileOutput, error) {
-		content, err := os.ReadFile(input.Path)
+	return genkit.DefineTool(g, ReadFileTool, "Reads the whole content of a file at the specified path. Use ReadFileLines for large files.", func(ctx *ai.ToolContext, input ReadFileInput) (ReadFileOutput, error) {
+		path, err := workspace.Read(ctx, input.Path)
+		if err != nil {
+			return ReadFileOutput{Error: err.Error()}, nil
+		}
+
+		content, err := os.ReadFile(path)
 		if err != nil {
 			return ReadFileOutput{}, fmt.Errorf("failed to read file: %w", err)
 		}
-		return ReadFileOutput{Content: string(content)}, nil
+		return ReadFileOutput{Content: string(content), Hash: fileutil.Hash(content)}, nil
+	})
+}
+
+const (
+	defaultReadMaxLines = 400
+	maxReadMaxLines     = 2000
+	defaultReadMaxBytes = 32 << 10
+	maxReadMaxBytes     = 256 << 10
+)
+
+// ReadFileLinesInput defines the input for the ReadFileLines tool.
+type ReadFileLinesInput struct {
+	Path string `json:"path"`
+	// StartLine is the first line to read, 1-based. Defaults to 1.
+	StartLine int `json:"start_line,omitempty"`
+	// EndLine is the last line to read, inclusive. Defaults to reading as much as the limits allow.
+	EndLine int `json:"end_line,omitempty"`
+	// MaxLines caps the lines returned. Defaults to 400, at most 2000.
+	MaxLines int `json:"max_lines,omitempty"`
+	// MaxBytes caps the bytes of content returned. Defaults to 32 KiB, at most 256 KiB.
+	MaxBytes int `json:"max_bytes,omitempty"`
+}
+
+// ReadFileLinesOutput defines the output for the ReadFileLines tool.
+type ReadFileLinesOutput struct {
+	// Content holds the lines prefixed with their line numbers.
+	Content    string `json:"content"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	TotalLines int    `json:"total_lines"`
+	// Hash identifies this version of the whole file for EditFile.
+	Hash string `json:"hash"`
+	// Truncated reports that the limits stopped reading before EndLine or the end of the file.
+	Truncated bool `json:"truncated,omitempty"`
+	// Next tells how to read the rest when Truncated is set.
+	Next  string `json:"next,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ReadFileLines creates a tool to read a range of lines of a file with line numbers.
+func ReadFileLines(g *genkit.Genkit) ai.Tool {
+	description := `Reads lines of a text file at the specified path, each prefixed with its line number ("  12: code").
+Use start_line and end_line to read a range; output is capped by max_lines and max_bytes, and when it is truncated "next" tells which call reads on.
+total_lines is the length of the whole file and hash identifies its version for EditFile. Binary files are refused.`
+
+	return genkit.DefineTool(g, ReadFileLinesTool, description, func(ctx *ai.ToolContext, input ReadFileLinesInput) (ReadFileLinesOutput, error) {
+		path, err := workspace.Read(ctx, input.Path)
+		if err != nil {
+			return ReadFileLinesOutput{Error: err.Error()}, nil
+		}
+
+		start := max(input.StartLine, 1)
+		if input.EndLine != 0 && input.EndLine < start {
+			return ReadFileLinesOutput{Error: fmt.Sprintf("end_line %d is before start_line %d", input.EndLine, start)}, nil
+		}
+		maxLines := limit(input.MaxLines, defaultReadMaxLines, maxReadMaxLines)
+		maxBytes := limit(input.MaxBytes, defaultReadMaxBytes, maxReadMaxBytes)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return ReadFileLinesOutput{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		if fileutil.IsBinary(content) {
+			return ReadFileLinesOutput{Error: fmt.Sprintf("%s is a binary file", input.Path)}, nil
+		}
+
+		var lines []string
+		size := 0
+		total := 0
+		truncated := false
+		reader := bufio.NewReader(bytes.NewReader(content))
+		for {
+			line, err := reader.ReadString('\n')
+			if line == "" && err != nil {
+				if err == io.EOF {
+					break
+				}
+				return ReadFileLinesOutput{}, fmt.Errorf("failed to read file: %w", err)
+			}
+			total++
+			if total < start || (input.EndLine != 0 && total > input.EndLine) || truncated {
+				continue
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if len(lines) == maxLines || size+len(line) > maxBytes && len(lines) > 0 {
+				truncated = true
+				continue
+			}
+			line = fileutil.TruncateLine(line, maxBytes)
+			lines = append(lines, line)
+			size += len(line) + 1
+		}
+
+		output := ReadFileLinesOutput{
+			Content:    string(fileutil.AttachLineNumbersFrom(lines, start)),
+			StartLine:  start,
+			EndLine:    start + len(lines) - 1,
+			TotalLines: total,
+			Hash:       fileutil.Hash(content),
+			Truncated:  truncated,
+		}
+		if len(lines) == 0 {
+			output.EndLine = 0
+			if total > 0 {
+				output.Error = fmt.Sprintf("start_line %d is past the end of the file (%d lines)", start, total)
+			}
+		}
+		if truncated {
+			output.Next = fmt.Sprintf("Call %s with start_line %d to read on.", ReadFileLinesTool, output.EndLine+1)
+		}
+		return output, nil
 	})
 }
 
+// limit applies a default to an unset value and caps it.
+func limit(value, def, maximum int) int {
+	if value <= 0 {
+		return def
+	}
+	return min(value, maximum)
+}
+
 // WriteFileInput defines the input for the WriteFile tool.
 type WriteFileInput struct {
 	Path    string `json:"path"`
@@ -43,17 +178,23 @@ type WriteFileInput struct {
 
 // WriteFileOutput defines the output for the WriteFile tool.
 type WriteFileOutput struct {
-	Success bool `json:"success"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
 }
 
 // WriteFile creates a tool to write content to a file.
 func WriteFile(g *genkit.Genkit) ai.Tool {
-	return genkit.DefineTool(g, WriteFileTool, "Writes content to a file at the specified path. Overwrites existing content.", func(ctx *ai.ToolContext, input WriteFileInput) (WriteFileOutput, error) {
-		if err := os.MkdirAll(filepath.Dir(input.Path), 0755); err != nil {
+	return genkit.DefineTool(g, WriteFileTool, "Writes content to a file at the specified path. Overwrites existing content and keeps its file mode.", func(ctx *ai.ToolContext, input WriteFileInput) (WriteFileOutput, error) {
+		path, err := workspace.Write(ctx, input.Path)
+		if err != nil {
+			return WriteFileOutput{Success: false, Error: err.Error()}, nil
+		}
+
+		if err := journal.MkdirAll(ctx, filepath.Dir(path), 0755); err != nil {
 			return WriteFileOutput{Success: false}, fmt.Errorf("failed to create directories: %w", err)
 		}
 
-		if err := os.WriteFile(input.Path, []byte(input.Content), 0644); err != nil {
+		if err := journal.WriteFile(ctx, path, []byte(input.Content), 0644); err != nil {
 			return WriteFileOutput{Success: false}, fmt.Errorf("failed to write file: %w", err)
 		}
 		return WriteFileOutput{Success: true}, nil
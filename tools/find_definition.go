@@ -1,13 +1,13 @@
 package tools
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
+	"regexp"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/utils/astgrep"
+	"github.com/snowmerak/useful-genkit/utils/gocode"
 	"github.com/snowmerak/useful-genkit/utils/language"
 )
 
@@ -15,7 +15,7 @@ const FindDefinitionTool = "FindDefinition"
 
 type FindDefinitionInput struct {
 	Query    string            `json:"query"`
-	Language language.Language `json:"language"`
+	Language language.Language `json:"language,omitempty"`
 	BasePath string            `json:"base_path"`
 }
 
@@ -23,73 +23,81 @@ type FindDefinitionOutput struct {
 	Result string `json:"result"`
 }
 
-func FindDefinition(g *genkit.Genkit) ai.Tool {
-	return genkit.DefineTool(g, FindDefinitionTool, "Finds the definition of a symbol (function, method, or type) in the codebase using ast-grep.", func(ctx *ai.ToolContext, input FindDefinitionInput) (FindDefinitionOutput, error) {
-		// Create a temporary rule file
-		ruleContent := fmt.Sprintf(`id: find-definition
-language: %s
-rule:
-  any:
-    - kind: function_declaration
-      has:
-        field: name
-        regex: ^%s$
-    - kind: method_declaration
-      has:
-        field: name
-        regex: ^%s$
-    - kind: type_spec
-      has:
-        field: name
-        regex: ^%s$
-`, input.Language, input.Query, input.Query, input.Query)
-
-		tmpFile, err := os.CreateTemp("", "find_definition_*.yml")
-		if err != nil {
-			return FindDefinitionOutput{}, fmt.Errorf("failed to create temp file: %w", err)
-		}
-		defer os.Remove(tmpFile.Name())
+// definitionKinds are the declarations with a name field that FindDefinition looks for.
+var definitionKinds = map[language.Language][]string{
+	language.Python:     {"function_definition", "class_definition"},
+	language.JavaScript: {"function_declaration", "generator_function_declaration", "method_definition", "class_declaration"},
+	language.TypeScript: {"function_declaration", "generator_function_declaration", "method_definition", "class_declaration", "abstract_class_declaration", "interface_declaration", "type_alias_declaration", "enum_declaration"},
+	language.Tsx:        {"function_declaration", "generator_function_declaration", "method_definition", "class_declaration", "abstract_class_declaration", "interface_declaration", "type_alias_declaration", "enum_declaration"},
+	language.Java:       {"class_declaration", "interface_declaration", "enum_declaration", "record_declaration", "method_declaration", "constructor_declaration"},
+	language.Rust:       {"function_item", "struct_item", "enum_item", "union_item", "trait_item", "type_item"},
+	language.CSharp:     {"class_declaration", "struct_declaration", "interface_declaration", "record_declaration", "enum_declaration", "method_declaration"},
+	language.Ruby:       {"method", "singleton_method", "class", "module"},
+	language.Php:        {"function_definition", "method_declaration", "class_declaration", "interface_declaration", "trait_declaration"},
+	language.Swift:      {"class_declaration", "protocol_declaration", "function_declaration"},
+	language.Scala:      {"function_definition", "class_definition", "object_definition", "trait_definition"},
+	language.Lua:        {"function_declaration"},
+}
 
-		if _, err := tmpFile.WriteString(ruleContent); err != nil {
-			return FindDefinitionOutput{}, fmt.Errorf("failed to write to temp file: %w", err)
+// definitionRule matches the definitions of name in lang.
+func definitionRule(lang language.Language, name string) (astgrep.Rule, bool) {
+	switch lang {
+	case language.C, language.Cpp:
+		// The name of a C function is nested in its declarator, behind pointers and, in
+		// C++, a class qualifier.
+		rules := []astgrep.Rule{
+			astgrep.Kind("function_definition").WithHas(astgrep.Rule{Field: "declarator", Regex: `(^|\W)` + regexp.QuoteMeta(name) + `\s*\(`}),
+			astgrep.Kind("type_definition").WithHas(astgrep.Rule{Field: "declarator", Regex: astgrep.Exact(name)}),
+			astgrep.All(astgrep.Named("struct_specifier", name), astgrep.Kind("struct_specifier").WithHas(astgrep.Kind("field_declaration_list").InField("body"))),
+			astgrep.All(astgrep.Named("union_specifier", name), astgrep.Kind("union_specifier").WithHas(astgrep.Kind("field_declaration_list").InField("body"))),
+			astgrep.All(astgrep.Named("enum_specifier", name), astgrep.Kind("enum_specifier").WithHas(astgrep.Kind("enumerator_list").InField("body"))),
 		}
-		if err := tmpFile.Close(); err != nil {
-			return FindDefinitionOutput{}, fmt.Errorf("failed to close temp file: %w", err)
+		if lang == language.Cpp {
+			rules = append(rules, astgrep.All(astgrep.Named("class_specifier", name), astgrep.Kind("class_specifier").WithHas(astgrep.Kind("field_declaration_list").InField("body"))))
 		}
+		return astgrep.Any(rules...), true
+	}
+
+	kinds, ok := definitionKinds[lang]
+	if !ok {
+		return astgrep.Rule{}, false
+	}
+	rules := make([]astgrep.Rule, len(kinds))
+	for i, kind := range kinds {
+		rules[i] = astgrep.Named(kind, name)
+	}
+	return astgrep.Any(rules...), true
+}
 
-		// Run ast-grep
-		args := []string{"scan", "--json", "-r", tmpFile.Name()}
-		if input.BasePath != "" {
-			args = append(args, input.BasePath)
+func FindDefinition(g *genkit.Genkit) ai.Tool {
+	return genkit.DefineTool(g, FindDefinitionTool, `Finds the definition of a symbol (function, method, or type) in the codebase.
+For Go, the packages under base_path are type-checked, and query may be qualified: "WriteFile", "journal.WriteFile", "Workspace.Read" or "(*Workspace).Read"; fields and constants are found too. Other languages use ast-grep and match the plain name. Without a language, the most common supported language under base_path is searched.`, func(ctx *ai.ToolContext, input FindDefinitionInput) (FindDefinitionOutput, error) {
+		lang, msg := resolveLanguage(ctx, input.Language, input.BasePath, language.FindDefinition)
+		if msg != "" {
+			return FindDefinitionOutput{Result: msg}, nil
 		}
-		cmd := exec.Command("sg", args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			// If output is empty, it's a real error. If not, it might just be exit code 1 for no matches or similar (though sg usually returns 0).
-			if len(output) == 0 {
-				return FindDefinitionOutput{Result: fmt.Sprintf("Error running ast-grep: %v", err)}, nil
+		input.Language = lang
+
+		if input.Language == language.Go {
+			program, msg := loadGoProgram(ctx, input.BasePath)
+			if program == nil {
+				return FindDefinitionOutput{Result: msg}, nil
 			}
+			symbols := readableGo(ctx, program, program.Definitions(input.Query), func(s gocode.Symbol) gocode.Location { return s.Location })
+			if len(symbols) == 0 {
+				return FindDefinitionOutput{Result: "No definitions found."}, nil
+			}
+			return FindDefinitionOutput{Result: formatGoDefinitions(symbols)}, nil
 		}
 
-		// Parse JSON output to make it more readable or just return as string
-		type SgMatch struct {
-			Text  string `json:"text"`
-			File  string `json:"file"`
-			Range struct {
-				Start struct {
-					Line int `json:"line"`
-				} `json:"start"`
-				End struct {
-					Line int `json:"line"`
-				} `json:"end"`
-			} `json:"range"`
+		rule, ok := definitionRule(input.Language, input.Query)
+		if !ok {
+			return FindDefinitionOutput{Result: fmt.Sprintf("Unsupported language: %s", input.Language)}, nil
 		}
-		var matches []SgMatch
-		if err := json.Unmarshal(output, &matches); err != nil {
-			// If not JSON, return raw output (it might be an error message)
-			return FindDefinitionOutput{Result: string(output)}, nil
+		matches, msg := scanCode(ctx, input.BasePath, astgrep.Config{ID: "find-definition", Language: input.Language, Rule: rule})
+		if msg != "" {
+			return FindDefinitionOutput{Result: msg}, nil
 		}
-
 		if len(matches) == 0 {
 			return FindDefinitionOutput{Result: "No definitions found."}, nil
 		}
@@ -97,7 +105,7 @@ rule:
 		// Format the output
 		var result string
 		for _, match := range matches {
-			result += fmt.Sprintf("File: %s (Line %d:%d)\n```%s\n%s\n```\n\n", match.File, match.Range.Start.Line+1, match.Range.End.Line+1, input.Language, match.Text)
+			result += fmt.Sprintf("File: %s (Line %d:%d)\n```%s\n%s\n```\n\n", match.File, match.Line(), match.EndLine(), input.Language, match.Text)
 		}
 
 		return FindDefinitionOutput{
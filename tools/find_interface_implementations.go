@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/utils/astgrep"
+	"github.com/snowmerak/useful-genkit/utils/gocode"
+	"github.com/snowmerak/useful-genkit/utils/language"
+)
+
+const FindInterfaceImplementationsTool = "FindInterfaceImplementations"
+
+type FindInterfaceImplementationsInput struct {
+	// Name is an interface, to find the types implementing it, or, for Go, a concrete type,
+	// to find the interfaces it implements.
+	Name     string            `json:"name"`
+	Language language.Language `json:"language,omitempty"`
+	BasePath string            `json:"base_path"`
+}
+
+type FindInterfaceImplementationsOutput struct {
+	Result string `json:"result"`
+}
+
+func FindInterfaceImplementations(g *genkit.Genkit) ai.Tool {
+	description := `Finds the types that implement an interface.
+For Go, implementations are checked with the type checker, so embedded and promoted methods count, and types that only implement the interface through their pointer (methods with pointer receivers) are marked. Given a concrete Go type instead, it lists the interfaces the type implements, from the codebase and the standard library packages it imports. Name may be qualified like "journal.Journal".
+Other languages use ast-grep to find classes declaring the interface (implements, base lists, trait impls); this misses structural or inherited implementations.
+Without a language, the most common supported language under base_path is searched.`
+
+	return genkit.DefineTool(g, FindInterfaceImplementationsTool, description, func(ctx *ai.ToolContext, input FindInterfaceImplementationsInput) (FindInterfaceImplementationsOutput, error) {
+		lang, msg := resolveLanguage(ctx, input.Language, input.BasePath, language.FindInterfaceImplementations)
+		if msg != "" {
+			return FindInterfaceImplementationsOutput{Result: msg}, nil
+		}
+		input.Language = lang
+
+		if input.Language != language.Go {
+			return findDeclaredImplementations(ctx, input)
+		}
+
+		program, msg := loadGoProgram(ctx, input.BasePath)
+		if program == nil {
+			return FindInterfaceImplementationsOutput{Result: msg}, nil
+		}
+		typeNames := readableGo(ctx, program, program.Types(input.Name), program.TypeLocation)
+		if len(typeNames) == 0 {
+			return FindInterfaceImplementationsOutput{Result: fmt.Sprintf("No type named %s found.", input.Name)}, nil
+		}
+
+		var result strings.Builder
+		for _, tn := range typeNames {
+			s := program.Symbol(tn)
+			if !types.IsInterface(tn.Type()) {
+				impls := readableGo(ctx, program, program.Interfaces(tn), implementationLocation)
+				fmt.Fprintf(&result, "%s (%s:%d) implements %d interfaces:\n", s.Signature, s.File, s.Line, len(impls))
+				for _, impl := range impls {
+					fmt.Fprintf(&result, "  %s", impl.Interface)
+					if impl.File != "" {
+						fmt.Fprintf(&result, " (%s:%d)", impl.File, impl.Line)
+					}
+					if impl.Pointer {
+						fmt.Fprintf(&result, " [only *%s]", impl.Type)
+					}
+					result.WriteString("\n")
+				}
+			} else {
+				impls := readableGo(ctx, program, program.Implementations(tn), implementationLocation)
+				fmt.Fprintf(&result, "%s (%s:%d) is implemented by %d types:\n", s.Signature, s.File, s.Line, len(impls))
+				for _, impl := range impls {
+					fmt.Fprintf(&result, "  %s (%s:%d)", implementer(impl), impl.File, impl.Line)
+					if impl.Pointer {
+						result.WriteString(" [pointer receiver methods; the value type does not implement it]")
+					}
+					result.WriteString("\n")
+				}
+			}
+			result.WriteString("\n")
+		}
+		return FindInterfaceImplementationsOutput{Result: result.String()}, nil
+	})
+}
+
+func implementationLocation(impl gocode.Implementation) gocode.Location {
+	return impl.Location
+}
+
+func implementer(impl gocode.Implementation) string {
+	if impl.Pointer {
+		return "*" + impl.Type
+	}
+	return impl.Type
+}
+
+// implementationRule matches the declarations of types in lang that name the interface
+// name as implemented, extended or derived from.
+func implementationRule(lang language.Language, name string) (astgrep.Rule, bool) {
+	naming := func(kinds []string, clause astgrep.Rule) astgrep.Rule {
+		rules := make([]astgrep.Rule, len(kinds))
+		for i, kind := range kinds {
+			rules[i] = astgrep.Kind(kind).WithHas(clause)
+		}
+		return astgrep.Any(rules...)
+	}
+	word := astgrep.Word(name)
+
+	switch lang {
+	case language.Java:
+		return naming([]string{"class_declaration", "enum_declaration", "record_declaration"}, astgrep.Rule{Field: "interfaces", Regex: word}), true
+	case language.TypeScript, language.Tsx:
+		return naming([]string{"class_declaration", "abstract_class_declaration"}, astgrep.Rule{Kind: "implements_clause", Regex: word}.Anywhere()), true
+	case language.CSharp:
+		return naming([]string{"class_declaration", "struct_declaration", "record_declaration"}, astgrep.Rule{Kind: "base_list", Regex: word}), true
+	case language.Kotlin:
+		return naming([]string{"class_declaration", "object_declaration"}, astgrep.Rule{Kind: "delegation_specifier", Regex: word}.Anywhere()), true
+	case language.Swift:
+		return naming([]string{"class_declaration"}, astgrep.Rule{Kind: "inheritance_specifier", Regex: word}.Anywhere()), true
+	case language.Rust:
+		return naming([]string{"impl_item"}, astgrep.Rule{Field: "trait", Regex: word}), true
+	case language.Python:
+		return naming([]string{"class_definition"}, astgrep.Rule{Field: "superclasses", Regex: word}), true
+	case language.Php:
+		return naming([]string{"class_declaration"}, astgrep.Rule{Kind: "class_interface_clause", Regex: word}), true
+	}
+	return astgrep.Rule{}, false
+}
+
+// findDeclaredImplementations runs the ast-grep rule of the input language.
+func findDeclaredImplementations(ctx context.Context, input FindInterfaceImplementationsInput) (FindInterfaceImplementationsOutput, error) {
+	rule, ok := implementationRule(input.Language, input.Name)
+	if !ok {
+		return FindInterfaceImplementationsOutput{Result: fmt.Sprintf("Unsupported language: %s", input.Language)}, nil
+	}
+	matches, msg := scanCode(ctx, input.BasePath, astgrep.Config{ID: "find-interface-implementations", Language: input.Language, Rule: rule})
+	if msg != "" {
+		return FindInterfaceImplementationsOutput{Result: msg}, nil
+	}
+	if len(matches) == 0 {
+		return FindInterfaceImplementationsOutput{Result: "No implementations found."}, nil
+	}
+
+	var result strings.Builder
+	for _, match := range matches {
+		// Only the declaration line is shown; the body can be read with ReadFileLines.
+		header, _, _ := strings.Cut(match.Text, "\n")
+		fmt.Fprintf(&result, "File: %s:%d\n%s\n\n", match.File, match.Line(), header)
+	}
+	return FindInterfaceImplementationsOutput{Result: result.String()}, nil
+}
@@ -1,21 +1,27 @@
 package tools
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
-	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/utils/astgrep"
+	"github.com/snowmerak/useful-genkit/utils/gocode"
 	"github.com/snowmerak/useful-genkit/utils/language"
 )
 
 const FindStructsTool = "FindStructs"
 
+// maxStructCodeLines caps the code shown for a type; its methods are listed after it.
+const maxStructCodeLines = 60
+
 type FindStructsInput struct {
 	StructName string            `json:"struct_name"`
-	Language   language.Language `json:"language"`
+	Language   language.Language `json:"language,omitempty"`
 	BasePath   string            `json:"base_path"`
 }
 
@@ -23,103 +29,344 @@ type FindStructsOutput struct {
 	Result string `json:"result"`
 }
 
+// The ids of the rules FindStructs runs, which decide how a match is grouped.
+const (
+	structRuleType      = "type"
+	structRuleExtension = "extension"
+	structRuleMethod    = "method"
+	structRuleNested    = "nested"
+	structRuleOutside   = "outside"
+)
+
+// structPatterns are the rules finding a type and its methods in one language.
+type structPatterns struct {
+	// types matches the declarations of the type.
+	types func(name string) astgrep.Rule
+	// extensions matches blocks adding methods to the type elsewhere, like Rust impl blocks.
+	extensions func(name string) astgrep.Rule
+	// typeKinds are the node kinds declaring types, to keep the methods of nested types apart.
+	typeKinds []string
+	// methods matches the methods declared inside a type or extension.
+	methods *astgrep.Rule
+	// outside matches methods declared on their own, like Go methods and C++ out-of-line definitions.
+	outside func(name string) astgrep.Rule
+}
+
+// namedAny matches declarations of any of kinds with the name.
+func namedAny(kinds ...string) func(string) astgrep.Rule {
+	return func(name string) astgrep.Rule {
+		rules := make([]astgrep.Rule, len(kinds))
+		for i, kind := range kinds {
+			rules[i] = astgrep.Named(kind, name)
+		}
+		return astgrep.Any(rules...)
+	}
+}
+
+// withBody matches declarations of any of kinds with the name and a body, leaving out
+// forward declarations and uses like "struct X *p".
+func withBody(body string, kinds ...string) func(string) astgrep.Rule {
+	return func(name string) astgrep.Rule {
+		rules := make([]astgrep.Rule, len(kinds))
+		for i, kind := range kinds {
+			rules[i] = astgrep.All(astgrep.Named(kind, name), astgrep.Kind(kind).WithHas(astgrep.Kind(body).InField("body")))
+		}
+		return astgrep.Any(rules...)
+	}
+}
+
+// generic matches name with optional type arguments, like "List" or "List<T>".
+func generic(name, open, close string) string {
+	o, c := regexp.QuoteMeta(open), regexp.QuoteMeta(close)
+	return regexp.QuoteMeta(name) + `(` + o + `[^` + c + `]*` + c + `)?`
+}
+
+var typeScriptStructs = structPatterns{
+	types:     namedAny("class_declaration", "abstract_class_declaration", "interface_declaration"),
+	typeKinds: []string{"class_declaration", "abstract_class_declaration", "interface_declaration", "class"},
+	methods:   anyKind("method_definition", "method_signature", "abstract_method_signature"),
+}
+
+var cppStructs = structPatterns{
+	types:     withBody("field_declaration_list", "class_specifier", "struct_specifier", "union_specifier"),
+	typeKinds: []string{"class_specifier", "struct_specifier", "union_specifier"},
+	// Methods defined in the class, and those only declared there.
+	methods: &astgrep.Rule{Any: []astgrep.Rule{
+		astgrep.Kind("function_definition"),
+		astgrep.Kind("field_declaration").WithHas(astgrep.Kind("function_declarator").InField("declarator")),
+		astgrep.Kind("declaration").WithHas(astgrep.Kind("function_declarator").InField("declarator")),
+	}},
+	outside: func(name string) astgrep.Rule {
+		// "Type::method(" in the declarator, behind a pointer or reference return type.
+		return astgrep.Kind("function_definition").WithHas(astgrep.Rule{
+			Field: "declarator",
+			Regex: `(^|[\s*&])` + generic(name, "<", ">") + `::~?\w+\s*\(`,
+		})
+	},
+}
+
+// swiftExtension matches the text of an extension declaration.
+const swiftExtension = `^[^{]*?\bextension\s`
+
+var structLanguages = map[language.Language]structPatterns{
+	language.Go: {
+		types: namedAny("type_spec", "type_alias"),
+		outside: func(name string) astgrep.Rule {
+			// Receivers like "(t T)", "(t *T)" and "(l *List[T])".
+			return astgrep.Kind("method_declaration").WithHas(astgrep.Rule{
+				Field: "receiver",
+				Regex: `^\(\s*(\w+\s+)?\*?\s*` + generic(name, "[", "]") + `\s*\)$`,
+			})
+		},
+	},
+	language.Python: {
+		types:     namedAny("class_definition"),
+		typeKinds: []string{"class_definition"},
+		methods:   anyKind("function_definition"),
+	},
+	language.JavaScript: {
+		types:     namedAny("class_declaration"),
+		typeKinds: []string{"class_declaration", "class"},
+		methods:   anyKind("method_definition"),
+	},
+	language.TypeScript: typeScriptStructs,
+	language.Tsx:        typeScriptStructs,
+	language.Java: {
+		types:     namedAny("class_declaration", "interface_declaration", "enum_declaration", "record_declaration"),
+		typeKinds: []string{"class_declaration", "interface_declaration", "enum_declaration", "record_declaration"},
+		methods:   anyKind("method_declaration", "constructor_declaration"),
+	},
+	language.Rust: {
+		types:     namedAny("struct_item", "enum_item", "union_item", "trait_item"),
+		typeKinds: []string{"struct_item", "enum_item", "union_item", "trait_item", "impl_item"},
+		// "impl X", "impl<T> X<T>" and "impl Trait for X" all name X in the type field.
+		extensions: func(name string) astgrep.Rule {
+			return astgrep.Kind("impl_item").WithHas(astgrep.Rule{Field: "type", Regex: "^" + generic(name, "<", ">") + "$"})
+		},
+		methods: anyKind("function_item", "function_signature_item"),
+	},
+	language.Kotlin: {
+		// Kotlin declarations have no name field; the name is a direct type_identifier child.
+		types: func(name string) astgrep.Rule {
+			named := astgrep.Rule{Kind: "type_identifier", Regex: astgrep.Exact(name)}
+			return astgrep.Any(astgrep.Kind("class_declaration").WithHas(named), astgrep.Kind("object_declaration").WithHas(named))
+		},
+		typeKinds: []string{"class_declaration", "object_declaration"},
+		methods:   anyKind("function_declaration"),
+		// Extension functions like "fun Foo.bar()", with only modifiers and annotations before "fun".
+		outside: func(name string) astgrep.Rule {
+			return astgrep.Rule{Kind: "function_declaration", Regex: `^[^{=]*?\bfun\s+(<[^>]*>\s*)?` + generic(name, "<", ">") + `\??\s*\.\s*\w+`}
+		},
+	},
+	language.CSharp: {
+		types:     namedAny("class_declaration", "struct_declaration", "record_declaration", "interface_declaration"),
+		typeKinds: []string{"class_declaration", "struct_declaration", "record_declaration", "interface_declaration"},
+		methods:   anyKind("method_declaration", "constructor_declaration"),
+	},
+	language.Swift: {
+		// Structs, classes, enums, actors and extensions are all class_declaration.
+		types: func(name string) astgrep.Rule {
+			declaration := astgrep.All(astgrep.Named("class_declaration", name), astgrep.Rule{Not: &astgrep.Rule{Regex: swiftExtension}})
+			return astgrep.Any(declaration, astgrep.Named("protocol_declaration", name))
+		},
+		extensions: func(name string) astgrep.Rule {
+			return astgrep.All(astgrep.Named("class_declaration", name), astgrep.Rule{Regex: swiftExtension})
+		},
+		typeKinds: []string{"class_declaration", "protocol_declaration"},
+		methods:   anyKind("function_declaration", "init_declaration", "protocol_function_declaration"),
+	},
+	language.C: {
+		types: withBody("field_declaration_list", "struct_specifier", "union_specifier"),
+	},
+	language.Cpp: cppStructs,
+}
+
+// configs returns the rules to run for name.
+func (p structPatterns) configs(lang language.Language, name string) []astgrep.Config {
+	containers := []astgrep.Rule{p.types(name)}
+	configs := []astgrep.Config{{ID: structRuleType, Language: lang, Rule: p.types(name)}}
+	if p.extensions != nil {
+		containers = append(containers, p.extensions(name))
+		configs = append(configs, astgrep.Config{ID: structRuleExtension, Language: lang, Rule: p.extensions(name)})
+	}
+	if p.methods != nil {
+		// Nested types and methods hold declarations that are not methods of the type, like
+		// the methods of an inner class or a function defined in a method.
+		inside := astgrep.Any(containers...).Anywhere()
+		nested := astgrep.Any(*anyKind(p.typeKinds...), *p.methods)
+		configs = append(configs,
+			astgrep.Config{ID: structRuleMethod, Language: lang, Rule: p.methods.WithInside(inside)},
+			astgrep.Config{ID: structRuleNested, Language: lang, Rule: nested.WithInside(inside)},
+		)
+	}
+	if p.outside != nil {
+		configs = append(configs, astgrep.Config{ID: structRuleOutside, Language: lang, Rule: p.outside(name)})
+	}
+	return configs
+}
+
+func anyKind(kinds ...string) *astgrep.Rule {
+	rules := make([]astgrep.Rule, len(kinds))
+	for i, kind := range kinds {
+		rules[i] = astgrep.Kind(kind)
+	}
+	rule := astgrep.Any(rules...)
+	return &rule
+}
+
 func FindStructs(g *genkit.Genkit) ai.Tool {
-	return genkit.DefineTool(g, FindStructsTool, "Finds the definition of a struct/class and its methods.", func(ctx *ai.ToolContext, input FindStructsInput) (FindStructsOutput, error) {
-		var patterns []string
-		switch input.Language {
-		case language.Go:
-			patterns = []string{
-				fmt.Sprintf("type %s struct { $$$ }", input.StructName),
-				fmt.Sprintf("func ($R %s) $M($$$) $$$", input.StructName),
-				fmt.Sprintf("func ($R *%s) $M($$$) $$$", input.StructName),
-			}
-		case language.Python:
-			patterns = []string{
-				fmt.Sprintf("class %s: $$$", input.StructName),
-			}
-		case language.TypeScript, language.JavaScript, language.Java:
-			patterns = []string{
-				fmt.Sprintf("class %s { $$$ }", input.StructName),
-			}
-		default:
-			return FindStructsOutput{}, fmt.Errorf("unsupported language: %s", input.Language)
+	return genkit.DefineTool(g, FindStructsTool, `Finds the definition of a struct/class and its methods.
+For Go, the type is looked up with type information and reported with its fields and its full method set, including promoted methods and which methods need a pointer receiver.
+Other languages use ast-grep: each declaration is shown with the methods declared in it, then the blocks adding methods elsewhere (Rust impl blocks, Swift extensions), then methods declared on their own (C++ out-of-line definitions, Kotlin extension functions).
+Without a language, the most common supported language under base_path is searched.`, func(ctx *ai.ToolContext, input FindStructsInput) (FindStructsOutput, error) {
+		lang, msg := resolveLanguage(ctx, input.Language, input.BasePath, language.FindStructs)
+		if msg != "" {
+			return FindStructsOutput{Result: msg}, nil
 		}
+		input.Language = lang
 
-		var rulePatterns string
-		for _, p := range patterns {
-			rulePatterns += fmt.Sprintf("    - pattern: '%s'\n", p)
+		if input.Language == language.Go {
+			program, msg := loadGoProgram(ctx, input.BasePath)
+			if program != nil {
+				var result string
+				for _, tn := range readableGo(ctx, program, program.Types(input.StructName), program.TypeLocation) {
+					methods := readableGo(ctx, program, program.Methods(tn), func(m gocode.Method) gocode.Location { return m.Location })
+					result += formatGoType(program.Symbol(tn), program.Fields(tn), methods)
+				}
+				if result == "" {
+					return FindStructsOutput{Result: "No struct/class or methods found."}, nil
+				}
+				return FindStructsOutput{Result: result}, nil
+			}
+			// Code that does not type-check is still searched by its syntax.
+			found, err := findStructsWithAstGrep(ctx, input)
+			found.Result = msg + "\nFalling back to a syntax search.\n\n" + found.Result
+			return found, err
 		}
+		return findStructsWithAstGrep(ctx, input)
+	})
+}
+
+func findStructsWithAstGrep(ctx context.Context, input FindStructsInput) (FindStructsOutput, error) {
+	patterns, ok := structLanguages[input.Language]
+	if !ok {
+		return FindStructsOutput{Result: fmt.Sprintf("Unsupported language: %s", input.Language)}, nil
+	}
+	matches, msg := scanCode(ctx, input.BasePath, patterns.configs(input.Language, input.StructName)...)
+	if msg != "" {
+		return FindStructsOutput{Result: msg}, nil
+	}
 
-		ruleContent := fmt.Sprintf(`id: find-structs
-language: %s
-rule:
-  any:
-%s
-`, input.Language, rulePatterns)
+	groups, outside := groupStructMatches(matches)
+	if len(groups) == 0 && len(outside) == 0 {
+		return FindStructsOutput{Result: "No struct/class or methods found."}, nil
+	}
 
-		tmpFile, err := os.CreateTemp("", "find_structs_*.yml")
-		if err != nil {
-			return FindStructsOutput{}, fmt.Errorf("failed to create temp file: %w", err)
+	var result strings.Builder
+	for _, group := range groups {
+		code := group.container.Text
+		if group.container.RuleID == structRuleExtension {
+			// The methods are listed below, so the header is enough.
+			code = declarationHeader(code)
+		} else if lines := strings.Split(code, "\n"); len(lines) > maxStructCodeLines {
+			code = strings.Join(lines[:maxStructCodeLines], "\n") + "\n..."
 		}
-		defer os.Remove(tmpFile.Name())
+		fmt.Fprintf(&result, "File: %s:%d\n%s\n", group.container.File, group.container.Line(), code)
+		writeStructMethods(&result, "Methods", group.methods)
+		result.WriteString("\n")
+	}
+	if len(outside) > 0 {
+		writeStructMethods(&result, "Methods declared outside the type", outside)
+	}
+	return FindStructsOutput{Result: result.String()}, nil
+}
 
-		if _, err := tmpFile.WriteString(ruleContent); err != nil {
-			return FindStructsOutput{}, fmt.Errorf("failed to write to temp file: %w", err)
+// structGroup is a type declaration or extension with the methods declared in it.
+type structGroup struct {
+	container astgrep.Match
+	methods   []astgrep.Match
+}
+
+// groupStructMatches puts each method in the innermost declaration or extension holding
+// it, dropping those inside nested types and methods. Declarations come before extensions.
+func groupStructMatches(matches []astgrep.Match) (groups []*structGroup, outside []astgrep.Match) {
+	var nested []astgrep.Match
+	for _, m := range matches {
+		switch m.RuleID {
+		case structRuleType, structRuleExtension:
+			groups = append(groups, &structGroup{container: m})
+		case structRuleNested:
+			nested = append(nested, m)
+		case structRuleOutside:
+			outside = append(outside, m)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i].container, groups[j].container
+		if (a.RuleID == structRuleType) != (b.RuleID == structRuleType) {
+			return a.RuleID == structRuleType
 		}
-		if err := tmpFile.Close(); err != nil {
-			return FindStructsOutput{}, fmt.Errorf("failed to close temp file: %w", err)
+		if a.File != b.File {
+			return a.File < b.File
 		}
+		return a.Range.Start.Line < b.Range.Start.Line
+	})
 
-		args := []string{"scan", "--rule", tmpFile.Name(), "--json"}
-		if input.BasePath != "" {
-			args = append(args, input.BasePath)
+	for _, m := range matches {
+		if m.RuleID != structRuleMethod {
+			continue
 		}
-		cmd := exec.Command("sg", args...)
-		output, err := cmd.Output()
-		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				// sg returns non-zero exit code if matches are found (sometimes) or if error?
-				// Actually sg scan returns 0 on success usually.
-				// But let's check stderr if needed.
-				// Wait, sg scan might return exit code 1 if no matches? Or if matches found?
-				// Let's assume standard behavior or check stderr.
-				// In find_usage.go, it handles err.
-				_ = exitError
+		var owner *structGroup
+		for _, g := range groups {
+			if contains(g.container, m) && (owner == nil || contains(owner.container, g.container)) {
+				owner = g
 			}
-			// If output is empty and err is not nil, it might be a real error.
-			// But sometimes tools return non-zero for "found matches" or "no matches".
-			// Let's proceed if we have output.
 		}
-
-		type SgMatch struct {
-			Text  string `json:"text"`
-			File  string `json:"file"`
-			Range struct {
-				Start struct {
-					Line int `json:"line"`
-				} `json:"start"`
-				End struct {
-					Line int `json:"line"`
-				} `json:"end"`
-			} `json:"range"`
+		if owner == nil {
+			continue
 		}
-
-		var matches []SgMatch
-		if len(output) > 0 {
-			if err := json.Unmarshal(output, &matches); err != nil {
-				return FindStructsOutput{}, fmt.Errorf("failed to parse sg output: %w", err)
+		inNested := false
+		for _, n := range nested {
+			if n.Range != m.Range && n.Range != owner.container.Range && contains(owner.container, n) && contains(n, m) {
+				inNested = true
+				break
 			}
 		}
-
-		if len(matches) == 0 {
-			return FindStructsOutput{Result: "No struct/class or methods found."}, nil
+		if !inNested {
+			owner.methods = append(owner.methods, m)
 		}
+	}
+	return groups, outside
+}
 
-		result := ""
-		for _, match := range matches {
-			result += fmt.Sprintf("File: %s:%d\n%s\n\n", match.File, match.Range.Start.Line+1, match.Text)
-		}
+// contains reports whether the match outer spans inner.
+func contains(outer, inner astgrep.Match) bool {
+	before := func(a, b astgrep.Position) bool {
+		return a.Line < b.Line || a.Line == b.Line && a.Column <= b.Column
+	}
+	return outer.File == inner.File && before(outer.Range.Start, inner.Range.Start) && before(inner.Range.End, outer.Range.End)
+}
 
-		return FindStructsOutput{Result: result}, nil
-	})
+func writeStructMethods(b *strings.Builder, title string, methods []astgrep.Match) {
+	if len(methods) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s:\n", title)
+	for _, m := range methods {
+		fmt.Fprintf(b, "  %s:%d: %s\n", m.File, m.Line(), declarationHeader(m.Text))
+	}
+}
+
+// declarationHeader returns the first line of a declaration that is not an attribute,
+// annotation or decorator, without the opening brace.
+func declarationHeader(text string) string {
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "@") || strings.HasPrefix(line, "#[") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		return strings.TrimSpace(strings.TrimSuffix(line, "{"))
+	}
+	return strings.TrimSpace(lines[0])
 }
@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/useful-genkit/utils/astgrep"
+)
+
+// match builds an ast-grep match of rule spanning the zero-based lines start to end.
+func match(rule, file string, start, end int) astgrep.Match {
+	return astgrep.Match{
+		RuleID: rule,
+		File:   file,
+		Range:  astgrep.Range{Start: astgrep.Position{Line: start, Column: 0}, End: astgrep.Position{Line: end, Column: 1}},
+	}
+}
+
+// describeGroups prints groups like "type a.py:1 [a.py:3 a.py:5]".
+func describeGroups(groups []*structGroup) []string {
+	var got []string
+	for _, g := range groups {
+		methods := make([]string, len(g.methods))
+		for i, m := range g.methods {
+			methods[i] = fmt.Sprintf("%s:%d", m.File, m.Line())
+		}
+		got = append(got, fmt.Sprintf("%s %s:%d [%s]", g.container.RuleID, g.container.File, g.container.Line(), strings.Join(methods, " ")))
+	}
+	return got
+}
+
+func TestGroupStructMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		matches []astgrep.Match
+		want    []string
+		outside int
+	}{
+		{
+			name: "methods of the type",
+			matches: []astgrep.Match{
+				match(structRuleType, "a.py", 0, 10),
+				match(structRuleMethod, "a.py", 2, 4),
+				match(structRuleMethod, "a.py", 6, 8),
+				match(structRuleNested, "a.py", 2, 4),
+				match(structRuleNested, "a.py", 6, 8),
+			},
+			want: []string{"type a.py:1 [a.py:3 a.py:7]"},
+		},
+		{
+			name: "innermost owner",
+			matches: []astgrep.Match{
+				match(structRuleType, "a.py", 0, 20),
+				match(structRuleType, "a.py", 4, 10),
+				match(structRuleMethod, "a.py", 2, 3),
+				match(structRuleMethod, "a.py", 5, 6),
+				match(structRuleMethod, "a.py", 12, 14),
+				match(structRuleNested, "a.py", 2, 3),
+				match(structRuleNested, "a.py", 4, 10),
+				match(structRuleNested, "a.py", 5, 6),
+				match(structRuleNested, "a.py", 12, 14),
+			},
+			want: []string{"type a.py:1 [a.py:3 a.py:13]", "type a.py:5 [a.py:6]"},
+		},
+		{
+			name: "methods of nested types and functions in methods are left out",
+			matches: []astgrep.Match{
+				match(structRuleType, "A.java", 0, 30),
+				match(structRuleMethod, "A.java", 2, 4),
+				match(structRuleMethod, "A.java", 7, 9),
+				match(structRuleMethod, "A.java", 14, 20),
+				match(structRuleMethod, "A.java", 15, 16),
+				match(structRuleNested, "A.java", 2, 4),
+				match(structRuleNested, "A.java", 6, 12),
+				match(structRuleNested, "A.java", 7, 9),
+				match(structRuleNested, "A.java", 14, 20),
+				match(structRuleNested, "A.java", 15, 16),
+			},
+			want: []string{"type A.java:1 [A.java:3 A.java:15]"},
+		},
+		{
+			name: "types before extensions",
+			matches: []astgrep.Match{
+				match(structRuleExtension, "b.rs", 10, 14),
+				match(structRuleMethod, "b.rs", 11, 13),
+				match(structRuleExtension, "a.rs", 20, 24),
+				match(structRuleExtension, "b.rs", 2, 6),
+				match(structRuleType, "b.rs", 0, 1),
+				match(structRuleMethod, "b.rs", 3, 5),
+			},
+			want: []string{"type b.rs:1 []", "extension a.rs:21 []", "extension b.rs:3 [b.rs:4]", "extension b.rs:11 [b.rs:12]"},
+		},
+		{
+			name: "methods in other files and outside methods",
+			matches: []astgrep.Match{
+				match(structRuleType, "a.go", 0, 10),
+				match(structRuleMethod, "b.go", 2, 4),
+				match(structRuleOutside, "a.go", 12, 14),
+				match(structRuleOutside, "b.go", 0, 2),
+			},
+			want:    []string{"type a.go:1 []"},
+			outside: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, outside := groupStructMatches(tt.matches)
+			if got := describeGroups(groups); !slices.Equal(got, tt.want) {
+				t.Errorf("groups = %q, want %q", got, tt.want)
+			}
+			if len(outside) != tt.outside {
+				t.Errorf("%d methods outside, want %d", len(outside), tt.outside)
+			}
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	at := func(file string, startLine, startColumn, endLine, endColumn int) astgrep.Match {
+		return astgrep.Match{File: file, Range: astgrep.Range{
+			Start: astgrep.Position{Line: startLine, Column: startColumn},
+			End:   astgrep.Position{Line: endLine, Column: endColumn},
+		}}
+	}
+	outer := at("a.go", 2, 4, 8, 1)
+	tests := []struct {
+		name  string
+		inner astgrep.Match
+		want  bool
+	}{
+		{"same range", outer, true},
+		{"inside", at("a.go", 3, 0, 5, 0), true},
+		{"same start line, later column", at("a.go", 2, 6, 2, 9), true},
+		{"same end line, earlier column", at("a.go", 7, 0, 8, 0), true},
+		{"starts before on the same line", at("a.go", 2, 3, 5, 0), false},
+		{"ends after on the same line", at("a.go", 3, 0, 8, 2), false},
+		{"starts on an earlier line", at("a.go", 1, 9, 5, 0), false},
+		{"ends on a later line", at("a.go", 3, 0, 9, 0), false},
+		{"other file", at("b.go", 3, 0, 5, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contains(outer, tt.inner); got != tt.want {
+				t.Errorf("contains = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeclarationHeader(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"func (l *List) Len() int {\n\treturn l.n\n}", "func (l *List) Len() int"},
+		{"@Override\npublic String toString() {\n}", "public String toString()"},
+		{"@Deprecated\n  @Override\n  void run() {}", "void run() {}"},
+		{"#[derive(Debug)]\npub struct List {\n}", "pub struct List"},
+		{"[Serializable]\npublic class List\n{\n}", "public class List"},
+		{"\n\n  impl Display for List {\n}", "impl Display for List"},
+		{"def __init__(self):\n    pass", "def __init__(self):"},
+		{"@dataclass", "@dataclass"},
+	}
+	for _, tt := range tests {
+		if got := declarationHeader(tt.text); got != tt.want {
+			t.Errorf("declarationHeader(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
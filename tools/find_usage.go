@@ -1,13 +1,14 @@
 package tools
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
+	"regexp"
+	"strings"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/snowmerak/useful-genkit/utils/astgrep"
+	"github.com/snowmerak/useful-genkit/utils/gocode"
 	"github.com/snowmerak/useful-genkit/utils/language"
 )
 
@@ -15,7 +16,7 @@ const FindUsageTool = "FindUsage"
 
 type FindUsageInput struct {
 	Query    string            `json:"query"`
-	Language language.Language `json:"language"`
+	Language language.Language `json:"language,omitempty"`
 	BasePath string            `json:"base_path"`
 }
 
@@ -23,67 +24,70 @@ type FindUsageOutput struct {
 	Result string `json:"result"`
 }
 
-func FindUsage(g *genkit.Genkit) ai.Tool {
-	return genkit.DefineTool(g, FindUsageTool, "Finds usages of a symbol (function, method, or type) in the codebase using ast-grep.", func(ctx *ai.ToolContext, input FindUsageInput) (FindUsageOutput, error) {
-		// Create a temporary rule file
-		ruleContent := fmt.Sprintf(`id: find-usage
-language: %s
-rule:
-  any:
-    - kind: function_declaration
-    - kind: method_declaration
-    - kind: type_declaration
-  has:
-    stopBy: end
-    regex: %s
-`, input.Language, input.Query)
+// identifierKinds are the node kinds that name things in each language.
+var identifierKinds = map[language.Language][]string{
+	language.Bash:       {"variable_name", "command_name"},
+	language.C:          {"identifier", "field_identifier", "type_identifier"},
+	language.Cpp:        {"identifier", "field_identifier", "type_identifier", "namespace_identifier"},
+	language.CSharp:     {"identifier"},
+	language.Java:       {"identifier", "type_identifier"},
+	language.JavaScript: {"identifier", "property_identifier", "shorthand_property_identifier"},
+	language.Kotlin:     {"simple_identifier", "type_identifier"},
+	language.Lua:        {"identifier"},
+	language.Php:        {"name"},
+	language.Python:     {"identifier"},
+	language.Ruby:       {"identifier", "constant"},
+	language.Rust:       {"identifier", "field_identifier", "type_identifier"},
+	language.Scala:      {"identifier", "type_identifier"},
+	language.Swift:      {"simple_identifier", "type_identifier"},
+	language.TypeScript: {"identifier", "property_identifier", "shorthand_property_identifier", "type_identifier"},
+	language.Tsx:        {"identifier", "property_identifier", "shorthand_property_identifier", "type_identifier"},
+}
 
-		tmpFile, err := os.CreateTemp("", "find_usage_*.yml")
-		if err != nil {
-			return FindUsageOutput{}, fmt.Errorf("failed to create temp file: %w", err)
-		}
-		defer os.Remove(tmpFile.Name())
+// qualifierSeparator splits qualified names like "pkg.Name", "Type::name" or "obj->name".
+var qualifierSeparator = regexp.MustCompile(`\.|::|->`)
 
-		if _, err := tmpFile.WriteString(ruleContent); err != nil {
-			return FindUsageOutput{}, fmt.Errorf("failed to write to temp file: %w", err)
-		}
-		if err := tmpFile.Close(); err != nil {
-			return FindUsageOutput{}, fmt.Errorf("failed to close temp file: %w", err)
+func FindUsage(g *genkit.Genkit) ai.Tool {
+	return genkit.DefineTool(g, FindUsageTool, `Finds usages of a symbol (function, method, or type) in the codebase.
+For Go, references are resolved with type information, so only uses of that exact symbol are reported, qualified ones like "journal.WriteFile" included, each with the function it is in. Other languages use ast-grep to find every identifier with the name, for a qualified query the last part of it, declarations included. Without a language, the most common supported language under base_path is searched.`, func(ctx *ai.ToolContext, input FindUsageInput) (FindUsageOutput, error) {
+		lang, msg := resolveLanguage(ctx, input.Language, input.BasePath, language.FindUsage)
+		if msg != "" {
+			return FindUsageOutput{Result: msg}, nil
 		}
+		input.Language = lang
 
-		// Run ast-grep
-		args := []string{"scan", "--json", "-r", tmpFile.Name()}
-		if input.BasePath != "" {
-			args = append(args, input.BasePath)
-		}
-		cmd := exec.Command("sg", args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			// If output is empty, it's a real error. If not, it might just be exit code 1 for no matches or similar (though sg usually returns 0).
-			if len(output) == 0 {
-				return FindUsageOutput{Result: fmt.Sprintf("Error running ast-grep: %v", err)}, nil
+		if input.Language == language.Go {
+			program, msg := loadGoProgram(ctx, input.BasePath)
+			if program == nil {
+				return FindUsageOutput{Result: msg}, nil
+			}
+			refs := readableGo(ctx, program, program.References(input.Query), func(r gocode.Reference) gocode.Location { return r.Location })
+			if len(refs) == 0 {
+				return FindUsageOutput{Result: "No usages found."}, nil
 			}
+			return FindUsageOutput{Result: formatGoReferences(refs)}, nil
 		}
 
-		// Parse JSON output to make it more readable or just return as string
-		type SgMatch struct {
-			Text  string `json:"text"`
-			File  string `json:"file"`
-			Range struct {
-				Start struct {
-					Line int `json:"line"`
-				} `json:"start"`
-				End struct {
-					Line int `json:"line"`
-				} `json:"end"`
-			} `json:"range"`
+		kinds, ok := identifierKinds[input.Language]
+		if !ok {
+			return FindUsageOutput{Result: fmt.Sprintf("Unsupported language: %s", input.Language)}, nil
 		}
-		var matches []SgMatch
-		if err := json.Unmarshal(output, &matches); err != nil {
-			// If not JSON, return raw output (it might be an error message)
-			return FindUsageOutput{Result: string(output)}, nil
+		parts := qualifierSeparator.Split(strings.TrimSpace(input.Query), -1)
+		name := parts[len(parts)-1]
+		if name == "" {
+			return FindUsageOutput{Result: "query is required"}, nil
 		}
+		rules := make([]astgrep.Rule, len(kinds))
+		for i, kind := range kinds {
+			rules[i] = astgrep.Kind(kind)
+		}
+		rule := astgrep.Any(rules...)
+		rule.Regex = astgrep.Exact(name)
 
+		matches, msg := scanCode(ctx, input.BasePath, astgrep.Config{ID: "find-usage", Language: input.Language, Rule: rule})
+		if msg != "" {
+			return FindUsageOutput{Result: msg}, nil
+		}
 		if len(matches) == 0 {
 			return FindUsageOutput{Result: "No usages found."}, nil
 		}
@@ -91,7 +95,7 @@ rule:
 		// Format the output
 		var result string
 		for _, match := range matches {
-			result += fmt.Sprintf("File: %s (Line %d:%d)\n```%s\n%s\n```\n\n", match.File, match.Range.Start.Line+1, match.Range.End.Line+1, input.Language, match.Text)
+			result += fmt.Sprintf("File: %s (Line %d:%d)\n```%s\n%s\n```\n\n", match.File, match.Line(), match.Column(), input.Language, strings.TrimRight(match.Lines, "\n"))
 		}
 
 		return FindUsageOutput{
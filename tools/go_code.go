@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/gocode"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
+)
+
+// loadGoProgram type-checks the Go packages under basePath, defaulting to the workspace
+// root or the current directory. A failure is returned as a message for the model.
+func loadGoProgram(ctx context.Context, basePath string) (*gocode.Program, string) {
+	root, err := searchRoot(ctx, basePath)
+	if err != nil {
+		return nil, err.Error()
+	}
+	program, err := gocode.Load(ctx, root)
+	if err != nil {
+		return nil, fmt.Sprintf("Error loading Go packages: %v", err)
+	}
+	return program, ""
+}
+
+// readableGoFile reports whether results in file may be shown. Packages are type-checked
+// as a whole, so files the workspace denies are loaded too and their results must be left
+// out. Files outside the loaded directory, of dependencies and the standard library, are
+// not part of the workspace and are shown.
+func readableGoFile(ctx context.Context, program *gocode.Program, file string) bool {
+	rel, err := filepath.Rel(program.Dir, file)
+	if file == "" || err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return true
+	}
+	_, err = workspace.Read(ctx, file)
+	return err == nil
+}
+
+// readableGo drops the items whose location readableGoFile rejects.
+func readableGo[T any](ctx context.Context, program *gocode.Program, items []T, loc func(T) gocode.Location) []T {
+	return slices.DeleteFunc(items, func(item T) bool {
+		return !readableGoFile(ctx, program, loc(item).File)
+	})
+}
+
+func formatGoDefinitions(symbols []gocode.Symbol) string {
+	var b strings.Builder
+	for _, s := range symbols {
+		fmt.Fprintf(&b, "File: %s (Line %d)\n%s %s in %s\n```go\n%s\n```\n\n", s.File, s.Line, s.Kind, s.Name, s.Package, s.Code)
+	}
+	return b.String()
+}
+
+func formatGoReferences(refs []gocode.Reference) string {
+	var b strings.Builder
+	file := ""
+	for _, r := range refs {
+		if r.File != file {
+			if file != "" {
+				b.WriteString("\n")
+			}
+			file = r.File
+			fmt.Fprintf(&b, "File: %s\n", file)
+		}
+		fmt.Fprintf(&b, "  %d:%d", r.Line, r.Column)
+		if r.Func != "" {
+			fmt.Fprintf(&b, " in %s", r.Func)
+		}
+		fmt.Fprintf(&b, ": %s\n", r.Text)
+	}
+	return b.String()
+}
+
+func formatGoType(s gocode.Symbol, fields []gocode.Field, methods []gocode.Method) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "File: %s:%d\n%s\n", s.File, s.Line, s.Code)
+	if len(fields) > 0 {
+		b.WriteString("\nFields:\n")
+		for _, f := range fields {
+			name := f.Name
+			if f.Embedded {
+				name = "(embedded)"
+			}
+			fmt.Fprintf(&b, "  %s %s", name, f.Type)
+			if f.Tag != "" {
+				fmt.Fprintf(&b, " `%s`", f.Tag)
+			}
+			fmt.Fprintf(&b, " (%s:%d)\n", f.File, f.Line)
+		}
+	}
+	if len(methods) > 0 {
+		b.WriteString("\nMethods:\n")
+		for _, m := range methods {
+			var notes []string
+			if m.PointerReceiver {
+				notes = append(notes, "pointer receiver")
+			}
+			if m.Promoted {
+				notes = append(notes, "promoted")
+			}
+			fmt.Fprintf(&b, "  %s (%s:%d)", m.Signature, m.File, m.Line)
+			if len(notes) > 0 {
+				fmt.Fprintf(&b, " [%s]", strings.Join(notes, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String() + "\n"
+}
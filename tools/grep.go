@@ -0,0 +1,250 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	fileutil "github.com/snowmerak/useful-genkit/utils/file"
+	"github.com/snowmerak/useful-genkit/utils/gitignore"
+	"github.com/snowmerak/useful-genkit/utils/workspace"
+)
+
+const GrepTool = "Grep"
+
+const (
+	defaultGrepMaxResults = 100
+	maxGrepMaxResults     = 1000
+	maxGrepContext        = 10
+	// maxGrepFileSize skips files that are unlikely to be source code.
+	maxGrepFileSize = 4 << 20
+	// maxGrepLineLength shortens minified or generated lines in the results.
+	maxGrepLineLength = 500
+)
+
+// errGrepLimit stops the walk once enough matches are found.
+var errGrepLimit = errors.New("result limit reached")
+
+// GrepInput defines the input for the Grep tool.
+type GrepInput struct {
+	Pattern string `json:"pattern"`
+	// Path is the file or directory to search. Defaults to the workspace root or the current directory.
+	Path string `json:"path,omitempty"`
+	// Literal searches for Pattern as plain text instead of a regular expression.
+	Literal    bool `json:"literal,omitempty"`
+	IgnoreCase bool `json:"ignore_case,omitempty"`
+	// Include limits the search to files matching any of these globs, like "*.go" or "cmd/**/*.go".
+	Include []string `json:"include,omitempty"`
+	// Exclude skips files and directories matching any of these globs.
+	Exclude []string `json:"exclude,omitempty"`
+	// Context is the number of lines shown before and after each match, at most 10.
+	Context int `json:"context,omitempty"`
+	// MaxResults caps the matches returned. Defaults to 100, at most 1000.
+	MaxResults int `json:"max_results,omitempty"`
+}
+
+// GrepMatch is a single matching line.
+type GrepMatch struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// Column is the 1-based byte offset of the match in the line.
+	Column int    `json:"column"`
+	Match  string `json:"match"`
+	Text   string `json:"text"`
+	// Before and After hold the context lines.
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// GrepOutput defines the output for the Grep tool.
+type GrepOutput struct {
+	Matches       []GrepMatch `json:"matches,omitempty"`
+	FilesSearched int         `json:"files_searched"`
+	// Truncated reports that the search stopped at MaxResults.
+	Truncated bool   `json:"truncated,omitempty"`
+	Notice    string `json:"notice,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Grep creates a tool to search file contents with a regular expression or plain text.
+func Grep(g *genkit.Genkit) ai.Tool {
+	description := `Searches the contents of files for a regular expression (Go RE2 syntax) or, with "literal", plain text.
+Searches a file or a directory recursively, skipping files ignored by .gitignore, the .git directory and binary files.
+Filter files with "include" and "exclude" globs: a glob without a slash matches file names at any depth ("*.go"), one with a slash matches paths relative to "path" ("internal/**/*.go").
+Each match reports its file, line, column, the matched text and the line; "context" adds lines around it. Use ReadFileLines to read more around a match.`
+
+	return genkit.DefineTool(g, GrepTool, description, func(ctx *ai.ToolContext, input GrepInput) (GrepOutput, error) {
+		if input.Pattern == "" {
+			return GrepOutput{Error: "pattern is required"}, nil
+		}
+		expr := input.Pattern
+		if input.Literal {
+			expr = regexp.QuoteMeta(expr)
+		}
+		if input.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return GrepOutput{Error: fmt.Sprintf("invalid pattern: %v", err)}, nil
+		}
+
+		root := input.Path
+		if root == "" {
+			if w := workspace.FromContext(ctx); w != nil {
+				root = w.Root
+			} else if root, err = os.Getwd(); err != nil {
+				return GrepOutput{}, fmt.Errorf("failed to get current directory: %w", err)
+			}
+		}
+		root, err = workspace.Read(ctx, root)
+		if err != nil {
+			return GrepOutput{Error: err.Error()}, nil
+		}
+
+		include := grepGlobs(input.Include)
+		exclude := grepGlobs(input.Exclude)
+		maxResults := limit(input.MaxResults, defaultGrepMaxResults, maxGrepMaxResults)
+		contextLines := min(max(input.Context, 0), maxGrepContext)
+
+		if _, err := os.Stat(root); err != nil {
+			return GrepOutput{Error: err.Error()}, nil
+		}
+
+		var output GrepOutput
+		var skipped []string
+		err = gitignore.Walk(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if d == nil {
+					return err
+				}
+				skipped = append(skipped, err.Error())
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			rel = filepath.ToSlash(rel)
+			if rel == "." && !d.IsDir() {
+				// Path is a single file; match the globs against its name.
+				rel = d.Name()
+			}
+			if _, err := workspace.Read(ctx, path); err != nil || (rel != "." && exclude.match(rel, d.IsDir())) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || len(include) > 0 && !include.match(rel, false) {
+				return nil
+			}
+
+			matches, searched, err := grepFile(path, re, contextLines, maxResults-len(output.Matches))
+			if err != nil {
+				skipped = append(skipped, err.Error())
+				return nil
+			}
+			if searched {
+				output.FilesSearched++
+			}
+			output.Matches = append(output.Matches, matches...)
+			if len(output.Matches) >= maxResults {
+				return errGrepLimit
+			}
+			return nil
+		})
+		if errors.Is(err, errGrepLimit) {
+			output.Truncated = true
+			output.Notice = fmt.Sprintf("Stopped after %d matches; narrow the pattern, path or include globs, or raise max_results, to see more.", maxResults)
+		} else if err != nil {
+			return GrepOutput{}, fmt.Errorf("failed to search %s: %w", root, err)
+		}
+		if len(skipped) > 0 {
+			output.Notice = strings.TrimSpace(output.Notice + fmt.Sprintf(" %d paths could not be read: %s", len(skipped), strings.Join(skipped, "; ")))
+		}
+		return output, nil
+	})
+}
+
+// grepFile searches a single file. Binary and oversized files are skipped with searched false.
+func grepFile(path string, re *regexp.Regexp, contextLines, limit int) (matches []GrepMatch, searched bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() || info.Size() > maxGrepFileSize {
+		return nil, false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if fileutil.IsBinary(content) {
+		return nil, false, nil
+	}
+
+	lines := fileutil.SplitLines(content)
+	for i, line := range lines {
+		loc := re.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		match := GrepMatch{
+			File:   path,
+			Line:   i + 1,
+			Column: loc[0] + 1,
+			Match:  shorten(line[loc[0]:loc[1]]),
+			Text:   shorten(line),
+		}
+		if contextLines > 0 {
+			match.Before = shortenAll(lines[max(i-contextLines, 0):i])
+			match.After = shortenAll(lines[i+1 : min(i+1+contextLines, len(lines))])
+		}
+		matches = append(matches, match)
+		if len(matches) >= limit {
+			break
+		}
+	}
+	return matches, true, nil
+}
+
+func shorten(line string) string {
+	return fileutil.TruncateLine(line, maxGrepLineLength)
+}
+
+func shortenAll(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = shorten(line)
+	}
+	return out
+}
+
+// grepGlobList is a list of include or exclude globs in gitignore syntax.
+type grepGlobList []gitignore.Pattern
+
+func grepGlobs(globs []string) grepGlobList {
+	var list grepGlobList
+	for _, glob := range globs {
+		if p, ok := gitignore.ParsePattern(glob); ok && !p.Negate {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+func (l grepGlobList) match(rel string, isDir bool) bool {
+	for _, p := range l {
+		if p.Match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
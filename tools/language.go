@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/language"
+)
+
+// resolveLanguage returns the language a code search tool runs with: lang, which may be
+// an alias like "golang" or "ts", or when it is empty, the language detected from the
+// files under basePath, among those the tool supports. A failure is returned as a
+// message for the model.
+func resolveLanguage(ctx context.Context, lang language.Language, basePath string, c language.Capability) (language.Language, string) {
+	if lang != "" {
+		parsed, ok := language.Parse(string(lang))
+		if !ok {
+			return "", unsupportedLanguage(lang, c)
+		}
+		lang = parsed
+	} else {
+		root, err := searchRoot(ctx, basePath)
+		if err != nil {
+			return "", err.Error()
+		}
+		lang, err = language.Detect(root, language.Supported(c)...)
+		if err != nil {
+			return "", fmt.Sprintf("Set language: %v", err)
+		}
+	}
+	if !language.Supports(lang, c) {
+		return "", unsupportedLanguage(lang, c)
+	}
+	return lang, ""
+}
+
+func unsupportedLanguage(lang language.Language, c language.Capability) string {
+	supported := language.Supported(c)
+	names := make([]string, len(supported))
+	for i, l := range supported {
+		names[i] = string(l)
+	}
+	return fmt.Sprintf("Unsupported language: %s. %s supports %s.", lang, c, strings.Join(names, ", "))
+}
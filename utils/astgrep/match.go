@@ -0,0 +1,57 @@
+package astgrep
+
+// Position is a zero-based line and column in a file.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Range is the span of a match.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// MetaVariable is the code bound to a metavariable.
+type MetaVariable struct {
+	Text  string `json:"text"`
+	Range Range  `json:"range"`
+}
+
+// MetaVariables holds the metavariables of a match: $NAME in Single, $$$NAME in Multi.
+type MetaVariables struct {
+	Single map[string]MetaVariable   `json:"single"`
+	Multi  map[string][]MetaVariable `json:"multi"`
+}
+
+// Match is a node matched by a rule, as reported by ast-grep with --json.
+type Match struct {
+	RuleID   string `json:"ruleId"`
+	File     string `json:"file"`
+	Language string `json:"language"`
+	// Text is the matched node; Lines are the whole source lines it spans.
+	Text          string        `json:"text"`
+	Lines         string        `json:"lines"`
+	Range         Range         `json:"range"`
+	MetaVariables MetaVariables `json:"metaVariables"`
+}
+
+// Line returns the one-based line the match starts on.
+func (m Match) Line() int {
+	return m.Range.Start.Line + 1
+}
+
+// EndLine returns the one-based line the match ends on.
+func (m Match) EndLine() int {
+	return m.Range.End.Line + 1
+}
+
+// Column returns the one-based column the match starts at.
+func (m Match) Column() int {
+	return m.Range.Start.Column + 1
+}
+
+// Var returns the text bound to the single metavariable name, without the "$".
+func (m Match) Var(name string) string {
+	return m.MetaVariables.Single[name].Text
+}
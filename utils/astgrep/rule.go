@@ -0,0 +1,122 @@
+// Package astgrep runs ast-grep rules and decodes their matches.
+//
+// Rules are built as Go values and handed to ast-grep as JSON, which is valid YAML, so
+// names taken from user input cannot break a rule. Match names with Named, Exact and Word
+// rather than formatting them into a Pattern, where they would be parsed as code.
+package astgrep
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/language"
+)
+
+// StopBy values for relational rules.
+const (
+	// StopByNeighbor only looks at direct children or parents, the default.
+	StopByNeighbor = "neighbor"
+	// StopByEnd searches all descendants or ancestors.
+	StopByEnd = "end"
+)
+
+// Rule is an ast-grep rule object. Empty fields are left out.
+type Rule struct {
+	Pattern string `json:"pattern,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Regex   string `json:"regex,omitempty"`
+
+	// Field restricts a Has or Inside rule to the node in this field of its parent.
+	Field  string `json:"field,omitempty"`
+	StopBy string `json:"stopBy,omitempty"`
+	Has    *Rule  `json:"has,omitempty"`
+	Inside *Rule  `json:"inside,omitempty"`
+
+	All []Rule `json:"all,omitempty"`
+	Any []Rule `json:"any,omitempty"`
+	Not *Rule  `json:"not,omitempty"`
+}
+
+// Config is a rule with its id and language, like a rule file.
+type Config struct {
+	ID       string            `json:"id"`
+	Language language.Language `json:"language"`
+	Rule     Rule              `json:"rule"`
+}
+
+// Pattern matches code like pattern, where $NAME and $$$ are metavariables.
+func Pattern(pattern string) Rule {
+	return Rule{Pattern: pattern}
+}
+
+// Kind matches nodes of the tree-sitter kind.
+func Kind(kind string) Rule {
+	return Rule{Kind: kind}
+}
+
+// Any matches nodes matching any of rules.
+func Any(rules ...Rule) Rule {
+	return Rule{Any: rules}
+}
+
+// All matches nodes matching all of rules.
+func All(rules ...Rule) Rule {
+	return Rule{All: rules}
+}
+
+// Named matches nodes of kind whose name field is exactly name.
+func Named(kind, name string) Rule {
+	return Rule{Kind: kind, Has: &Rule{Field: "name", Regex: Exact(name)}}
+}
+
+// WithHas returns r requiring a child, or with StopByEnd a descendant, matching sub.
+func (r Rule) WithHas(sub Rule) Rule {
+	r.Has = &sub
+	return r
+}
+
+// WithInside returns r requiring a parent, or with StopByEnd an ancestor, matching sub.
+func (r Rule) WithInside(sub Rule) Rule {
+	r.Inside = &sub
+	return r
+}
+
+// InField returns r restricted to the field of the parent, for use in Has and Inside.
+func (r Rule) InField(field string) Rule {
+	r.Field = field
+	return r
+}
+
+// Anywhere returns r searching all descendants or ancestors, for use in Has and Inside.
+func (r Rule) Anywhere() Rule {
+	r.StopBy = StopByEnd
+	return r
+}
+
+// Exact returns a regular expression matching s and nothing else.
+func Exact(s string) string {
+	return "^" + regexp.QuoteMeta(s) + "$"
+}
+
+// Word returns a regular expression matching s as a whole word.
+func Word(s string) string {
+	return `\b` + regexp.QuoteMeta(s) + `\b`
+}
+
+// Document renders configs as a multi-document rule file.
+func Document(configs ...Config) (string, error) {
+	docs := make([]string, len(configs))
+	for i, c := range configs {
+		if c.ID == "" || c.Language == "" {
+			return "", fmt.Errorf("rule %d needs an id and a language", i)
+		}
+		b, err := json.Marshal(c)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode rule %s: %w", c.ID, err)
+		}
+		docs[i] = string(b)
+	}
+	return strings.Join(docs, "\n---\n"), nil
+}
@@ -0,0 +1,71 @@
+package astgrep
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var trickyNames = []string{"it's", "key: value", "a.*b", "$NAME", "$$$", `back\slash`}
+
+func TestExact(t *testing.T) {
+	for _, name := range trickyNames {
+		re, err := regexp.Compile(Exact(name))
+		if err != nil {
+			t.Fatalf("Exact(%q) does not compile: %v", name, err)
+		}
+		if !re.MatchString(name) {
+			t.Errorf("Exact(%q) does not match itself", name)
+		}
+		for _, other := range []string{name + "x", "x" + name, "azzzb"} {
+			if re.MatchString(other) {
+				t.Errorf("Exact(%q) matches %q", name, other)
+			}
+		}
+	}
+}
+
+func TestDocumentKeepsNamesIntact(t *testing.T) {
+	var configs []Config
+	for i, name := range trickyNames {
+		configs = append(configs, Config{
+			ID:       "rule-" + string(rune('a'+i)),
+			Language: "go",
+			Rule:     Named("function_declaration", name),
+		})
+	}
+
+	doc, err := Document(configs...)
+	if err != nil {
+		t.Fatalf("Document: %v", err)
+	}
+	parts := strings.Split(doc, "\n---\n")
+	if len(parts) != len(configs) {
+		t.Fatalf("Document has %d parts, want %d", len(parts), len(configs))
+	}
+	for i, part := range parts {
+		var got Config
+		if err := json.Unmarshal([]byte(part), &got); err != nil {
+			t.Fatalf("part %d is not valid JSON: %v\n%s", i, err, part)
+		}
+		if got.ID != configs[i].ID || got.Rule.Kind != "function_declaration" || got.Rule.Has == nil {
+			t.Fatalf("part %d decoded to %+v", i, got)
+		}
+		if got.Rule.Pattern != "" {
+			t.Errorf("part %d puts the name in a pattern: %q", i, got.Rule.Pattern)
+		}
+		if got.Rule.Has.Field != "name" || got.Rule.Has.Regex != Exact(trickyNames[i]) {
+			t.Errorf("part %d does not match %q exactly: %+v", i, trickyNames[i], got.Rule.Has)
+		}
+	}
+}
+
+func TestDocumentRequiresIDAndLanguage(t *testing.T) {
+	if _, err := Document(Config{Language: "go", Rule: Kind("identifier")}); err == nil {
+		t.Error("Document accepted a rule without an id")
+	}
+	if _, err := Document(Config{ID: "r", Rule: Kind("identifier")}); err == nil {
+		t.Error("Document accepted a rule without a language")
+	}
+}
@@ -0,0 +1,118 @@
+package astgrep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotInstalled is returned when no ast-grep binary is found.
+var ErrNotInstalled = errors.New("ast-grep is not installed; install it from https://ast-grep.github.io to search code in this language")
+
+// DefaultTimeout bounds a scan when the Runner sets no timeout.
+const DefaultTimeout = 2 * time.Minute
+
+// binaries are the names ast-grep is installed under. "sg" comes first but is also the
+// name of a shadow-utils command on many Linux systems, so the version output is checked.
+var binaries = []string{"sg", "ast-grep"}
+
+// Runner runs ast-grep. The zero value looks the binary up on PATH.
+type Runner struct {
+	// Binary is the path or name of the ast-grep executable. Empty tries "sg" and "ast-grep".
+	Binary  string
+	Timeout time.Duration
+
+	once     sync.Once
+	resolved string
+	err      error
+}
+
+// Default is the runner used by the tools.
+var Default = &Runner{}
+
+// versionTimeout bounds the "--version" probe of a candidate binary.
+const versionTimeout = 10 * time.Second
+
+// Available returns the ast-grep binary the runner uses, or ErrNotInstalled. The lookup
+// is done once, and is not cut short by ctx being canceled.
+func (r *Runner) Available(ctx context.Context) (string, error) {
+	r.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), versionTimeout)
+		defer cancel()
+
+		candidates := binaries
+		if r.Binary != "" {
+			candidates = []string{r.Binary}
+		}
+		r.err = ErrNotInstalled
+		for _, name := range candidates {
+			path, err := exec.LookPath(name)
+			if err != nil {
+				continue
+			}
+			out, err := exec.CommandContext(ctx, path, "--version").Output()
+			if err == nil && strings.Contains(strings.ToLower(string(out)), "ast-grep") {
+				r.resolved, r.err = path, nil
+				return
+			}
+		}
+	})
+	return r.resolved, r.err
+}
+
+// Scan runs configs over paths, or the current directory if none are given, and returns
+// the matches in the order ast-grep reports them.
+func (r *Runner) Scan(ctx context.Context, paths []string, configs ...Config) ([]Match, error) {
+	bin, err := r.Available(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := Document(configs...)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := append([]string{"scan", "--inline-rules", rules, "--json"}, paths...)
+	cmd := exec.CommandContext(ctx, bin, args...)
+	// Do not wait for processes ast-grep left behind holding the output open.
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("ast-grep timed out after %s", timeout)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// A scan exits non-zero when an error severity rule matches, with the matches on stdout.
+	var matches []Match
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &matches); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("failed to run ast-grep: %w: %s", runErr, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("failed to parse ast-grep output: %w", err)
+	}
+	return matches, nil
+}
+
+// Scan runs configs with the Default runner.
+func Scan(ctx context.Context, paths []string, configs ...Config) ([]Match, error) {
+	return Default.Scan(ctx, paths, configs...)
+}
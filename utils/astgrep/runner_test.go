@@ -0,0 +1,118 @@
+package astgrep
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeVersion answers the --version probe like ast-grep does.
+const fakeVersion = `if [ "$1" = "--version" ]; then echo "ast-grep 0.0.0"; exit 0; fi
+`
+
+// writeScript writes an executable shell script called name into dir and returns its path.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binaries are shell scripts")
+	}
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+var identifierRule = Config{ID: "r", Language: "go", Rule: Kind("identifier")}
+
+func TestAvailableRejectsShadowUtilsSg(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "sg", "echo 'Usage: sg group [[-c] command]' >&2\nexit 1\n")
+	want := writeScript(t, dir, "ast-grep", fakeVersion)
+	t.Setenv("PATH", dir)
+
+	got, err := (&Runner{}).Available(context.Background())
+	if err != nil {
+		t.Fatalf("Available: %v", err)
+	}
+	if got != want {
+		t.Errorf("Available = %s, want %s", got, want)
+	}
+}
+
+func TestAvailableNotInstalled(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "sg", "echo 'sg from shadow-utils'\n")
+	t.Setenv("PATH", dir)
+
+	if _, err := (&Runner{}).Available(context.Background()); !errors.Is(err, ErrNotInstalled) {
+		t.Fatalf("Available error = %v, want ErrNotInstalled", err)
+	}
+	if _, err := (&Runner{}).Scan(context.Background(), nil, identifierRule); !errors.Is(err, ErrNotInstalled) {
+		t.Fatalf("Scan error = %v, want ErrNotInstalled", err)
+	}
+}
+
+func TestScanFailureCarriesStderr(t *testing.T) {
+	bin := writeScript(t, t.TempDir(), "sg", fakeVersion+"echo 'Error: cannot parse rule' >&2\nexit 2\n")
+
+	_, err := (&Runner{Binary: bin}).Scan(context.Background(), nil, identifierRule)
+	if err == nil || !strings.Contains(err.Error(), "cannot parse rule") {
+		t.Fatalf("Scan error = %v, want the stderr of ast-grep", err)
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	bin := writeScript(t, t.TempDir(), "sg", fakeVersion+"exec sleep 10\n")
+
+	start := time.Now()
+	_, err := (&Runner{Binary: bin, Timeout: 100 * time.Millisecond}).Scan(context.Background(), nil, identifierRule)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Scan error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Scan took %s to time out", elapsed)
+	}
+}
+
+func TestScanDecodesMatches(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	output := `[{"ruleId":"find","file":"a.go","language":"Go","text":"func Load() {}","lines":"func Load() {}",` +
+		`"range":{"start":{"line":2,"column":0},"end":{"line":4,"column":1}},` +
+		`"metaVariables":{"single":{"NAME":{"text":"Load","range":{"start":{"line":2,"column":5},"end":{"line":2,"column":9}}}},"multi":{}}}]`
+	bin := writeScript(t, dir, "sg", fakeVersion+
+		`printf '%s\n' "$@" > '`+argsFile+"'\n"+
+		"echo '"+output+"'\n")
+
+	config := Config{ID: "find", Language: "go", Rule: Named("function_declaration", "Load")}
+	matches, err := (&Runner{Binary: bin}).Scan(context.Background(), []string{"a.go"}, config)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Scan returned %d matches, want 1", len(matches))
+	}
+	m := matches[0]
+	if m.RuleID != "find" || m.File != "a.go" || m.Line() != 3 || m.EndLine() != 5 || m.Column() != 1 || m.Var("NAME") != "Load" {
+		t.Errorf("unexpected match %+v", m)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := Document(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{"scan", "--inline-rules", rules, "--json", "a.go"}, "\n") + "\n"
+	if string(args) != want {
+		t.Errorf("ast-grep called with\n%s\nwant\n%s", args, want)
+	}
+}
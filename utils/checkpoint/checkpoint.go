@@ -0,0 +1,127 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/useful-genkit/utils/state"
+)
+
+const manifestFileName = "manifest.json"
+
+// Entry records a file that a run has already handled.
+type Entry struct {
+	File          string    `json:"file"`
+	Status        string    `json:"status"`
+	InputHash     string    `json:"input_hash"`
+	OutputHash    string    `json:"output_hash,omitempty"`
+	PromptVersion string    `json:"prompt_version"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Manifest is the checkpoint of a single run. It is saved after every recorded file,
+// so an interrupted run can be resumed with the same run ID.
+type Manifest struct {
+	RunID   string           `json:"run_id"`
+	Flow    string           `json:"flow"`
+	Entries map[string]Entry `json:"entries"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// Open loads the manifest of the given run, or starts an empty one.
+func Open(runID, flow string) (*Manifest, error) {
+	dir, err := state.RunDir(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		RunID:   runID,
+		Flow:    flow,
+		Entries: make(map[string]Entry),
+		path:    filepath.Join(dir, manifestFileName),
+	}
+
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint manifest: %w", err)
+	}
+	if m.Flow != flow {
+		return nil, fmt.Errorf("run %s belongs to flow %s, not %s", runID, m.Flow, flow)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]Entry)
+	}
+	return m, nil
+}
+
+// Done reports whether the file was already handled with the same prompt version
+// and has not changed since, either before or after the run rewrote it.
+func (m *Manifest) Done(file, hash, promptVersion string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.Entries[key(file)]
+	if !ok || e.PromptVersion != promptVersion {
+		return false
+	}
+	return e.InputHash == hash || (e.OutputHash != "" && e.OutputHash == hash)
+}
+
+// Record stores the entry and saves the manifest.
+func (m *Manifest) Record(e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.UpdatedAt = time.Now()
+	m.Entries[key(e.File)] = e
+	return m.save()
+}
+
+func (m *Manifest) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint manifest: %w", err)
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint manifest: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint manifest: %w", err)
+	}
+	return nil
+}
+
+func key(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
+
+// Discard deletes the manifest of the given run, so a resumed run processes every file again.
+func Discard(runID string) error {
+	dir, err := state.RunDir(runID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, manifestFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint manifest: %w", err)
+	}
+	return nil
+}
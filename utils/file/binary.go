@@ -0,0 +1,34 @@
+package file
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of a file IsBinary looks at, as git does.
+const sniffLen = 8000
+
+// IsBinary reports whether content looks like binary data rather than text: it contains a
+// NUL byte or is not valid UTF-8 within its first 8000 bytes.
+func IsBinary(content []byte) bool {
+	if len(content) > sniffLen {
+		content = content[:sniffLen]
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return true
+	}
+	return !validUTF8Prefix(content)
+}
+
+// validUTF8Prefix reports whether content is valid UTF-8, allowing a rune cut in half at
+// the end of the sniffed prefix.
+func validUTF8Prefix(content []byte) bool {
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		if r == utf8.RuneError && size == 1 {
+			return !utf8.FullRune(content)
+		}
+		content = content[size:]
+	}
+	return true
+}
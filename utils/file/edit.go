@@ -0,0 +1,145 @@
+package file
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// EditOp is the kind of a line-anchored edit.
+type EditOp string
+
+const (
+	// EditInsertAfter inserts Text after line Start. Start 0 inserts at the top of the file.
+	EditInsertAfter EditOp = "insert_after"
+	// EditReplace replaces lines Start..End with Text.
+	EditReplace EditOp = "replace"
+	// EditRemove removes lines Start..End.
+	EditRemove EditOp = "remove"
+)
+
+// Edit is a single operation against the 1-based line numbers of the original content,
+// as shown by AttachLineNumbers. End is inclusive and defaults to Start.
+type Edit struct {
+	Op    EditOp `json:"op"`
+	Start int    `json:"start"`
+	End   int    `json:"end,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+func (e Edit) end() int {
+	if e.End == 0 {
+		return e.Start
+	}
+	return e.End
+}
+
+func (e Edit) String() string {
+	if e.Op == EditInsertAfter || e.end() == e.Start {
+		return fmt.Sprintf("%s %d", e.Op, e.Start)
+	}
+	return fmt.Sprintf("%s %d-%d", e.Op, e.Start, e.end())
+}
+
+// SplitLines splits content into lines, normalizing CRLF. A trailing newline does not
+// start an extra empty line.
+func SplitLines(content []byte) []string {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.Split(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// ValidateEdits checks that every edit is in range and that no two edits touch the same lines.
+// All line numbers refer to the original content, so the edits are independent of their order.
+func ValidateEdits(lineCount int, edits []Edit) error {
+	changed := make(map[int]Edit)
+	for _, e := range edits {
+		if e.Op == EditReplace || e.Op == EditRemove {
+			if e.Start < 1 || e.end() < e.Start || e.end() > lineCount {
+				return fmt.Errorf("%s: lines out of range 1-%d", e, lineCount)
+			}
+			for n := e.Start; n <= e.end(); n++ {
+				if other, ok := changed[n]; ok {
+					return fmt.Errorf("%s overlaps %s at line %d", e, other, n)
+				}
+				changed[n] = e
+			}
+		}
+	}
+
+	for _, e := range edits {
+		switch e.Op {
+		case EditReplace, EditRemove:
+		case EditInsertAfter:
+			if e.Start < 0 || e.Start > lineCount {
+				return fmt.Errorf("%s: line out of range 0-%d", e, lineCount)
+			}
+			// Inserting after the last line of a changed range is fine; inside it is ambiguous.
+			if other, ok := changed[e.Start]; ok && e.Start != other.end() {
+				return fmt.Errorf("%s falls inside %s", e, other)
+			}
+		default:
+			return fmt.Errorf("unknown edit operation %q", e.Op)
+		}
+	}
+	return nil
+}
+
+// ApplyEdits validates the edits and applies them to content with InsertLineAfter,
+// RemoveLine and ReplaceLine. Line endings and the presence of a trailing newline are
+// preserved.
+func ApplyEdits(content []byte, edits []Edit) ([]byte, error) {
+	lines, newline, trailing := splitContent(content)
+	if err := ValidateEdits(len(lines), edits); err != nil {
+		return nil, err
+	}
+	// Work on LF with a trailing newline so that intermediate results cannot change how
+	// the helpers detect either; the original style is restored at the end.
+	content = joinContent(lines, "\n", true)
+
+	// Apply from the bottom up so that line numbers of the original content stay valid.
+	// An insertion after line N sorts above an edit starting at N, so it lands after a
+	// replacement ending there, and insertions at the same line keep their given order.
+	order := make([]int, len(edits))
+	for i := range order {
+		order[i] = i
+	}
+	key := func(e Edit) int {
+		if e.Op == EditInsertAfter {
+			return 2*e.Start + 1
+		}
+		return 2 * e.Start
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if c := cmp.Compare(key(edits[b]), key(edits[a])); c != 0 {
+			return c
+		}
+		return cmp.Compare(b, a)
+	})
+
+	for _, i := range order {
+		e := edits[i]
+		switch e.Op {
+		case EditInsertAfter:
+			content = InsertLineAfter(content, InsertLineAfterOptions{Number: e.Start, Line: e.Text})
+		case EditReplace, EditRemove:
+			for n := e.end(); n > e.Start; n-- {
+				content = RemoveLine(content, RemoveLineOptions{Number: n})
+			}
+			if e.Op == EditReplace {
+				content = ReplaceLine(content, ReplaceLineOptions{Number: e.Start, Line: e.Text})
+			} else {
+				content = RemoveLine(content, RemoveLineOptions{Number: e.Start})
+			}
+		}
+	}
+	return joinContent(SplitLines(content), newline, trailing), nil
+}
@@ -0,0 +1,75 @@
+package file
+
+import "testing"
+
+func TestApplyEdits(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edits   []Edit
+		want    string
+	}{
+		{
+			name:    "replace then insert at the same line",
+			content: "a\nb\nc\n",
+			edits: []Edit{
+				{Op: EditInsertAfter, Start: 2, Text: "y"},
+				{Op: EditReplace, Start: 1, End: 2, Text: "x"},
+			},
+			want: "x\ny\nc\n",
+		},
+		{
+			name:    "inserts keep their order",
+			content: "a\n",
+			edits: []Edit{
+				{Op: EditInsertAfter, Start: 0, Text: "1"},
+				{Op: EditInsertAfter, Start: 0, Text: "2"},
+				{Op: EditInsertAfter, Start: 1, Text: "3\n4"},
+			},
+			want: "1\n2\na\n3\n4\n",
+		},
+		{
+			name:    "remove",
+			content: "a\nb\nc\nd\n",
+			edits:   []Edit{{Op: EditRemove, Start: 2, End: 3}},
+			want:    "a\nd\n",
+		},
+		{
+			name:    "crlf without trailing newline",
+			content: "a\r\nb",
+			edits: []Edit{
+				{Op: EditRemove, Start: 2},
+				{Op: EditInsertAfter, Start: 0, Text: "x"},
+			},
+			want: "x\r\na",
+		},
+		{
+			name:    "empty content",
+			content: "",
+			edits:   []Edit{{Op: EditInsertAfter, Start: 0, Text: "a"}},
+			want:    "a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyEdits([]byte(tt.content), tt.edits)
+			if err != nil {
+				t.Fatalf("ApplyEdits: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ApplyEdits = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEditsRejectsOverlap(t *testing.T) {
+	edits := []Edit{
+		{Op: EditReplace, Start: 1, End: 2, Text: "x"},
+		{Op: EditRemove, Start: 2},
+	}
+	if _, err := ApplyEdits([]byte("a\nb\n"), edits); err == nil {
+		t.Fatal("ApplyEdits accepted overlapping edits")
+	}
+}
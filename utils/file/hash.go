@@ -0,0 +1,14 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Hash returns the hex SHA-256 of content. Tools hand it out with file content so that
+// later edits can be checked against the version that was read, and checkpoints key
+// files by it.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
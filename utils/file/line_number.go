@@ -3,55 +3,70 @@ package file
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"slices"
+	"strings"
 )
 
 func AttachLineNumbers(content []byte) []byte {
-	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
-	lines := bytes.Split(content, []byte("\n"))
+	return AttachLineNumbersFrom(SplitLines(content), 1)
+}
+
+// AttachLineNumbersFrom numbers lines like AttachLineNumbers, starting at first, for
+// showing an excerpt of a file.
+func AttachLineNumbersFrom(lines []string, first int) []byte {
 	var buf bytes.Buffer
 	for i, line := range lines {
-		fmt.Fprintf(&buf, "%4d: %s\n", i+1, line)
+		fmt.Fprintf(&buf, "%4d: %s\n", first+i, line)
 	}
 	return buf.Bytes()
 }
 
+var lineNumberPrefix = regexp.MustCompile(`^ *\d+: ?`)
+
+// StripLineNumbers removes AttachLineNumbers prefixes from text, but only when every
+// line carries one, so code that merely looks numbered is left alone.
+func StripLineNumbers(text string) string {
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		if !lineNumberPrefix.MatchString(line) {
+			return text
+		}
+	}
+	for i, line := range lines {
+		lines[i] = lineNumberPrefix.ReplaceAllString(line, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
 type InsertLineAfterOptions struct {
 	Number int
 	Line   string
 }
 
+// InsertLineAfter inserts Line after line Number, or at the top of the file when Number
+// is 0. Line may span several lines. Out-of-range numbers leave content unchanged.
 func InsertLineAfter(content []byte, opts InsertLineAfterOptions) []byte {
-	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
-	lines := bytes.Split(content, []byte("\n"))
-	var buf bytes.Buffer
-	for i, line := range lines {
-		if i+1 == opts.Number {
-			fmt.Fprintf(&buf, "%s\n%s\n", line, opts.Line)
-		} else {
-			fmt.Fprintf(&buf, "%s\n", line)
-		}
+	lines, newline, trailing := splitContent(content)
+	if opts.Number < 0 || opts.Number > len(lines) {
+		return content
 	}
-	return buf.Bytes()
+	lines = slices.Insert(lines, opts.Number, textLines(opts.Line)...)
+	return joinContent(lines, newline, trailing)
 }
 
 type RemoveLineOptions struct {
 	Number int
 }
 
+// RemoveLine removes line Number. Out-of-range numbers leave content unchanged.
 func RemoveLine(content []byte, opts RemoveLineOptions) []byte {
-	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
-	lines := bytes.Split(content, []byte("\n"))
-	var buf bytes.Buffer
-	for i, line := range lines {
-		if i+1 == opts.Number {
-			// Skip this line
-			continue
-		} else {
-			fmt.Fprintf(&buf, "%s\n", line)
-		}
+	lines, newline, trailing := splitContent(content)
+	if opts.Number < 1 || opts.Number > len(lines) {
+		return content
 	}
-	return buf.Bytes()
+	lines = slices.Delete(lines, opts.Number-1, opts.Number)
+	return joinContent(lines, newline, trailing)
 }
 
 type ReplaceLineOptions struct {
@@ -59,33 +74,51 @@ type ReplaceLineOptions struct {
 	Line   string
 }
 
+// ReplaceLine replaces line Number with Line, which may span several lines.
 func ReplaceLine(content []byte, opts ReplaceLineOptions) []byte {
-	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
-	lines := bytes.Split(content, []byte("\n"))
-	var buf bytes.Buffer
-	for i, line := range lines {
-		if i+1 == opts.Number {
-			fmt.Fprintf(&buf, "%s\n", opts.Line)
-		} else {
-			fmt.Fprintf(&buf, "%s\n", line)
-		}
-	}
-	return buf.Bytes()
+	return ReplaceLines(content, opts)
 }
 
+// ReplaceLines replaces each numbered line with its Line. Numbers refer to the original
+// content and out-of-range numbers are ignored.
 func ReplaceLines(content []byte, opts ...ReplaceLineOptions) []byte {
-	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
-	lines := bytes.Split(content, []byte("\n"))
-	var buf bytes.Buffer
+	lines, newline, trailing := splitContent(content)
+	var out []string
 	for i, line := range lines {
 		index := slices.IndexFunc(opts, func(o ReplaceLineOptions) bool {
 			return o.Number == i+1
 		})
 		if index != -1 {
-			fmt.Fprintf(&buf, "%s\n", opts[index].Line)
+			out = append(out, textLines(opts[index].Line)...)
 		} else {
-			fmt.Fprintf(&buf, "%s\n", line)
+			out = append(out, line)
 		}
 	}
-	return buf.Bytes()
+	return joinContent(out, newline, trailing)
+}
+
+// splitContent splits content into lines and reports its line ending and whether it ends
+// with one, so that joinContent can put it back together the same way.
+func splitContent(content []byte) (lines []string, newline string, trailing bool) {
+	newline = "\n"
+	if bytes.Contains(content, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	return SplitLines(content), newline, len(content) == 0 || bytes.HasSuffix(content, []byte("\n"))
+}
+
+func joinContent(lines []string, newline string, trailing bool) []byte {
+	result := strings.Join(lines, newline)
+	if len(lines) > 0 && trailing {
+		result += newline
+	}
+	return []byte(result)
+}
+
+// textLines splits inserted text into lines. Empty text is a single empty line.
+func textLines(text string) []string {
+	if text == "" {
+		return []string{""}
+	}
+	return SplitLines([]byte(text))
 }
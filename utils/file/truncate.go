@@ -0,0 +1,15 @@
+package file
+
+import "unicode/utf8"
+
+// TruncateLine shortens line to at most n bytes, backing off to the start of a rune so
+// that the result stays valid UTF-8, and marks it as truncated.
+func TruncateLine(line string, n int) string {
+	if len(line) <= n {
+		return line
+	}
+	for n > 0 && !utf8.RuneStart(line[n]) {
+		n--
+	}
+	return line[:n] + " [line truncated]"
+}
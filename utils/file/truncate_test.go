@@ -0,0 +1,29 @@
+package file
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		line string
+		n    int
+		want string
+	}{
+		{line: "short", n: 10, want: "short"},
+		{line: "abcdef", n: 3, want: "abc [line truncated]"},
+		{line: "가나다", n: 4, want: "가 [line truncated]"},
+		{line: "가나다", n: 6, want: "가나 [line truncated]"},
+		{line: "가", n: 2, want: " [line truncated]"},
+	}
+	for _, tt := range tests {
+		got := TruncateLine(tt.line, tt.n)
+		if got != tt.want {
+			t.Errorf("TruncateLine(%q, %d) = %q, want %q", tt.line, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("TruncateLine(%q, %d) is not valid UTF-8", tt.line, tt.n)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Run executes git in dir and returns its standard output.
+func Run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Root returns the top-level directory of the repository containing dir.
+func Root(ctx context.Context, dir string) (string, error) {
+	out, err := Run(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("failed to find repository root: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// MergeBase returns the best common ancestor of ref and HEAD.
+func MergeBase(ctx context.Context, dir, ref string) (string, error) {
+	out, err := Run(ctx, dir, "merge-base", ref, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge base with %s: %w", ref, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ChangedFiles returns the absolute paths of files added, copied, modified or renamed
+// between the merge base of ref and the working tree, and of untracked files that are
+// not ignored.
+func ChangedFiles(ctx context.Context, dir, ref string) ([]string, error) {
+	root, err := Root(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	base, err := MergeBase(ctx, dir, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := Run(ctx, root, "diff", "--name-only", "--diff-filter=ACMR", "-z", base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+
+	untracked, err := Run(ctx, root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	var files []string
+	for _, name := range strings.Split(out+untracked, "\x00") {
+		if name == "" {
+			continue
+		}
+		files = append(files, filepath.Join(root, filepath.FromSlash(name)))
+	}
+	return files, nil
+}
+
+// Hunk is an inclusive range of changed lines in the new version of a file.
+type Hunk struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Overlaps reports whether the hunk touches the inclusive line range [start, end].
+func (h Hunk) Overlaps(start, end int) bool {
+	return h.Start <= end && start <= h.End
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// ChangedHunks returns the changed line ranges of file between the merge base of ref
+// and the working tree. Pure deletions are reported as the line they follow, and an
+// untracked file is changed as a whole.
+func ChangedHunks(ctx context.Context, dir, ref, file string) ([]Hunk, error) {
+	base, err := MergeBase(ctx, dir, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := Run(ctx, filepath.Dir(file), "ls-files", "--others", "--exclude-standard", "--", filepath.Base(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check whether %s is tracked: %w", file, err)
+	}
+	if strings.TrimSpace(untracked) != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		lines := bytes.Count(content, []byte("\n"))
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			lines++
+		}
+		return []Hunk{{Start: 1, End: max(lines, 1)}}, nil
+	}
+
+	out, err := Run(ctx, filepath.Dir(file), "diff", "-U0", base, "--", filepath.Base(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s: %w", file, err)
+	}
+
+	var hunks []Hunk
+	for _, line := range strings.Split(out, "\n") {
+		m := hunkHeader.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		start, _ := strconv.Atoi(m[1])
+		count := 1
+		if m[2] != "" {
+			count, _ = strconv.Atoi(m[2])
+		}
+		if count == 0 {
+			hunks = append(hunks, Hunk{Start: max(start, 1), End: max(start, 1)})
+			continue
+		}
+		hunks = append(hunks, Hunk{Start: start, End: start + count - 1})
+	}
+	return hunks, nil
+}
+
+// IsClean reports whether the working tree has no staged, unstaged or untracked changes.
+func IsClean(ctx context.Context, dir string) (bool, error) {
+	out, err := Run(ctx, dir, "status", "--porcelain")
+	if err != nil {
+		return false, fmt.Errorf("failed to get status: %w", err)
+	}
+	return strings.TrimSpace(out) == "", nil
+}
+
+// HasChanges reports whether anything under dir differs from HEAD, including untracked files.
+func HasChanges(ctx context.Context, dir string) (bool, error) {
+	out, err := Run(ctx, dir, "status", "--porcelain", "--", ".")
+	if err != nil {
+		return false, fmt.Errorf("failed to get status: %w", err)
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+// CreateBranch creates a new branch from HEAD and switches to it.
+func CreateBranch(ctx context.Context, dir, name string) error {
+	if _, err := Run(ctx, dir, "checkout", "-b", name); err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", name, err)
+	}
+	return nil
+}
+
+// BranchExists reports whether a local branch with the given name exists.
+func BranchExists(ctx context.Context, dir, name string) (bool, error) {
+	out, err := Run(ctx, dir, "branch", "--list", name)
+	if err != nil {
+		return false, fmt.Errorf("failed to list branches: %w", err)
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+// Checkout switches to an existing branch.
+func Checkout(ctx context.Context, dir, name string) error {
+	if _, err := Run(ctx, dir, "checkout", name); err != nil {
+		return fmt.Errorf("failed to check out branch %s: %w", name, err)
+	}
+	return nil
+}
+
+// Commit stages the given paths and commits only them. With no paths, every change
+// under dir is committed. It returns the new commit hash.
+func Commit(ctx context.Context, dir, message string, paths ...string) (string, error) {
+	addArgs := []string{"add", "-A", "--"}
+	if len(paths) == 0 {
+		addArgs = append(addArgs, ".")
+	}
+	if _, err := Run(ctx, dir, append(addArgs, paths...)...); err != nil {
+		return "", fmt.Errorf("failed to stage changes: %w", err)
+	}
+
+	commitArgs := []string{"commit", "-q", "-m", message}
+	if len(paths) > 0 {
+		commitArgs = append(commitArgs, "--")
+		commitArgs = append(commitArgs, paths...)
+	}
+	if _, err := Run(ctx, dir, commitArgs...); err != nil {
+		return "", fmt.Errorf("failed to commit: %w", err)
+	}
+
+	out, err := Run(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit hash: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
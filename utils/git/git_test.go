@@ -0,0 +1,83 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found on PATH")
+	}
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(env, "test")
+	}
+	for _, env := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(env, "test@example.com")
+	}
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "tracked.go", "package a\n")
+	writeFile(t, dir, ".gitignore", "ignored.go\n")
+	for _, args := range [][]string{{"init", "-q", "-b", "main"}, {"add", "-A"}, {"commit", "-q", "-m", "initial"}} {
+		if _, err := Run(context.Background(), dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangedFilesIncludesUntracked(t *testing.T) {
+	dir := initRepo(t)
+	writeFile(t, dir, "tracked.go", "package a\n\nvar x int\n")
+	writeFile(t, dir, "new.go", "package a\n")
+	writeFile(t, dir, "ignored.go", "package a\n")
+
+	files, err := ChangedFiles(context.Background(), dir, "main")
+	if err != nil {
+		t.Fatalf("ChangedFiles: %v", err)
+	}
+	slices.Sort(files)
+	want := []string{filepath.Join(dir, "new.go"), filepath.Join(dir, "tracked.go")}
+	if !slices.Equal(files, want) {
+		t.Errorf("ChangedFiles = %v, want %v", files, want)
+	}
+}
+
+func TestChangedHunks(t *testing.T) {
+	dir := initRepo(t)
+	writeFile(t, dir, "tracked.go", "package a\n\nvar x int\n")
+	writeFile(t, dir, "new.go", "package a\n\nvar y int")
+
+	tests := []struct {
+		file string
+		want []Hunk
+	}{
+		{file: "tracked.go", want: []Hunk{{Start: 2, End: 3}}},
+		{file: "new.go", want: []Hunk{{Start: 1, End: 3}}},
+	}
+	for _, tt := range tests {
+		got, err := ChangedHunks(context.Background(), dir, "main", filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatalf("ChangedHunks(%s): %v", tt.file, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ChangedHunks(%s) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
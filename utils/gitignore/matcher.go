@@ -0,0 +1,196 @@
+package gitignore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of the files holding ignore rules.
+const FileName = ".gitignore"
+
+type rule struct {
+	// base is the slash-separated directory the pattern is relative to, "" for the root.
+	base    string
+	pattern Pattern
+}
+
+// Matcher holds the ignore rules of a directory tree. Rules added later take precedence,
+// so the rules of a directory must be added after those of its parents.
+type Matcher struct {
+	rules []rule
+}
+
+// New creates a matcher with no rules.
+func New() *Matcher {
+	return &Matcher{}
+}
+
+// AddPatterns adds patterns relative to base, a slash-separated directory relative to the root.
+func (m *Matcher) AddPatterns(base string, lines []string) {
+	base = strings.Trim(path.Clean("/"+base), "/")
+	for _, line := range lines {
+		if p, ok := ParsePattern(line); ok {
+			m.rules = append(m.rules, rule{base: base, pattern: p})
+		}
+	}
+}
+
+// AddFile adds the patterns of the ignore file at file, relative to the directory base.
+// A missing file is not an error.
+func (m *Matcher) AddFile(base, file string) error {
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	m.AddPatterns(base, lines)
+	return nil
+}
+
+// Match reports whether rel, a slash-separated path relative to the root, is ignored.
+// The last matching rule decides. Parent directories are not checked, so callers walking
+// a tree must skip ignored directories themselves, as Walk does.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	rel = strings.Trim(path.Clean("/"+rel), "/")
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := m.rules[i]
+		sub := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			sub = rel[len(r.base)+1:]
+		}
+		if r.pattern.Match(sub, isDir) {
+			return !r.pattern.Negate
+		}
+	}
+	return false
+}
+
+// Walk walks the tree at root like filepath.WalkDir, reading the .gitignore file of every
+// directory on the way. When root is inside a git repository, .git/info/exclude and the
+// .gitignore files of the directories between the repository root and root apply too.
+// Ignored files and directories, and .git directories, are not visited.
+func Walk(root string, fn fs.WalkDirFunc) error {
+	m := New()
+	prefix, err := m.addParents(root)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fn(p, d, err)
+		}
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			return fmt.Errorf("failed to resolve %s: %w", p, relErr)
+		}
+		rel = filepath.ToSlash(rel)
+		// full is relative to the repository root, which the rules are relative to.
+		full := path.Join(prefix, rel)
+
+		if rel != "." {
+			if d.IsDir() && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if m.Match(full, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if d.IsDir() {
+			base := full
+			if base == "." {
+				base = ""
+			}
+			if err := m.AddFile(base, filepath.Join(p, FileName)); err != nil {
+				return err
+			}
+		}
+		return fn(p, d, nil)
+	})
+}
+
+// addParents adds .git/info/exclude and the .gitignore files of the directories above root,
+// up to the root of the git repository containing it. It returns the slash-separated path
+// of root relative to the repository root, or "" when root is not inside a repository.
+func (m *Matcher) addParents(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+	dir := abs
+	if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+		dir = filepath.Dir(abs)
+	}
+
+	repo := findRepository(dir)
+	if repo == "" {
+		return "", nil
+	}
+	if err := m.AddFile("", filepath.Join(repo, ".git", "info", "exclude")); err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(repo, dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	// The .gitignore of dir itself is read by the walk.
+	parent := repo
+	base := ""
+	if rel != "." {
+		for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+			if err := m.AddFile(base, filepath.Join(parent, FileName)); err != nil {
+				return "", err
+			}
+			parent = filepath.Join(parent, name)
+			base = path.Join(base, name)
+		}
+	}
+
+	prefix, err := filepath.Rel(repo, abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", abs, err)
+	}
+	if prefix == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(prefix), nil
+}
+
+// findRepository returns the closest directory at or above dir that contains .git, or "".
+func findRepository(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,56 @@
+package gitignore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWalkSubdirectoryUsesParentRules(t *testing.T) {
+	repo := t.TempDir()
+	files := map[string]string{
+		".git/info/exclude":   "*.tmp\n",
+		".gitignore":          "*.log\n/sub/gen/\n",
+		"sub/.gitignore":      "!keep.log\n",
+		"sub/a.go":            "",
+		"sub/a.log":           "",
+		"sub/keep.log":        "",
+		"sub/a.tmp":           "",
+		"sub/gen/x.go":        "",
+		"sub/deep/b.go":       "",
+		"sub/deep/.gitignore": "b.go\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := filepath.Join(repo, "sub")
+	var got []string
+	err := Walk(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(root, p)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+
+	slices.Sort(got)
+	want := []string{".gitignore", "a.go", "deep/.gitignore", "keep.log"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Walk visited %v, want %v", got, want)
+	}
+}
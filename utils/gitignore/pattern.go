@@ -0,0 +1,117 @@
+// Package gitignore matches paths against .gitignore rules without calling git.
+package gitignore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Pattern is a single gitignore pattern. It matches slash-separated paths relative to the
+// directory of the file it was read from.
+type Pattern struct {
+	// Negate is set for "!" patterns, which re-include what earlier patterns excluded.
+	Negate bool
+	// DirOnly is set for patterns with a trailing slash, which only match directories.
+	DirOnly bool
+
+	re *regexp.Regexp
+}
+
+// ParsePattern parses a line of a .gitignore file. ok is false for blank lines, comments
+// and patterns that cannot be compiled.
+func ParsePattern(line string) (p Pattern, ok bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return Pattern{}, false
+	}
+
+	// A pattern with a slash other than a trailing one is relative to its directory;
+	// one without matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	re, err := regexp.Compile(compile(line, anchored))
+	if err != nil {
+		return Pattern{}, false
+	}
+	p.re = re
+	return p, true
+}
+
+// Match reports whether the pattern matches rel, a slash-separated relative path.
+// Negation is not applied; Matcher takes care of it.
+func (p Pattern) Match(rel string, isDir bool) bool {
+	if p.DirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(rel)
+}
+
+// trimTrailingSpaces removes trailing spaces unless they are escaped with a backslash.
+func trimTrailingSpaces(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// compile translates a glob into a regular expression anchored at both ends.
+func compile(glob string, anchored bool) string {
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			// "**/" matches zero or more directories.
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case glob[i:] == "**" && (i == 0 || glob[i-1] == '/'):
+			// A trailing "/**" matches everything inside.
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
@@ -0,0 +1,150 @@
+package gocode
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// CallGraph is the call graph of a program, built with class hierarchy analysis: a call
+// through an interface reaches every method of the program that could implement it.
+// Calls through function values are left out. Function literals are merged into the
+// function declaring them, so a callback passed to another function does not make that
+// function a caller. Only functions of the program have callees; calls into dependencies
+// end there.
+type CallGraph struct {
+	Funcs map[string]*Func
+}
+
+// Func is a function or method in the call graph.
+type Func struct {
+	// Name is qualified by package name, like "journal.WriteFile" or "(*journal.Journal).WriteFile".
+	Name string `json:"name"`
+	// External is set for functions outside the program, whose callees are not known.
+	External bool `json:"external,omitempty"`
+	Location
+
+	Callers []*Call `json:"-"`
+	Callees []*Call `json:"-"`
+
+	key string
+}
+
+// Call is a call from Caller to Callee at Site. A call through an interface has a Call for
+// each method it may reach.
+type Call struct {
+	Caller *Func
+	Callee *Func
+	Site   Location
+	// Dynamic is set for calls through an interface.
+	Dynamic bool
+}
+
+// CallGraph returns the call graph of the program, building it on first use.
+func (p *Program) CallGraph() *CallGraph {
+	p.graphOnce.Do(func() {
+		p.graph = p.buildCallGraph()
+	})
+	return p.graph
+}
+
+func (p *Program) buildCallGraph() *CallGraph {
+	prog, _ := ssautil.Packages(p.Packages, ssa.InstantiateGenerics)
+	prog.Build()
+
+	local := make(map[string]bool)
+	for _, pkg := range p.Packages {
+		local[pkg.Types.Path()] = true
+	}
+
+	g := &CallGraph{Funcs: make(map[string]*Func)}
+	node := func(fn *ssa.Function) *Func {
+		for fn.Parent() != nil {
+			fn = fn.Parent()
+		}
+		if fn.Origin() != nil {
+			fn = fn.Origin()
+		}
+		// Wrappers of a method share its object, and so its node.
+		f := &Func{Name: fn.String(), Location: p.location(fn.Pos()), key: fn.String()}
+		if obj, ok := fn.Object().(*types.Func); ok && obj.Pkg() != nil {
+			f = &Func{Name: funcString(obj), Location: p.location(obj.Pos()), key: p.objectKey(obj)}
+			f.External = !local[obj.Pkg().Path()]
+		} else if fn.Pkg != nil {
+			f.Name = fn.Pkg.Pkg.Name() + "." + fn.Name()
+			f.External = !local[fn.Pkg.Pkg.Path()]
+		}
+		if existing, ok := g.Funcs[f.key]; ok {
+			return existing
+		}
+		g.Funcs[f.key] = f
+		return f
+	}
+
+	type callID struct {
+		caller, callee string
+		site           Location
+	}
+	seen := make(map[callID]bool)
+	for _, n := range cha.CallGraph(prog).Nodes {
+		if n.Func == nil || n.Func.Pkg == nil || !local[n.Func.Pkg.Pkg.Path()] {
+			continue
+		}
+		for _, e := range n.Out {
+			caller, callee := node(e.Caller.Func), node(e.Callee.Func)
+			// A function literal is part of the function declaring it, so calls into it are
+			// not calls of that function; nor are calls from a wrapper to the method it wraps.
+			if e.Callee.Func.Parent() != nil || caller == callee && e.Caller.Func.Synthetic != "" {
+				continue
+			}
+			// Class hierarchy analysis resolves a call through a function value to every
+			// function with its signature, which is mostly noise.
+			common := e.Site.Common()
+			if common.StaticCallee() == nil && !common.IsInvoke() {
+				continue
+			}
+			// Test variants of a package compile the same calls twice.
+			id := callID{caller.key, callee.key, p.location(e.Site.Pos())}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			call := &Call{Caller: caller, Callee: callee, Site: id.site, Dynamic: common.IsInvoke()}
+			caller.Callees = append(caller.Callees, call)
+			callee.Callers = append(callee.Callers, call)
+		}
+	}
+	for _, f := range g.Funcs {
+		sortByLocation(f.Callers, func(c *Call) Location { return c.Site })
+		sortByLocation(f.Callees, func(c *Call) Location { return c.Site })
+	}
+	return g
+}
+
+// Lookup returns the functions and methods matching query, in the forms Definitions accepts.
+func (p *Program) Lookup(query string) []*Func {
+	g := p.CallGraph()
+	var funcs []*Func
+	for _, obj := range p.lookup(query) {
+		if _, ok := obj.(*types.Func); !ok {
+			continue
+		}
+		if f, ok := g.Funcs[p.objectKey(obj)]; ok {
+			funcs = append(funcs, f)
+		} else {
+			// Not called and calling nothing.
+			funcs = append(funcs, &Func{Name: funcString(obj.(*types.Func)), Location: p.location(obj.Pos())})
+		}
+	}
+	return funcs
+}
+
+// funcString names fn like "pkg.Func" or "(*pkg.Type).Method".
+func funcString(fn *types.Func) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		return "(" + types.TypeString(recv.Type(), qualifier) + ")." + fn.Name()
+	}
+	return fn.Pkg().Name() + "." + fn.Name()
+}
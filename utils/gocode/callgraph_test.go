@@ -0,0 +1,83 @@
+package gocode
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// calls lists the calls of the function matching query, like "-> store.Each" for a callee
+// and "<- journal.WriteFile" for a caller, marking dynamic calls with "?".
+func calls(t *testing.T, p *Program, query string) []string {
+	t.Helper()
+	funcs := p.Lookup(query)
+	if len(funcs) != 1 {
+		t.Fatalf("Lookup(%q) = %v", query, funcs)
+	}
+	var got []string
+	for _, c := range funcs[0].Callees {
+		got = append(got, fmt.Sprintf("-> %s%s", c.Callee.Name, dynamic(c)))
+	}
+	for _, c := range funcs[0].Callers {
+		got = append(got, fmt.Sprintf("<- %s%s", c.Caller.Name, dynamic(c)))
+	}
+	slices.Sort(got)
+	return got
+}
+
+func dynamic(c *Call) string {
+	if c.Dynamic {
+		return "?"
+	}
+	return ""
+}
+
+func TestCallGraph(t *testing.T) {
+	p := loadShop(t)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"store.WriteFile", []string{
+			// Class hierarchy analysis reaches every Save of the program, test files included.
+			"-> (*store.Store).Save?",
+			"-> (*store.fake).Save?",
+			"-> (store.Memory).Save?",
+			"<- journal.WriteFile",
+			"<- store.init",
+		}},
+		{"journal.WriteFile", []string{"-> store.WriteFile"}},
+		// The literal passed to Each is part of Replay, and its call through fn is left out.
+		{"(*Journal).Replay", []string{"-> (*store.Store).Save", "-> store.Each"}},
+		{"store.Each", []string{"<- (*journal.Journal).Replay"}},
+		{"(*Store).Save", []string{
+			"<- (*journal.Journal).Replay",
+			"<- store.WriteFile?",
+		}},
+		{"Store.Len", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := calls(t, p, tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("calls of %s = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// Function literals and method wrappers have no nodes of their own.
+	var names []string
+	for _, f := range p.CallGraph().Funcs {
+		names = append(names, f.Name)
+	}
+	slices.Sort(names)
+	want := []string{
+		"(*journal.Journal).Replay", "(*store.Store).Save", "(*store.fake).Save", "(store.Memory).Save",
+		"journal.WriteFile", "journal.init", "store.Each", "store.WriteFile", "store.init",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("call graph functions = %v, want %v", names, want)
+	}
+	if p.CallGraph() != p.CallGraph() {
+		t.Error("CallGraph is built twice")
+	}
+}
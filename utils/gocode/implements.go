@@ -0,0 +1,190 @@
+package gocode
+
+import (
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Implementation is a type that satisfies an interface.
+type Implementation struct {
+	// Type and Interface are qualified by package name, like "journal.Journal".
+	Type      string `json:"type"`
+	Interface string `json:"interface"`
+	// Pointer is set when only the pointer to Type satisfies Interface, because some of the
+	// methods have pointer receivers.
+	Pointer bool `json:"pointer,omitempty"`
+	// Location is where the type, or the interface for Interfaces, is declared.
+	Location
+}
+
+// named is a named type declared at package level, with the package it was found in.
+type named struct {
+	obj *types.TypeName
+	pkg *packages.Package
+}
+
+// namedTypes returns the package-level named types of the program, each declaration once.
+// With std set, the exported interfaces of the standard library packages imported by the
+// program are included too, and the error interface.
+func (p *Program) namedTypes(std bool) []named {
+	var all []named
+	seen := make(map[string]bool)
+	add := func(obj types.Object, pkg *packages.Package) {
+		tn, ok := obj.(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			return
+		}
+		if key := p.objectKey(tn); !seen[key] {
+			seen[key] = true
+			all = append(all, named{obj: tn, pkg: pkg})
+		}
+	}
+
+	for _, pkg := range p.Packages {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			add(scope.Lookup(name), pkg)
+		}
+	}
+	if !std {
+		return all
+	}
+
+	all = append(all, named{obj: types.Universe.Lookup("error").(*types.TypeName)})
+	for _, pkg := range p.Packages {
+		for path, imp := range pkg.Imports {
+			if !isStandard(path) || imp.Types == nil {
+				continue
+			}
+			scope := imp.Types.Scope()
+			for _, name := range scope.Names() {
+				if obj := scope.Lookup(name); obj.Exported() && types.IsInterface(obj.Type()) {
+					add(obj, imp)
+				}
+			}
+		}
+	}
+	return all
+}
+
+// isStandard reports whether path is a public standard library package.
+func isStandard(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".") && !strings.Contains(path, "internal") && path != "C"
+}
+
+// Implementations returns the concrete types of the program that satisfy the interface
+// iface, reporting those whose pointer alone does. Generic types are not checked.
+func (p *Program) Implementations(iface *types.TypeName) []Implementation {
+	if !usableInterface(iface) {
+		return nil
+	}
+	var impls []Implementation
+	for _, t := range p.namedTypes(false) {
+		if types.IsInterface(t.obj.Type()) || isGeneric(t.obj) {
+			continue
+		}
+		local, ok := p.view(t.pkg, iface).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if impl, ok := p.implements(t.obj, local); ok {
+			impl.Location = p.location(t.obj.Pos())
+			impls = append(impls, impl)
+		}
+	}
+	sortByLocation(impls, func(i Implementation) Location { return i.Location })
+	return impls
+}
+
+// Interfaces returns the interfaces that the concrete type tn or its pointer satisfies:
+// those of the program, those exported by the standard library packages it imports, and
+// error. Empty interfaces are left out.
+func (p *Program) Interfaces(tn *types.TypeName) []Implementation {
+	if types.IsInterface(tn.Type()) || isGeneric(tn) {
+		return nil
+	}
+	pkg, _ := p.file(tn.Pos())
+	var impls []Implementation
+	for _, t := range p.namedTypes(true) {
+		if !usableInterface(t.obj) {
+			continue
+		}
+		iface, ok := p.view(pkg, t.obj).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if impl, ok := p.implements(tn, iface); ok {
+			if t.obj.Pkg() != nil {
+				impl.Location = p.location(t.obj.Pos())
+			}
+			impls = append(impls, impl)
+		}
+	}
+	sortByLocation(impls, func(i Implementation) Location { return i.Location })
+	return impls
+}
+
+// implements checks t against iface, trying the pointer type when the value type fails.
+func (p *Program) implements(t, iface *types.TypeName) (Implementation, bool) {
+	it, ok := iface.Type().Underlying().(*types.Interface)
+	if !ok {
+		return Implementation{}, false
+	}
+	impl := Implementation{
+		Type:      types.TypeString(t.Type(), qualifier),
+		Interface: types.TypeString(iface.Type(), qualifier),
+	}
+	if types.Implements(t.Type(), it) {
+		return impl, true
+	}
+	if types.Implements(types.NewPointer(t.Type()), it) {
+		impl.Pointer = true
+		return impl, true
+	}
+	return Implementation{}, false
+}
+
+// usableInterface reports whether tn is a non-generic interface with methods that can be
+// used as a variable type, so not a type constraint.
+func usableInterface(tn *types.TypeName) bool {
+	it, ok := tn.Type().Underlying().(*types.Interface)
+	return ok && !isGeneric(tn) && it.NumMethods() > 0 && it.IsMethodSet()
+}
+
+func isGeneric(tn *types.TypeName) bool {
+	n, ok := tn.Type().(*types.Named)
+	return ok && n.TypeParams().Len() > 0
+}
+
+// view returns obj as seen from pkg. Test variants of a package are type-checked apart
+// from it, so an interface must be taken from the variant pkg imports to match the types
+// in pkg. obj is returned unchanged when pkg does not import its package.
+func (p *Program) view(pkg *packages.Package, obj types.Object) types.Object {
+	if pkg == nil || obj.Pkg() == nil {
+		return obj
+	}
+	seen := make(map[*packages.Package]bool)
+	var find func(*packages.Package) types.Object
+	find = func(q *packages.Package) types.Object {
+		if seen[q] || q.Types == nil {
+			return nil
+		}
+		seen[q] = true
+		if q.Types.Path() == obj.Pkg().Path() {
+			return q.Types.Scope().Lookup(obj.Name())
+		}
+		for _, imp := range q.Imports {
+			if found := find(imp); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	if found := find(pkg); found != nil {
+		return found
+	}
+	return obj
+}
@@ -0,0 +1,70 @@
+package gocode
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestImplementations(t *testing.T) {
+	p := loadShop(t)
+	tests := []struct {
+		query string
+		want  []Implementation
+	}{
+		{"store.Saver", []Implementation{
+			{Type: "journal.Journal", Interface: "store.Saver", Pointer: true},
+			{Type: "store.Store", Interface: "store.Saver", Pointer: true},
+			{Type: "store.Memory", Interface: "store.Saver"},
+			// Declared in the test variant of store, which has its own Saver.
+			{Type: "store.fake", Interface: "store.Saver", Pointer: true},
+		}},
+		{"store.Store", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			iface := p.Types(tt.query)
+			if len(iface) != 1 {
+				t.Fatalf("Types(%q) = %v", tt.query, iface)
+			}
+			got := p.Implementations(iface[0])
+			for i := range got {
+				got[i].Location = Location{}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Implementations = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	p := loadShop(t)
+	tests := []struct {
+		query string
+		want  []Implementation
+	}{
+		{"store.Memory", []Implementation{
+			{Type: "store.Memory", Interface: "error"},
+			{Type: "store.Memory", Interface: "store.Saver"},
+		}},
+		{"store.Store", []Implementation{{Type: "store.Store", Interface: "store.Saver", Pointer: true}}},
+		{"journal.Journal", []Implementation{{Type: "journal.Journal", Interface: "store.Saver", Pointer: true}}},
+		{"fake", []Implementation{{Type: "store.fake", Interface: "store.Saver", Pointer: true}}},
+		{"store.Saver", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			tn := p.Types(tt.query)
+			if len(tn) != 1 {
+				t.Fatalf("Types(%q) = %v", tt.query, tn)
+			}
+			got := p.Interfaces(tn[0])
+			for i := range got {
+				got[i].Location = Location{}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Interfaces = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
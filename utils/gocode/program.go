@@ -0,0 +1,226 @@
+// Package gocode answers questions about Go code, such as where a symbol is defined and
+// used, with go/packages and go/types instead of text patterns.
+package gocode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Program is the type-checked packages under a directory, test files included. It is safe
+// for concurrent use.
+type Program struct {
+	Dir      string
+	Fset     *token.FileSet
+	Packages []*packages.Package
+
+	mu    sync.Mutex
+	lines map[string][]string
+
+	graphOnce sync.Once
+	graph     *CallGraph
+}
+
+// maxCachedPrograms bounds the programs kept by Load; each holds the syntax and types of
+// the whole dependency graph.
+const maxCachedPrograms = 4
+
+var cache struct {
+	sync.Mutex
+	programs map[string]*cachedProgram
+}
+
+type cachedProgram struct {
+	stamp   string
+	program *Program
+	used    time.Time
+}
+
+// Load loads and type-checks the packages in dir and its subdirectories, dependencies
+// included. Packages with errors are kept, so partly broken code can still be searched.
+// Programs are cached per directory until a Go file, go.mod or go.sum under it changes.
+func Load(ctx context.Context, dir string) (*Program, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+		abs = filepath.Dir(abs)
+	}
+
+	stamp, err := sourceStamp(abs)
+	if err != nil {
+		return nil, err
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	if c, ok := cache.programs[abs]; ok && c.stamp == stamp {
+		c.used = time.Now()
+		return c.program, nil
+	}
+
+	program, err := load(ctx, abs)
+	if err != nil {
+		return nil, err
+	}
+	if cache.programs == nil {
+		cache.programs = make(map[string]*cachedProgram)
+	}
+	if _, ok := cache.programs[abs]; !ok && len(cache.programs) >= maxCachedPrograms {
+		oldest := ""
+		for dir, c := range cache.programs {
+			if oldest == "" || c.used.Before(cache.programs[oldest].used) {
+				oldest = dir
+			}
+		}
+		delete(cache.programs, oldest)
+	}
+	cache.programs[abs] = &cachedProgram{stamp: stamp, program: program, used: time.Now()}
+	return program, nil
+}
+
+func load(ctx context.Context, dir string) (*Program, error) {
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packages.LoadAllSyntax,
+		Dir:     dir,
+		Fset:    fset,
+		Tests:   true,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages in %s: %w", dir, err)
+	}
+
+	var loaded []*packages.Package
+	for _, pkg := range pkgs {
+		// The generated test main packages only hold code from the build cache.
+		if strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+		if pkg.Types != nil && pkg.TypesInfo != nil {
+			loaded = append(loaded, pkg)
+		}
+	}
+	if len(loaded) == 0 {
+		return nil, fmt.Errorf("no Go packages found in %s", dir)
+	}
+	return &Program{Dir: dir, Fset: fset, Packages: loaded, lines: make(map[string][]string)}, nil
+}
+
+// sourceStamp fingerprints the Go files, go.mod and go.sum files under dir by path, size
+// and modification time, skipping the directories the go command ignores.
+func sourceStamp(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") && name != "go.mod" && name != "go.sum" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %d %d\n", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Location is a position in a source file.
+type Location struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func (p *Program) location(pos token.Pos) Location {
+	position := p.Fset.Position(pos)
+	return Location{File: position.Filename, Line: position.Line, Column: position.Column}
+}
+
+// line returns the trimmed source line at loc.
+func (p *Program) line(loc Location) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lines, ok := p.lines[loc.File]
+	if !ok {
+		content, err := os.ReadFile(loc.File)
+		if err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		p.lines[loc.File] = lines
+	}
+	if loc.Line < 1 || loc.Line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[loc.Line-1])
+}
+
+// source returns the source text between from and to, at most maxLines lines.
+func (p *Program) source(from, to token.Pos, maxLines int) string {
+	start := p.Fset.Position(from)
+	end := p.Fset.Position(to)
+	content, err := os.ReadFile(start.Filename)
+	if err != nil || start.Offset > end.Offset || end.Offset > len(content) {
+		return ""
+	}
+	text := string(content[start.Offset:end.Offset])
+	if lines := strings.Split(text, "\n"); len(lines) > maxLines {
+		text = strings.Join(lines[:maxLines], "\n") + "\n\t// ..."
+	}
+	return text
+}
+
+// file returns the syntax tree and package holding pos.
+func (p *Program) file(pos token.Pos) (*packages.Package, *ast.File) {
+	for _, pkg := range p.Packages {
+		for _, f := range pkg.Syntax {
+			if f.FileStart <= pos && pos <= f.FileEnd {
+				return pkg, f
+			}
+		}
+	}
+	return nil, nil
+}
+
+// objectKey identifies an object across packages. Packages are type-checked separately,
+// so the same declaration is a different types.Object in each package that imports it.
+func (p *Program) objectKey(obj types.Object) string {
+	if obj == nil || obj.Pkg() == nil {
+		return ""
+	}
+	pos := p.Fset.Position(obj.Pos())
+	return fmt.Sprintf("%s %s %s:%d", obj.Pkg().Path(), obj.Name(), filepath.Base(pos.Filename), pos.Line)
+}
+
+// qualifier prints package names instead of full import paths.
+func qualifier(pkg *types.Package) string {
+	return pkg.Name()
+}
@@ -0,0 +1,127 @@
+package gocode
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// loadShop loads the module in testdata/shop.
+func loadShop(t *testing.T) *Program {
+	t.Helper()
+	p, err := Load(context.Background(), filepath.Join("testdata", "shop"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return p
+}
+
+// copyDir copies the files of src into dst.
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.WalkDir(src, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), content, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSourceStamp(t *testing.T) {
+	dir := t.TempDir()
+	copyDir(t, filepath.Join("testdata", "shop"), dir)
+	stamp := func() string {
+		t.Helper()
+		s, err := sourceStamp(dir)
+		if err != nil {
+			t.Fatalf("sourceStamp: %v", err)
+		}
+		return s
+	}
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		change  func()
+		changed bool
+	}{
+		{"nothing", func() {}, false},
+		{"other file", func() { write("README.md", "# shop\n") }, false},
+		{"hidden directory", func() { write(".cache/x.go", "package x\n") }, false},
+		{"testdata directory", func() { write("store/testdata/x.go", "package x\n") }, false},
+		{"underscore directory", func() { write("_old/x.go", "package x\n") }, false},
+		{"new go file", func() { write("store/extra.go", "package store\n") }, true},
+		{"edited go file", func() { write("store/extra.go", "package store\n\nvar X = 1\n") }, true},
+		{"touched go file", func() {
+			future := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(dir, "store", "extra.go"), future, future); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+		{"go.sum", func() { write("go.sum", "") }, true},
+		{"removed go file", func() {
+			if err := os.Remove(filepath.Join(dir, "store", "extra.go")); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := stamp()
+			tt.change()
+			if changed := stamp() != before; changed != tt.changed {
+				t.Errorf("stamp changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestLoadCache(t *testing.T) {
+	dir := t.TempDir()
+	copyDir(t, filepath.Join("testdata", "shop"), dir)
+	ctx := context.Background()
+
+	first, err := Load(ctx, dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if again, err := Load(ctx, filepath.Join(dir, "go.mod")); err != nil || again != first {
+		t.Fatalf("Load of an unchanged directory = %p, %v, want the cached %p", again, err, first)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "store", "extra.go"), []byte("package store\n\nfunc Extra() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := Load(ctx, dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if reloaded == first {
+		t.Fatal("Load returned the cached program after a Go file was added")
+	}
+	if len(reloaded.Definitions("store.Extra")) != 1 {
+		t.Error("the reloaded program does not hold the added function")
+	}
+}
@@ -0,0 +1,394 @@
+package gocode
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// maxCodeLines caps the source shown for a definition.
+const maxCodeLines = 80
+
+// Kind is the kind of a symbol.
+type Kind string
+
+const (
+	KindFunc   Kind = "func"
+	KindMethod Kind = "method"
+	KindType   Kind = "type"
+	KindVar    Kind = "var"
+	KindConst  Kind = "const"
+	KindField  Kind = "field"
+)
+
+// Symbol is a declared identifier.
+type Symbol struct {
+	Name    string `json:"name"`
+	Kind    Kind   `json:"kind"`
+	Package string `json:"package"`
+	Location
+	// Signature is the declaration as go/types prints it, like "func journal.WriteFile(ctx context.Context, ...) error".
+	Signature string `json:"signature"`
+	// Code is the source of the declaration with its doc comment.
+	Code string `json:"code,omitempty"`
+}
+
+// Reference is a use of a symbol.
+type Reference struct {
+	Location
+	// Text is the source line holding the reference.
+	Text string `json:"text"`
+	// Func is the top-level function or method the reference is in, empty outside functions.
+	Func string `json:"func,omitempty"`
+}
+
+// Method is a method in the method set of a type.
+type Method struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+	// PointerReceiver is set for methods only in the method set of the pointer type.
+	PointerReceiver bool `json:"pointer_receiver,omitempty"`
+	// Promoted is set for methods of an embedded field.
+	Promoted bool `json:"promoted,omitempty"`
+	Location
+}
+
+// Field is a field of a struct type.
+type Field struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Tag      string `json:"tag,omitempty"`
+	Embedded bool   `json:"embedded,omitempty"`
+	Location
+}
+
+// lookup resolves a query to the objects it names. A query is a name ("WriteFile"), a
+// name qualified by a package name or import path ("journal.WriteFile"), or a member of a
+// type ("Workspace.Read", "(*Workspace).Read", "workspace.Workspace.Read"). A bare name
+// also matches methods and fields with that name.
+func (p *Program) lookup(query string) []types.Object {
+	query = strings.NewReplacer("(", "", ")", "", "*", "").Replace(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	// An import path may hold dots, so only the part after the last slash is split.
+	prefix := ""
+	if i := strings.LastIndex(query, "/"); i >= 0 {
+		prefix, query = query[:i+1], query[i+1:]
+	}
+	parts := strings.Split(query, ".")
+	if prefix != "" {
+		if len(parts) < 2 {
+			return nil
+		}
+		parts[0] = prefix + parts[0]
+	}
+
+	var found []types.Object
+	seen := make(map[string]bool)
+	add := func(obj types.Object) {
+		key := p.objectKey(obj)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			found = append(found, obj)
+		}
+	}
+
+	for _, pkg := range p.Packages {
+		scope := pkg.Types.Scope()
+		switch len(parts) {
+		case 1:
+			if obj := scope.Lookup(parts[0]); obj != nil {
+				add(obj)
+			}
+			for _, name := range scope.Names() {
+				if tn, ok := scope.Lookup(name).(*types.TypeName); ok {
+					if obj := member(tn, parts[0], false); obj != nil {
+						add(obj)
+					}
+				}
+			}
+		case 2:
+			if matchPackage(pkg, parts[0]) {
+				if obj := scope.Lookup(parts[1]); obj != nil {
+					add(obj)
+				}
+			}
+			if tn, ok := scope.Lookup(parts[0]).(*types.TypeName); ok && prefix == "" {
+				if obj := member(tn, parts[1], true); obj != nil {
+					add(obj)
+				}
+			}
+		case 3:
+			if !matchPackage(pkg, parts[0]) {
+				continue
+			}
+			if tn, ok := scope.Lookup(parts[1]).(*types.TypeName); ok {
+				if obj := member(tn, parts[2], true); obj != nil {
+					add(obj)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// member returns the field or method name of the type tn. Promoted members are only
+// returned when promoted is set, so a bare name finds each declaration once.
+func member(tn *types.TypeName, name string, promoted bool) types.Object {
+	obj, index, _ := types.LookupFieldOrMethod(tn.Type(), true, tn.Pkg(), name)
+	if obj == nil || (!promoted && len(index) > 1) {
+		return nil
+	}
+	return obj
+}
+
+// matchPackage reports whether name is the name, import path or a trailing part of the
+// import path of pkg.
+func matchPackage(pkg *packages.Package, name string) bool {
+	path := pkg.Types.Path()
+	return pkg.Types.Name() == name || path == name || strings.HasSuffix(path, "/"+name)
+}
+
+// Definitions returns the declarations matching query. See lookup for the query forms.
+func (p *Program) Definitions(query string) []Symbol {
+	var symbols []Symbol
+	for _, obj := range p.lookup(query) {
+		symbols = append(symbols, p.symbol(obj))
+	}
+	sortByLocation(symbols, func(s Symbol) Location { return s.Location })
+	return symbols
+}
+
+func (p *Program) symbol(obj types.Object) Symbol {
+	s := Symbol{
+		Name:      obj.Name(),
+		Kind:      kindOf(obj),
+		Package:   obj.Pkg().Path(),
+		Location:  p.location(obj.Pos()),
+		Signature: signature(obj),
+	}
+	if _, f := p.file(obj.Pos()); f != nil {
+		if from, to, ok := declaration(f, obj); ok {
+			s.Code = p.source(from, to, maxCodeLines)
+		}
+	}
+	return s
+}
+
+// signature prints obj like types.ObjectString, leaving out the members of struct and
+// interface types, which Code already shows.
+func signature(obj types.Object) string {
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return types.ObjectString(obj, qualifier)
+	}
+	name := types.TypeString(tn.Type(), qualifier)
+	if tn.IsAlias() {
+		return "type " + tn.Name() + " = " + name
+	}
+	switch u := tn.Type().Underlying().(type) {
+	case *types.Struct:
+		return "type " + name + " struct"
+	case *types.Interface:
+		return "type " + name + " interface"
+	default:
+		return "type " + name + " " + types.TypeString(u, qualifier)
+	}
+}
+
+func kindOf(obj types.Object) Kind {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return KindMethod
+		}
+		return KindFunc
+	case *types.TypeName:
+		return KindType
+	case *types.Const:
+		return KindConst
+	case *types.Var:
+		if obj.IsField() {
+			return KindField
+		}
+	}
+	return KindVar
+}
+
+// declaration returns the range of the syntax declaring obj, including its doc comment.
+// A spec alone in its declaration is widened to the whole declaration, keeping the keyword.
+func declaration(f *ast.File, obj types.Object) (from, to token.Pos, ok bool) {
+	path, _ := astutil.PathEnclosingInterval(f, obj.Pos(), obj.Pos())
+	for i, node := range path {
+		switch node := node.(type) {
+		case *ast.FuncDecl:
+			return withDoc(node.Doc, node), node.End(), true
+		case *ast.Field:
+			return node.Pos(), node.End(), true
+		case *ast.TypeSpec, *ast.ValueSpec:
+			if i+1 < len(path) {
+				if decl, ok := path[i+1].(*ast.GenDecl); ok && len(decl.Specs) == 1 {
+					return withDoc(decl.Doc, decl), decl.End(), true
+				}
+			}
+			return node.Pos(), node.End(), true
+		}
+	}
+	return token.NoPos, token.NoPos, false
+}
+
+func withDoc(doc *ast.CommentGroup, node ast.Node) token.Pos {
+	if doc != nil {
+		return doc.Pos()
+	}
+	return node.Pos()
+}
+
+// References returns the uses of the symbols matching query, in all packages including
+// tests. Declarations are not included.
+func (p *Program) References(query string) []Reference {
+	targets := make(map[string]bool)
+	for _, obj := range p.lookup(query) {
+		targets[p.objectKey(obj)] = true
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var refs []Reference
+	seen := make(map[Location]bool)
+	for _, pkg := range p.Packages {
+		for _, f := range pkg.Syntax {
+			for _, decl := range f.Decls {
+				fn := ""
+				if fd, ok := decl.(*ast.FuncDecl); ok {
+					fn = funcName(fd)
+				}
+				ast.Inspect(decl, func(n ast.Node) bool {
+					id, ok := n.(*ast.Ident)
+					if !ok || !targets[p.objectKey(pkg.TypesInfo.Uses[id])] {
+						return true
+					}
+					loc := p.location(id.Pos())
+					if !seen[loc] {
+						seen[loc] = true
+						refs = append(refs, Reference{Location: loc, Text: p.line(loc), Func: fn})
+					}
+					return true
+				})
+			}
+		}
+	}
+	sortByLocation(refs, func(r Reference) Location { return r.Location })
+	return refs
+}
+
+// funcName names a function declaration like "Name" or "(*Type).Name".
+func funcName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	recv := fd.Recv.List[0].Type
+	pointer := false
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv, pointer = star.X, true
+	}
+	switch r := recv.(type) {
+	case *ast.IndexExpr:
+		recv = r.X
+	case *ast.IndexListExpr:
+		recv = r.X
+	}
+	name := types.ExprString(recv)
+	if pointer {
+		return "(*" + name + ")." + fd.Name.Name
+	}
+	return name + "." + fd.Name.Name
+}
+
+// Types returns the type declarations matching query.
+func (p *Program) Types(query string) []*types.TypeName {
+	var names []*types.TypeName
+	for _, obj := range p.lookup(query) {
+		if tn, ok := obj.(*types.TypeName); ok {
+			names = append(names, tn)
+		}
+	}
+	return names
+}
+
+// Methods returns the method set of the pointer to tn, or of tn itself for interfaces,
+// marking the methods the value type lacks.
+func (p *Program) Methods(tn *types.TypeName) []Method {
+	t := tn.Type()
+	values := types.NewMethodSet(t)
+	all := values
+	if !types.IsInterface(t) {
+		all = types.NewMethodSet(types.NewPointer(t))
+	}
+
+	methods := make([]Method, 0, all.Len())
+	for i := range all.Len() {
+		sel := all.At(i)
+		obj := sel.Obj()
+		methods = append(methods, Method{
+			Name:            obj.Name(),
+			Signature:       types.ObjectString(obj, qualifier),
+			PointerReceiver: values.Lookup(obj.Pkg(), obj.Name()) == nil,
+			Promoted:        len(sel.Index()) > 1,
+			Location:        p.location(obj.Pos()),
+		})
+	}
+	return methods
+}
+
+// Fields returns the fields declared by tn, or nil if it is not a struct type. Fields of
+// embedded types are not expanded.
+func (p *Program) Fields(tn *types.TypeName) []Field {
+	st, ok := tn.Type().Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	fields := make([]Field, 0, st.NumFields())
+	for i := range st.NumFields() {
+		v := st.Field(i)
+		fields = append(fields, Field{
+			Name:     v.Name(),
+			Type:     types.TypeString(v.Type(), qualifier),
+			Tag:      st.Tag(i),
+			Embedded: v.Embedded(),
+			Location: p.location(v.Pos()),
+		})
+	}
+	return fields
+}
+
+// Symbol describes tn like Definitions does.
+func (p *Program) Symbol(tn *types.TypeName) Symbol {
+	return p.symbol(tn)
+}
+
+// TypeLocation returns where tn is declared.
+func (p *Program) TypeLocation(tn *types.TypeName) Location {
+	return p.location(tn.Pos())
+}
+
+func sortByLocation[T any](items []T, loc func(T) Location) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := loc(items[i]), loc(items[j])
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
@@ -0,0 +1,96 @@
+package gocode
+
+import (
+	"go/types"
+	"slices"
+	"testing"
+)
+
+// describe names obj like "(*store.Store).Save" or "store.Saver".
+func describe(obj types.Object) string {
+	if fn, ok := obj.(*types.Func); ok {
+		return funcString(fn)
+	}
+	if v, ok := obj.(*types.Var); ok && v.IsField() {
+		return "field " + v.Name()
+	}
+	return obj.Pkg().Name() + "." + obj.Name()
+}
+
+func TestLookup(t *testing.T) {
+	p := loadShop(t)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"WriteFile", []string{"journal.WriteFile", "store.WriteFile"}},
+		{"journal.WriteFile", []string{"journal.WriteFile"}},
+		{"store.WriteFile", []string{"store.WriteFile"}},
+		{" store.WriteFile ", []string{"store.WriteFile"}},
+		{"example.com/shop/journal.WriteFile", []string{"journal.WriteFile"}},
+		{"shop/store.WriteFile", []string{"store.WriteFile"}},
+		{"example.com/shop/journal", nil},
+		{"Saver", []string{"store.Saver"}},
+		{"Save", []string{"(*store.Store).Save", "(store.Memory).Save", "(store.Saver).Save", "(*store.fake).Save"}},
+		{"Store.Save", []string{"(*store.Store).Save"}},
+		{"(*Store).Save", []string{"(*store.Store).Save"}},
+		{"(*Journal).Replay", []string{"(*journal.Journal).Replay"}},
+		{"Journal.Save", []string{"(*store.Store).Save"}},
+		{"journal.Journal.Save", []string{"(*store.Store).Save"}},
+		{"example.com/shop/journal.Journal.Replay", []string{"(*journal.Journal).Replay"}},
+		{"Journal.Store", []string{"field Store"}},
+		{"fake", []string{"store.fake"}},
+		{"store.Missing", nil},
+		{"store", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var got []string
+			for _, obj := range p.lookup(tt.query) {
+				got = append(got, describe(obj))
+			}
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(tt.want))
+			if !slices.Equal(got, want) {
+				t.Errorf("lookup(%q) = %v, want %v", tt.query, got, want)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	p := loadShop(t)
+	refs := p.References("store.WriteFile")
+	var funcs []string
+	for _, r := range refs {
+		funcs = append(funcs, r.Func)
+	}
+	if !slices.Equal(funcs, []string{"WriteFile", ""}) {
+		t.Fatalf("References = %+v, want one in journal.WriteFile and one in the test file", refs)
+	}
+	if refs[0].Text != "return store.WriteFile(&Journal{}, name)" {
+		t.Errorf("reference text = %q", refs[0].Text)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	p := loadShop(t)
+	types := p.Types("journal.Journal")
+	if len(types) != 1 {
+		t.Fatalf("Types = %v", types)
+	}
+	got := make(map[string]Method)
+	for _, m := range p.Methods(types[0]) {
+		got[m.Name] = m
+	}
+	if m := got["Save"]; !m.PointerReceiver || !m.Promoted {
+		t.Errorf("Save = %+v, want a promoted pointer method", m)
+	}
+	if m := got["Len"]; m.PointerReceiver || !m.Promoted {
+		t.Errorf("Len = %+v, want a promoted value method", m)
+	}
+	if m := got["Replay"]; !m.PointerReceiver || m.Promoted {
+		t.Errorf("Replay = %+v, want a declared pointer method", m)
+	}
+}
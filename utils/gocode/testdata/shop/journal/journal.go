@@ -0,0 +1,21 @@
+// Package journal records saved keys.
+package journal
+
+import "example.com/shop/store"
+
+// Journal is a Saver through the embedded Store.
+type Journal struct {
+	store.Store
+}
+
+// WriteFile saves name in a new journal.
+func WriteFile(name string) error {
+	return store.WriteFile(&Journal{}, name)
+}
+
+// Replay saves every key again.
+func (j *Journal) Replay(keys []string) {
+	store.Each(keys, func(k string) {
+		j.Save(k)
+	})
+}
@@ -0,0 +1,40 @@
+// Package store saves keys.
+package store
+
+// Saver saves a key.
+type Saver interface {
+	Save(key string) error
+}
+
+// Store saves keys in memory. Only its pointer is a Saver.
+type Store struct {
+	data map[string]bool
+}
+
+func (s *Store) Save(key string) error {
+	s.data[key] = true
+	return nil
+}
+
+func (s Store) Len() int {
+	return len(s.data)
+}
+
+// Memory is a Saver and an error.
+type Memory struct{}
+
+func (Memory) Save(key string) error { return nil }
+
+func (Memory) Error() string { return "memory" }
+
+// WriteFile saves name through s.
+func WriteFile(s Saver, name string) error {
+	return s.Save(name)
+}
+
+// Each calls fn for every key.
+func Each(keys []string, fn func(string)) {
+	for _, k := range keys {
+		fn(k)
+	}
+}
@@ -0,0 +1,7 @@
+package store
+
+type fake struct{}
+
+func (*fake) Save(key string) error { return nil }
+
+var _ = WriteFile(&fake{}, "test")
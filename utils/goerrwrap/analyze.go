@@ -0,0 +1,395 @@
+package goerrwrap
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/gosource"
+	"golang.org/x/tools/go/packages"
+)
+
+// Site is a return statement that passes an error on without wrapping it.
+type Site struct {
+	ID   int    `json:"id"`
+	Func string `json:"func"`
+	Line int    `json:"line"`
+	// Var is the returned error variable.
+	Var string `json:"var"`
+	// Call is the call the error came from, such as "os.ReadFile(path)", when it is known.
+	Call string `json:"call,omitempty"`
+	// Snippet is the source from the call, or a few lines before, up to the return statement.
+	Snippet string `json:"snippet"`
+
+	// start and end delimit the error variable; stmtStart and stmtEnd the return statement.
+	start, end         int
+	stmtStart, stmtEnd int
+	// guarded reports whether the return sits inside an "if err != nil" block on Var, so
+	// the error is known to be non-nil there.
+	guarded bool
+	indent  string
+}
+
+// File holds the sites found in a Go source file.
+type File struct {
+	Name  string
+	Sites []Site
+	// Typed reports whether the sites were found with full type information. Without it
+	// they are found by name: "err" or names ending in "Err".
+	Typed bool
+
+	src []byte
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// Analyze finds the returns of unwrapped errors in filename, whose content is src.
+// It type-checks the enclosing package when it can and falls back to a syntactic
+// analysis when the package does not load or type-check.
+func Analyze(filename string, src []byte) (*File, error) {
+	if f, info, fset, ok := loadTyped(filename, src); ok {
+		return analyze(filename, src, fset, f, info), nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return analyze(filename, src, fset, f, nil), nil
+}
+
+// loadTyped loads the package containing filename with src as its content.
+func loadTyped(filename string, src []byte) (*ast.File, *types.Info, *token.FileSet, bool) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	pkgs, err := loadPackage(abs, src, packages.NeedSyntax|packages.NeedTypes|packages.NeedTypesInfo)
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 || pkg.TypesInfo == nil {
+			continue
+		}
+		for _, f := range pkg.Syntax {
+			if pkg.Fset.Position(f.Pos()).Filename == abs {
+				return f, pkg.TypesInfo, pkg.Fset, true
+			}
+		}
+	}
+	return nil, nil, nil, false
+}
+
+func loadPackage(abs string, src []byte, mode packages.LoadMode) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | mode,
+		Dir:     filepath.Dir(abs),
+		Tests:   strings.HasSuffix(abs, "_test.go"),
+		Overlay: map[string][]byte{abs: src},
+	}
+	pkgs, err := packages.Load(cfg, "file="+abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package of %s: %w", abs, err)
+	}
+	return pkgs, nil
+}
+
+type analyzer struct {
+	src   []byte
+	fset  *token.FileSet
+	info  *types.Info
+	sites []Site
+}
+
+func analyze(filename string, src []byte, fset *token.FileSet, f *ast.File, info *types.Info) *File {
+	a := &analyzer{src: src, fset: fset, info: info}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		a.function(gosource.FuncName(fn), fn.Type, fn.Body)
+	}
+	for i := range a.sites {
+		a.sites[i].ID = i + 1
+	}
+	return &File{Name: filename, Sites: a.sites, Typed: info != nil, src: src}
+}
+
+// function collects the sites of one function body. Function literals are analyzed as
+// functions of their own, since their returns do not leave the enclosing function.
+func (a *analyzer) function(name string, typ *ast.FuncType, body *ast.BlockStmt) {
+	results := resultCount(typ)
+	returnsError := results > 0 && a.isErrorType(typ.Results.List[len(typ.Results.List)-1].Type)
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			a.function(name, n.Type, n.Body)
+			return false
+		case *ast.ReturnStmt:
+			if returnsError && len(n.Results) == results {
+				a.returnStmt(name, typ, body, n)
+			}
+		}
+		return true
+	})
+}
+
+func (a *analyzer) returnStmt(name string, typ *ast.FuncType, body *ast.BlockStmt, ret *ast.ReturnStmt) {
+	id, ok := ret.Results[len(ret.Results)-1].(*ast.Ident)
+	if !ok || id.Name == "nil" || id.Name == "_" {
+		return
+	}
+	if !a.isLocalError(id, typ) {
+		return
+	}
+
+	site := Site{
+		Func:      name,
+		Line:      a.fset.Position(ret.Pos()).Line,
+		Var:       id.Name,
+		start:     a.fset.Position(id.Pos()).Offset,
+		end:       a.fset.Position(id.End()).Offset,
+		stmtStart: a.fset.Position(ret.Pos()).Offset,
+		stmtEnd:   a.fset.Position(ret.End()).Offset,
+		guarded:   a.isGuarded(id, body, ret),
+		indent:    a.indent(ret),
+	}
+
+	var from ast.Node = ret
+	if origin := a.origin(id, body, ret.Pos()); origin != nil {
+		call, ok := ast.Unparen(origin).(*ast.CallExpr)
+		if !ok {
+			// The error is constructed here, not passed on.
+			return
+		}
+		if a.isWrapCall(call) {
+			return
+		}
+		site.Call = a.text(call)
+		from = origin
+	}
+	site.Snippet = a.snippet(from, ret)
+	a.sites = append(a.sites, site)
+}
+
+// isGuarded reports whether ret is inside the body of an if statement whose condition
+// requires id to be non-nil.
+func (a *analyzer) isGuarded(id *ast.Ident, body *ast.BlockStmt, ret *ast.ReturnStmt) bool {
+	guarded := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if guarded {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Returns of function literals are analyzed with the literal's own body.
+			return false
+		case *ast.IfStmt:
+			if n.Body.Pos() <= ret.Pos() && ret.End() <= n.Body.End() && a.requiresNonNil(n.Cond, id) {
+				guarded = true
+			}
+		}
+		return true
+	})
+	return guarded
+}
+
+// requiresNonNil reports whether cond can only be true when id is not nil: it is
+// "id != nil", possibly joined with other conditions by &&.
+func (a *analyzer) requiresNonNil(cond ast.Expr, id *ast.Ident) bool {
+	bin, ok := ast.Unparen(cond).(*ast.BinaryExpr)
+	if !ok {
+		return false
+	}
+	switch bin.Op {
+	case token.LAND:
+		return a.requiresNonNil(bin.X, id) || a.requiresNonNil(bin.Y, id)
+	case token.NEQ:
+		x, y := ast.Unparen(bin.X), ast.Unparen(bin.Y)
+		if isNil(x) {
+			x, y = y, x
+		}
+		v, ok := x.(*ast.Ident)
+		return ok && isNil(y) && a.sameVar(v, id)
+	}
+	return false
+}
+
+func isNil(expr ast.Expr) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == "nil"
+}
+
+// indent returns the leading whitespace of the line n starts on.
+func (a *analyzer) indent(n ast.Node) string {
+	pos := a.fset.Position(n.Pos())
+	line := a.src[pos.Offset-(pos.Column-1) : pos.Offset]
+	return string(line[:len(line)-len(strings.TrimLeft(string(line), " \t"))])
+}
+
+// isLocalError reports whether id is a local variable of type error, excluding parameters
+// and package-level sentinel errors.
+func (a *analyzer) isLocalError(id *ast.Ident, typ *ast.FuncType) bool {
+	if a.info == nil {
+		if !gosource.IsErrorName(id.Name) {
+			return false
+		}
+		for _, field := range typ.Params.List {
+			for _, n := range field.Names {
+				if n.Name == id.Name {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	v, ok := a.info.Uses[id].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Parent() == v.Pkg().Scope() {
+		return false
+	}
+	if v.Pos() >= typ.Params.Pos() && v.Pos() < typ.Params.End() {
+		return false
+	}
+	return types.Identical(v.Type(), errorType)
+}
+
+// origin returns the expression last assigned to id before pos, if any.
+func (a *analyzer) origin(id *ast.Ident, body *ast.BlockStmt, pos token.Pos) ast.Expr {
+	var last ast.Expr
+	var lastPos token.Pos
+	consider := func(lhs ast.Expr, rhs ast.Expr) {
+		l, ok := lhs.(*ast.Ident)
+		if !ok || !a.sameVar(l, id) || l.Pos() >= pos || l.Pos() < lastPos {
+			return
+		}
+		last, lastPos = rhs, l.Pos()
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range n.Lhs {
+				switch {
+				case len(n.Rhs) == len(n.Lhs):
+					consider(lhs, n.Rhs[i])
+				case len(n.Rhs) == 1:
+					consider(lhs, n.Rhs[0])
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				switch {
+				case len(n.Values) == len(n.Names):
+					consider(name, n.Values[i])
+				case len(n.Values) == 1:
+					consider(name, n.Values[0])
+				}
+			}
+		}
+		return true
+	})
+	return last
+}
+
+func (a *analyzer) sameVar(x, y *ast.Ident) bool {
+	if a.info == nil {
+		return x.Name == y.Name
+	}
+	obj := a.info.ObjectOf(x)
+	return obj != nil && obj == a.info.ObjectOf(y)
+}
+
+// wrapFuncs lists the functions whose result is already a new or wrapped error.
+var wrapFuncs = map[string][]string{
+	"fmt":                           {"Errorf"},
+	"errors":                        {"New", "Join"},
+	"github.com/pkg/errors":         nil,
+	"github.com/cockroachdb/errors": nil,
+}
+
+func (a *analyzer) isWrapCall(call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+
+	var pkg string
+	if a.info != nil {
+		fn, ok := a.info.Uses[sel.Sel].(*types.Func)
+		if !ok || fn.Pkg() == nil {
+			return false
+		}
+		pkg = fn.Pkg().Path()
+	} else {
+		x, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		pkg = x.Name
+	}
+
+	names, ok := wrapFuncs[pkg]
+	if !ok {
+		return false
+	}
+	if names == nil {
+		return true
+	}
+	for _, name := range names {
+		if name == sel.Sel.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *analyzer) isErrorType(expr ast.Expr) bool {
+	if a.info == nil {
+		id, ok := expr.(*ast.Ident)
+		return ok && id.Name == "error"
+	}
+	t := a.info.TypeOf(expr)
+	return t != nil && types.Identical(t, errorType)
+}
+
+func (a *analyzer) text(n ast.Node) string {
+	return string(a.src[a.fset.Position(n.Pos()).Offset:a.fset.Position(n.End()).Offset])
+}
+
+// maxSnippetLines caps how far back a snippet reaches for the originating call.
+const maxSnippetLines = 12
+
+func (a *analyzer) snippet(from ast.Node, ret *ast.ReturnStmt) string {
+	start, end := a.fset.Position(from.Pos()), a.fset.Position(ret.End())
+	if end.Line-start.Line > maxSnippetLines {
+		start = a.fset.Position(ret.Pos())
+	}
+
+	// Extend to whole lines.
+	s := start.Offset - (start.Column - 1)
+	e := end.Offset
+	for e < len(a.src) && a.src[e] != '\n' {
+		e++
+	}
+	return string(a.src[s:e])
+}
+
+func resultCount(typ *ast.FuncType) int {
+	if typ.Results == nil {
+		return 0
+	}
+	n := 0
+	for _, field := range typ.Results.List {
+		n += max(len(field.Names), 1)
+	}
+	return n
+}
@@ -0,0 +1,166 @@
+package goerrwrap
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/snowmerak/useful-genkit/utils/gosource"
+	"golang.org/x/tools/go/packages"
+)
+
+// Filter keeps only the sites for which keep returns true.
+func (f *File) Filter(keep func(Site) bool) {
+	f.Sites = slices.DeleteFunc(f.Sites, func(s Site) bool { return !keep(s) })
+}
+
+// Rewrite wraps the error of every site with fmt.Errorf and the site's message, adding the
+// fmt import when needed. Sites without a message get FallbackMessage. A return that is
+// not inside an "if err != nil" block on its error becomes such a block returning the
+// wrapped error, followed by the original return with a nil error. Everything else in the
+// file is kept byte for byte. When the file was analyzed with type information, the result
+// is type-checked again before it is returned.
+func (f *File) Rewrite(messages map[int]string) ([]byte, error) {
+	if len(f.Sites) == 0 {
+		return f.src, nil
+	}
+
+	qualifier, err := fmtQualifier(f.src)
+	if err != nil {
+		return nil, err
+	}
+
+	sites := slices.Clone(f.Sites)
+	slices.SortFunc(sites, func(a, b Site) int { return b.start - a.start })
+
+	out := slices.Clone(f.src)
+	for _, s := range sites {
+		msg := cleanMessage(messages[s.ID])
+		if msg == "" {
+			msg = FallbackMessage(s)
+		}
+		wrapped := fmt.Sprintf("%s.Errorf(%s, %s)", qualifier, strconv.Quote(msg+": %w"), s.Var)
+		if s.guarded {
+			out = slices.Concat(out[:s.start], []byte(wrapped), out[s.end:])
+			continue
+		}
+
+		head, tail := string(f.src[s.stmtStart:s.start]), string(f.src[s.end:s.stmtEnd])
+		guarded := fmt.Sprintf("if %s != nil {\n%s\t%s%s%s\n%s}\n%s%snil%s",
+			s.Var, s.indent, head, wrapped, tail, s.indent, s.indent, head, tail)
+		out = slices.Concat(out[:s.stmtStart], []byte(guarded), out[s.stmtEnd:])
+	}
+
+	if qualifier == "fmt" {
+		if out, err = gosource.AddImport(out, "fmt"); err != nil {
+			return nil, fmt.Errorf("failed to add fmt import: %w", err)
+		}
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), f.Name, out, parser.ParseComments); err != nil {
+		return nil, fmt.Errorf("rewritten %s does not parse: %w", f.Name, err)
+	}
+	if f.Typed {
+		if err := typeCheck(f.Name, out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// fmtQualifier returns the name under which fmt is imported, or "fmt" when it is not.
+func fmtQualifier(src []byte) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ImportsOnly)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse imports: %w", err)
+	}
+	for _, imp := range file.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p != "fmt" || imp.Name == nil {
+			continue
+		}
+		if imp.Name.Name != "_" && imp.Name.Name != "." {
+			return imp.Name.Name, nil
+		}
+	}
+	return "fmt", nil
+}
+
+// typeCheck reports the errors of the package containing filename once src replaces it.
+func typeCheck(filename string, src []byte) error {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", filename, err)
+	}
+	pkgs, err := loadPackage(abs, src, packages.NeedTypes)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, e := range pkg.Errors {
+			errs = append(errs, e.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("rewritten %s does not compile: %s", filename, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// cleanMessage turns a model-written message into a format string prefix.
+func cleanMessage(msg string) string {
+	msg = strings.TrimSpace(msg)
+	msg = strings.Trim(msg, "\"`")
+	msg = strings.TrimSuffix(msg, "%w")
+	msg = strings.TrimRight(msg, " :.")
+	msg = strings.ReplaceAll(msg, "\n", " ")
+	return strings.ReplaceAll(msg, "%", "%%")
+}
+
+// FallbackMessage derives a message from the call the error came from, or from the
+// function name, such as "failed to read file" for os.ReadFile.
+func FallbackMessage(s Site) string {
+	name := s.Func
+	if s.Call != "" {
+		name = s.Call
+		if i := strings.IndexByte(name, '('); i >= 0 {
+			name = name[:i]
+		}
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	if words := splitWords(name); len(words) > 0 {
+		return "failed to " + strings.Join(words, " ")
+	}
+	return "failed in " + s.Func
+}
+
+// splitWords splits a camel-case identifier into lower-case words, keeping acronyms together.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		boundary := i == len(runes) || runes[i] == '_'
+		if !boundary && unicode.IsUpper(runes[i]) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			boundary = prevLower || (unicode.IsUpper(runes[i-1]) && nextLower)
+		}
+		if !boundary {
+			continue
+		}
+		if word := strings.Trim(string(runes[start:i]), "_"); word != "" {
+			words = append(words, strings.ToLower(word))
+		}
+		start = i
+	}
+	return words
+}
@@ -0,0 +1,142 @@
+package goerrwrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const loadSrc = `package load
+
+import "os"
+
+func Load(p string) ([]byte, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func LoadTail(p string) ([]byte, error) {
+	b, err := os.ReadFile(p)
+	return b, err
+}
+`
+
+const loadWant = `package load
+
+import (
+	"fmt"
+	"os"
+)
+
+func Load(p string) ([]byte, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return b, nil
+}
+
+func LoadTail(p string) ([]byte, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return b, fmt.Errorf("failed to read file: %w", err)
+	}
+	return b, nil
+}
+`
+
+func TestRewriteGuardsUncheckedReturns(t *testing.T) {
+	tests := []struct {
+		name   string
+		module bool
+	}{
+		{name: "typed", module: true},
+		{name: "syntactic", module: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.module {
+				if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/load\n\ngo 1.21\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			name := filepath.Join(dir, "load.go")
+			if err := os.WriteFile(name, []byte(loadSrc), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := Analyze(name, []byte(loadSrc))
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if tt.module && !f.Typed {
+				t.Fatal("Analyze did not type-check the module")
+			}
+			if len(f.Sites) != 2 {
+				t.Fatalf("found %d sites, want 2: %+v", len(f.Sites), f.Sites)
+			}
+			if !f.Sites[0].guarded || f.Sites[1].guarded {
+				t.Errorf("guarded = %v, %v; want true, false", f.Sites[0].guarded, f.Sites[1].guarded)
+			}
+
+			out, err := f.Rewrite(nil)
+			if err != nil {
+				t.Fatalf("Rewrite: %v", err)
+			}
+			if string(out) != loadWant {
+				t.Errorf("Rewrite =\n%s\nwant\n%s", out, loadWant)
+			}
+
+			again, err := Analyze(name, out)
+			if err != nil {
+				t.Fatalf("Analyze rewritten: %v", err)
+			}
+			if len(again.Sites) != 0 {
+				t.Errorf("rewritten file still has %d sites", len(again.Sites))
+			}
+		})
+	}
+}
+
+func TestRequiresNonNil(t *testing.T) {
+	const src = `package a
+
+func f(ok bool) error {
+	err := g()
+	if ok && err != nil {
+		return err
+	}
+	if nil != err {
+		return err
+	}
+	if err == nil {
+		return err
+	}
+	return nil
+}
+
+func g() error { return nil }
+`
+	f, err := Analyze(filepath.Join(t.TempDir(), "a.go"), []byte(src))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	var got []bool
+	for _, s := range f.Sites {
+		got = append(got, s.guarded)
+	}
+	want := []bool{true, true, false}
+	if len(got) != len(want) {
+		t.Fatalf("found %d sites, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("site %d guarded = %v, want %v", i+1, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package goguard
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/gosource"
+)
+
+// Policy lists the changes a rewrite may make besides editing function bodies.
+type Policy struct {
+	// AllowedImports lists the import paths that may be added.
+	AllowedImports []string
+	// AllowAnyImport lets any import be added.
+	AllowAnyImport bool
+	// AllowNewDecls lets top-level declarations be added. Removing them is never allowed.
+	AllowNewDecls bool
+	// AllowUnexportedSignatureChanges lets unexported functions and methods change their
+	// signature, for example to take a context.Context.
+	AllowUnexportedSignatureChanges bool
+	// AllowTypeChanges lets the definitions of existing types change, for example to add a field.
+	AllowTypeChanges bool
+	// AllowCommentRemoval lets the rewrite have fewer comments than the original.
+	AllowCommentRemoval bool
+}
+
+// Violation is a change the policy does not allow.
+type Violation struct {
+	Kind   string `json:"kind"`
+	Symbol string `json:"symbol,omitempty"`
+	Detail string `json:"detail"`
+}
+
+func (v Violation) String() string {
+	if v.Symbol == "" {
+		return fmt.Sprintf("%s: %s", v.Kind, v.Detail)
+	}
+	return fmt.Sprintf("%s %s: %s", v.Kind, v.Symbol, v.Detail)
+}
+
+// Violations explains every violation of a rewrite.
+type Violations []Violation
+
+func (vs Violations) String() string {
+	lines := make([]string, len(vs))
+	for i, v := range vs {
+		lines[i] = v.String()
+	}
+	return strings.Join(lines, "; ")
+}
+
+// summary is what the guard compares between the original and the rewritten file.
+type summary struct {
+	pkg        string
+	imports    []string
+	decls      map[string]int
+	signatures map[string]string
+	exported   map[string]bool
+	types      map[string]string
+	comments   int
+}
+
+// Check compares the top-level declarations, signatures, imports and comments of before
+// and after, and returns the changes the policy does not allow.
+func Check(filename string, before, after []byte, policy Policy) (Violations, error) {
+	old, err := summarize(filename, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse original %s: %w", filename, err)
+	}
+	cur, err := summarize(filename, after)
+	if err != nil {
+		return nil, fmt.Errorf("rewritten %s does not parse: %w", filename, err)
+	}
+
+	var vs Violations
+	if old.pkg != cur.pkg {
+		vs = append(vs, Violation{Kind: "package", Detail: fmt.Sprintf("renamed from %s to %s", old.pkg, cur.pkg)})
+	}
+
+	for _, imp := range old.imports {
+		if !slices.Contains(cur.imports, imp) {
+			vs = append(vs, Violation{Kind: "import", Symbol: strconv.Quote(imp), Detail: "removed"})
+		}
+	}
+	for _, imp := range cur.imports {
+		if !slices.Contains(old.imports, imp) && !policy.AllowAnyImport && !slices.Contains(policy.AllowedImports, imp) {
+			vs = append(vs, Violation{Kind: "import", Symbol: strconv.Quote(imp), Detail: "added"})
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(old.decls)) {
+		if n := cur.decls[key]; n < old.decls[key] {
+			vs = append(vs, Violation{Kind: "declaration", Symbol: key, Detail: "removed"})
+		}
+	}
+	if !policy.AllowNewDecls {
+		for _, key := range slices.Sorted(maps.Keys(cur.decls)) {
+			if n := old.decls[key]; n < cur.decls[key] {
+				vs = append(vs, Violation{Kind: "declaration", Symbol: key, Detail: "added"})
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(old.signatures)) {
+		sig, ok := cur.signatures[name]
+		if !ok || sig == old.signatures[name] {
+			continue
+		}
+		if !old.exported[name] && policy.AllowUnexportedSignatureChanges {
+			continue
+		}
+		vs = append(vs, Violation{Kind: "signature", Symbol: name, Detail: fmt.Sprintf("changed from %q to %q", old.signatures[name], sig)})
+	}
+
+	if !policy.AllowTypeChanges {
+		for _, name := range slices.Sorted(maps.Keys(old.types)) {
+			if def, ok := cur.types[name]; ok && def != old.types[name] {
+				vs = append(vs, Violation{Kind: "type", Symbol: name, Detail: "definition changed"})
+			}
+		}
+	}
+
+	if cur.comments < old.comments && !policy.AllowCommentRemoval {
+		vs = append(vs, Violation{Kind: "comments", Detail: fmt.Sprintf("%d of %d comments removed", old.comments-cur.comments, old.comments)})
+	}
+	return vs, nil
+}
+
+func summarize(filename string, src []byte) (*summary, error) {
+	fset := token.NewFileSet()
+	commented, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	// Declarations are compared without comments; the printer would include doc comments of fields.
+	f, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &summary{
+		pkg:        f.Name.Name,
+		decls:      make(map[string]int),
+		signatures: make(map[string]string),
+		exported:   make(map[string]bool),
+		types:      make(map[string]string),
+	}
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		s.imports = append(s.imports, p)
+	}
+	for _, group := range commented.Comments {
+		s.comments += len(group.List)
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := gosource.FuncName(d)
+			kind := "func"
+			if d.Recv != nil {
+				kind = "method"
+			}
+			s.decls[kind+" "+name]++
+			sig := &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}
+			s.signatures[name] = render(fset, sig)
+			s.exported[name] = d.Name.IsExported()
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					s.decls["type "+sp.Name.Name]++
+					s.types[sp.Name.Name] = render(fset, &ast.TypeSpec{Name: sp.Name, TypeParams: sp.TypeParams, Assign: sp.Assign, Type: sp.Type})
+				case *ast.ValueSpec:
+					for _, n := range sp.Names {
+						if n.Name != "_" {
+							s.decls[d.Tok.String()+" "+n.Name]++
+						}
+					}
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// render prints a node on one line, so layout changes do not count.
+func render(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
@@ -0,0 +1,219 @@
+package gosource
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// Chunk is a function declaration selected for rewriting. Code spans from the func
+// keyword to the closing brace; the doc comment is left in the file untouched.
+type Chunk struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Code string `json:"code"`
+
+	start, end int
+	recv       string
+}
+
+// File is a parsed Go source file split into rewritable chunks.
+type File struct {
+	Name   string
+	Chunks []Chunk
+
+	src  []byte
+	fset *token.FileSet
+	file *ast.File
+}
+
+// Split parses src and selects the function declarations accepted by selector.
+func Split(filename string, src []byte, selector func(*ast.FuncDecl) bool) (*File, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	file := &File{Name: filename, src: src, fset: fset, file: f}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || !selector(fn) {
+			continue
+		}
+		start := fset.Position(fn.Pos()).Offset
+		end := fset.Position(fn.End()).Offset
+		file.Chunks = append(file.Chunks, Chunk{
+			ID:    len(file.Chunks) + 1,
+			Name:  FuncName(fn),
+			Code:  string(src[start:end]),
+			start: start,
+			end:   end,
+			recv:  receiverType(fn),
+		})
+	}
+	return file, nil
+}
+
+// Context returns the minimal surrounding source a model needs to rewrite the chunks:
+// the package clause, the imports and the declarations of the chunks' receiver types.
+func (f *File) Context() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "package %s\n", f.file.Name.Name)
+
+	var receivers []string
+	for _, c := range f.Chunks {
+		if c.recv != "" && !slices.Contains(receivers, c.recv) {
+			receivers = append(receivers, c.recv)
+		}
+	}
+
+	for _, decl := range f.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		switch gen.Tok {
+		case token.IMPORT:
+			buf.WriteString("\n")
+			buf.Write(f.source(gen))
+			buf.WriteString("\n")
+		case token.TYPE:
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if !slices.Contains(receivers, ts.Name.Name) {
+					continue
+				}
+				buf.WriteString("\n")
+				if gen.Lparen.IsValid() {
+					buf.WriteString("type ")
+					buf.Write(f.source(ts))
+				} else {
+					buf.Write(f.source(gen))
+				}
+				buf.WriteString("\n")
+			}
+		}
+	}
+	return buf.String()
+}
+
+// Splice replaces the chunks with the given IDs by new function source and returns the
+// resulting file. Everything outside the replaced chunks is kept byte for byte.
+// Each replacement must be a single function declaration with the chunk's name.
+func (f *File) Splice(replacements map[int]string) ([]byte, error) {
+	chunks := slices.Clone(f.Chunks)
+	slices.SortFunc(chunks, func(a, b Chunk) int { return b.start - a.start })
+
+	out := bytes.Clone(f.src)
+	for _, c := range chunks {
+		code, ok := replacements[c.ID]
+		if !ok {
+			continue
+		}
+		code = strings.TrimSpace(StripCodeFence(code))
+		if err := checkReplacement(c, code); err != nil {
+			return nil, err
+		}
+		out = append(out[:c.start:c.start], append([]byte(code), out[c.end:]...)...)
+	}
+	return out, nil
+}
+
+func checkReplacement(c Chunk, code string) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "package p\n\n"+code, parser.SkipObjectResolution)
+	if err != nil {
+		return fmt.Errorf("rewritten %s does not parse: %w", c.Name, err)
+	}
+	if len(f.Decls) != 1 {
+		return fmt.Errorf("rewritten %s must be exactly one function declaration, got %d declarations", c.Name, len(f.Decls))
+	}
+	fn, ok := f.Decls[0].(*ast.FuncDecl)
+	if !ok {
+		return fmt.Errorf("rewritten %s is not a function declaration", c.Name)
+	}
+	if name := FuncName(fn); name != c.Name {
+		return fmt.Errorf("rewritten %s was renamed to %s", c.Name, name)
+	}
+	return nil
+}
+
+func (f *File) source(n ast.Node) []byte {
+	return f.src[f.fset.Position(n.Pos()).Offset:f.fset.Position(n.End()).Offset]
+}
+
+// FuncName names a function declaration; methods are named Receiver.Method.
+func FuncName(fn *ast.FuncDecl) string {
+	if recv := receiverType(fn); recv != "" {
+		return recv + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+func receiverType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	typ := fn.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.ParenExpr:
+			typ = t.X
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.IndexListExpr:
+			typ = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// StripCodeFence removes a surrounding markdown code fence from model output.
+func StripCodeFence(code string) string {
+	code = strings.TrimSpace(code)
+	if !strings.HasPrefix(code, "```") {
+		return code
+	}
+	code = strings.TrimPrefix(code, "```")
+	if i := strings.IndexByte(code, '\n'); i >= 0 {
+		code = code[i+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSpace(code), "```")
+}
+
+// ReturnsBareError reports whether fn returns an error variable without wrapping it,
+// such as "return err" or "return nil, err".
+func ReturnsBareError(fn *ast.FuncDecl) bool {
+	found := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok {
+			return true
+		}
+		for _, r := range ret.Results {
+			if id, ok := r.(*ast.Ident); ok && IsErrorName(id.Name) {
+				found = true
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// IsErrorName reports whether name is conventionally used for an error variable.
+func IsErrorName(name string) bool {
+	return name == "err" || strings.HasSuffix(name, "Err")
+}
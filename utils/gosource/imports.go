@@ -0,0 +1,170 @@
+package gosource
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// AddImport adds path to the imports of src unless it is already imported. Only the
+// import declaration is touched; the rest of the file is kept byte for byte.
+func AddImport(src []byte, path string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse imports: %w", err)
+	}
+
+	for _, imp := range f.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == path {
+			return src, nil
+		}
+	}
+
+	quoted := strconv.Quote(path)
+	insert := func(offset int, text string) []byte {
+		out := make([]byte, 0, len(src)+len(text))
+		out = append(out, src[:offset]...)
+		out = append(out, text...)
+		return append(out, src[offset:]...)
+	}
+
+	var last *ast.GenDecl
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		if gen.Lparen.IsValid() {
+			if !specsOnOwnLines(fset, gen) {
+				start := fset.Position(gen.Pos()).Offset
+				end := fset.Position(gen.End()).Offset
+				group := renderGroup(fset, src, gen, path)
+				out := make([]byte, 0, len(src)+len(group))
+				out = append(out, src[:start]...)
+				out = append(out, group...)
+				return append(out, src[end:]...), nil
+			}
+			return insert(groupOffset(fset, gen, path), "\t"+quoted+"\n"), nil
+		}
+		last = gen
+	}
+	if last != nil {
+		// Turn a lone single-line import into a group.
+		spec := last.Specs[0].(*ast.ImportSpec)
+		if len(f.Imports) == 1 && spec.Doc == nil && spec.Comment == nil {
+			start := fset.Position(last.Pos()).Offset
+			end := fset.Position(last.End()).Offset
+			existing := string(src[fset.Position(spec.Pos()).Offset:end])
+			lines := []string{existing, quoted}
+			if p, _ := strconv.Unquote(spec.Path.Value); p > path {
+				lines = []string{quoted, existing}
+			}
+			group := "import (\n\t" + lines[0] + "\n\t" + lines[1] + "\n)"
+			out := make([]byte, 0, len(src)+len(group))
+			out = append(out, src[:start]...)
+			out = append(out, group...)
+			return append(out, src[end:]...), nil
+		}
+		offset := fset.Position(last.End()).Offset
+		return insert(offset, "\nimport "+quoted), nil
+	}
+
+	offset := fset.Position(f.Name.End()).Offset
+	return insert(offset, "\n\nimport "+quoted), nil
+}
+
+// UsesPackage reports whether src refers to name as a package qualifier, as in name.X.
+func UsesPackage(src []byte, name string) (bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse: %w", err)
+	}
+	used := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == name {
+				used = true
+			}
+		}
+		return !used
+	})
+	return used, nil
+}
+
+// specsOnOwnLines reports whether the specs of a parenthesized import declaration start
+// after the line of its opening parenthesis and end before the line of the closing one,
+// so that a new spec can be inserted as a line of its own.
+func specsOnOwnLines(fset *token.FileSet, gen *ast.GenDecl) bool {
+	tf := fset.File(gen.Pos())
+	if len(gen.Specs) == 0 {
+		return tf.Line(gen.Lparen) != tf.Line(gen.Rparen)
+	}
+	first := gen.Specs[0].(*ast.ImportSpec)
+	last := gen.Specs[len(gen.Specs)-1].(*ast.ImportSpec)
+	firstPos, lastEnd := first.Pos(), last.End()
+	if first.Doc != nil {
+		firstPos = first.Doc.Pos()
+	}
+	if last.Comment != nil {
+		lastEnd = last.Comment.End()
+	}
+	return tf.Line(firstPos) > tf.Line(gen.Lparen) && tf.Line(lastEnd) < tf.Line(gen.Rparen)
+}
+
+// renderGroup rewrites a parenthesized import declaration whose specs share a line with
+// a parenthesis, such as import ("os"), as a multi-line group that also imports path.
+func renderGroup(fset *token.FileSet, src []byte, gen *ast.GenDecl, path string) string {
+	quoted := strconv.Quote(path)
+	group := "import (\n"
+	added := false
+	for _, spec := range gen.Specs {
+		imp := spec.(*ast.ImportSpec)
+		if p, _ := strconv.Unquote(imp.Path.Value); !added && p > path {
+			group += "\t" + quoted + "\n"
+			added = true
+		}
+		start, end := imp.Pos(), imp.End()
+		if imp.Doc != nil {
+			start = imp.Doc.Pos()
+		}
+		if imp.Comment != nil {
+			end = imp.Comment.End()
+		}
+		group += "\t" + string(src[fset.Position(start).Offset:fset.Position(end).Offset]) + "\n"
+	}
+	if !added {
+		group += "\t" + quoted + "\n"
+	}
+	return group + ")"
+}
+
+// groupOffset returns the start of the line where path belongs in sorted order within
+// the first group of a parenthesized import declaration whose specs are on their own lines.
+func groupOffset(fset *token.FileSet, gen *ast.GenDecl, path string) int {
+	tf := fset.File(gen.Pos())
+	lparenLine := tf.Line(gen.Lparen)
+	if len(gen.Specs) == 0 {
+		return tf.Offset(tf.LineStart(lparenLine + 1))
+	}
+
+	prevLine := 0
+	for _, spec := range gen.Specs {
+		imp := spec.(*ast.ImportSpec)
+		line := tf.Line(imp.Pos())
+		if prevLine != 0 && line > prevLine+1 {
+			break
+		}
+		if p, _ := strconv.Unquote(imp.Path.Value); p > path {
+			return tf.Offset(tf.LineStart(line))
+		}
+		prevLine = tf.Line(imp.End())
+	}
+	if prevLine >= tf.LineCount() {
+		return tf.Size()
+	}
+	return tf.Offset(tf.LineStart(prevLine + 1))
+}
@@ -0,0 +1,61 @@
+package gosource
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestAddImport(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "group",
+			src:  "package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n",
+			want: "package a\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		{
+			name: "one-line group",
+			src:  "package a\n\nimport (\"os\")\n\nvar _ = os.Args\n",
+			want: "package a\n\nimport (\n\t\"errors\"\n\t\"os\"\n)\n\nvar _ = os.Args\n",
+		},
+		{
+			name: "one-line group at end of file",
+			src:  "package a\n\nimport (\"os\")",
+			want: "package a\n\nimport (\n\t\"errors\"\n\t\"os\"\n)",
+		},
+		{
+			name: "closing paren after last spec",
+			src:  "package a\n\nimport (\n\t\"fmt\"\n\t\"os\")\n",
+			want: "package a\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n\t\"os\"\n)\n",
+		},
+		{
+			name: "single import",
+			src:  "package a\n\nimport \"os\"\n",
+			want: "package a\n\nimport (\n\t\"errors\"\n\t\"os\"\n)\n",
+		},
+		{
+			name: "already imported",
+			src:  "package a\n\nimport (\"errors\")\n",
+			want: "package a\n\nimport (\"errors\")\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AddImport([]byte(tt.src), "errors")
+			if err != nil {
+				t.Fatalf("AddImport: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("AddImport =\n%s\nwant\n%s", got, tt.want)
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), "", got, parser.ImportsOnly); err != nil {
+				t.Errorf("result does not parse: %v", err)
+			}
+		})
+	}
+}
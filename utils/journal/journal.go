@@ -0,0 +1,344 @@
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/useful-genkit/utils/state"
+)
+
+const (
+	journalDirName  = "journal"
+	entriesFileName = "entries.jsonl"
+	undoneFileName  = "entries.undone.jsonl"
+	backupDirName   = "backups"
+)
+
+// Op is the kind of a journaled mutation.
+type Op string
+
+const (
+	OpWrite  Op = "write"
+	OpRemove Op = "remove"
+	OpMkdir  Op = "mkdir"
+)
+
+// Entry records a single mutation together with what is needed to revert it.
+type Entry struct {
+	Seq     int         `json:"seq"`
+	Op      Op          `json:"op"`
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"`
+	Mode    fs.FileMode `json:"mode,omitempty"`
+	Backup  string      `json:"backup,omitempty"`
+	Time    time.Time   `json:"time"`
+}
+
+// Journal is a write-ahead log of every file mutation of a run. Each entry is persisted,
+// with a backup of the original content, before the mutation is performed.
+type Journal struct {
+	RunID string
+
+	mu  sync.Mutex
+	dir string
+	seq int
+}
+
+// Open opens the journal of the given run, continuing an existing one.
+func Open(runID string) (*Journal, error) {
+	runDir, err := state.RunDir(runID)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(runDir, journalDirName)
+	if err := os.MkdirAll(filepath.Join(dir, backupDirName), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	entries, err := readEntries(filepath.Join(dir, entriesFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{RunID: runID, dir: dir}
+	if len(entries) > 0 {
+		j.seq = entries[len(entries)-1].Seq
+	}
+	return j, nil
+}
+
+// WriteFile atomically replaces the content of path, keeping the mode of an existing file.
+func (j *Journal) WriteFile(path string, data []byte, perm os.FileMode) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	e := Entry{Op: OpWrite, Path: path}
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil:
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+		e.Existed = true
+		e.Mode = info.Mode().Perm()
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	if err := j.record(&e); err != nil {
+		return err
+	}
+	return AtomicWriteFile(path, data, perm)
+}
+
+// RemoveAll removes path and everything below it after backing it up.
+func (j *Journal) RemoveAll(path string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	e := Entry{Op: OpRemove, Path: path, Existed: true, Mode: info.Mode().Perm()}
+	if err := j.record(&e); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// MkdirAll creates path and any missing parents, recording the topmost directory it created.
+func (j *Journal) MkdirAll(path string, perm os.FileMode) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	top := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to stat %s: %w", dir, err)
+		}
+		top = dir
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if top == "" {
+		return nil
+	}
+
+	e := Entry{Op: OpMkdir, Path: top}
+	if err := j.record(&e); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path, perm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", path, err)
+	}
+	return nil
+}
+
+// record backs up the current content of e.Path when it exists and appends e to the journal.
+func (j *Journal) record(e *Entry) error {
+	j.seq++
+	e.Seq = j.seq
+	e.Time = time.Now()
+
+	if e.Existed {
+		e.Backup = strconv.Itoa(e.Seq)
+		if err := copyTree(e.Path, filepath.Join(j.dir, backupDirName, e.Backup)); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", e.Path, err)
+		}
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(j.dir, entriesFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to append journal entry: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return nil
+}
+
+// Undo reverts every mutation of the run in reverse order and returns the restored paths.
+// A journal can only be undone once.
+func Undo(runID string) ([]string, error) {
+	runDir, err := state.RunDir(runID)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(runDir, journalDirName)
+	entriesPath := filepath.Join(dir, entriesFileName)
+
+	if _, err := os.Stat(entriesPath); errors.Is(err, fs.ErrNotExist) {
+		if _, err := os.Stat(filepath.Join(dir, undoneFileName)); err == nil {
+			return nil, fmt.Errorf("run %s has already been undone", runID)
+		}
+		return nil, fmt.Errorf("run %s has no journal", runID)
+	}
+
+	entries, err := readEntries(entriesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []string
+	seen := make(map[string]struct{})
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if err := revert(dir, e); err != nil {
+			return restored, fmt.Errorf("failed to revert %s of %s: %w", e.Op, e.Path, err)
+		}
+		if _, ok := seen[e.Path]; !ok {
+			seen[e.Path] = struct{}{}
+			restored = append(restored, e.Path)
+		}
+	}
+
+	if err := os.Rename(entriesPath, filepath.Join(dir, undoneFileName)); err != nil {
+		return restored, fmt.Errorf("failed to mark journal as undone: %w", err)
+	}
+	return restored, nil
+}
+
+func revert(dir string, e Entry) error {
+	backup := filepath.Join(dir, backupDirName, e.Backup)
+
+	switch e.Op {
+	case OpWrite:
+		if !e.Existed {
+			if err := os.Remove(e.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		data, err := os.ReadFile(backup)
+		if err != nil {
+			return err
+		}
+		if err := AtomicWriteFile(e.Path, data, e.Mode); err != nil {
+			return err
+		}
+		return os.Chmod(e.Path, e.Mode)
+	case OpRemove:
+		if err := os.RemoveAll(e.Path); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+			return err
+		}
+		return copyTree(backup, e.Path)
+	case OpMkdir:
+		return os.RemoveAll(e.Path)
+	default:
+		return fmt.Errorf("unknown journal operation %q", e.Op)
+	}
+}
+
+func readEntries(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("failed to parse journal entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return entries, nil
+}
+
+type contextKey struct{}
+
+// WithJournal returns a context whose file mutations are recorded in j.
+func WithJournal(ctx context.Context, j *Journal) context.Context {
+	return context.WithValue(ctx, contextKey{}, j)
+}
+
+// FromContext returns the journal carried by ctx, or nil.
+func FromContext(ctx context.Context) *Journal {
+	j, _ := ctx.Value(contextKey{}).(*Journal)
+	return j
+}
+
+// WriteFile writes through the journal in ctx, or atomically without one.
+func WriteFile(ctx context.Context, path string, data []byte, perm os.FileMode) error {
+	if j := FromContext(ctx); j != nil {
+		return j.WriteFile(path, data, perm)
+	}
+	return AtomicWriteFile(path, data, perm)
+}
+
+// RemoveAll removes path through the journal in ctx, or directly without one.
+func RemoveAll(ctx context.Context, path string) error {
+	if j := FromContext(ctx); j != nil {
+		return j.RemoveAll(path)
+	}
+	return os.RemoveAll(path)
+}
+
+// MkdirAll creates path through the journal in ctx, or directly without one.
+func MkdirAll(ctx context.Context, path string, perm os.FileMode) error {
+	if j := FromContext(ctx); j != nil {
+		return j.MkdirAll(path, perm)
+	}
+	return os.MkdirAll(path, perm)
+}
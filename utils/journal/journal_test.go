@@ -0,0 +1,134 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snowmerak/useful-genkit/utils/state"
+)
+
+// snapshot records every file and directory under root with its mode and content.
+func snapshot(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		if d.IsDir() {
+			tree[rel] = fmt.Sprintf("dir %v", info.Mode())
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		tree[rel] = fmt.Sprintf("file %v %q", info.Mode(), content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func writeFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	// Set the mode explicitly, regardless of the umask.
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndoRestoresTree(t *testing.T) {
+	t.Setenv(state.DirEnv, t.TempDir())
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "main.go"), "package main\n", 0644)
+	writeFile(t, filepath.Join(root, "run.sh"), "#!/bin/sh\necho hi\n", 0755)
+	writeFile(t, filepath.Join(root, "old", "a.txt"), "a\n", 0644)
+	writeFile(t, filepath.Join(root, "old", "nested", "b.txt"), "b\n", 0600)
+	before := snapshot(t, root)
+
+	const runID = "undo-test"
+	j, err := Open(runID)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	ctx := WithJournal(context.Background(), j)
+
+	steps := []struct {
+		name string
+		do   func() error
+	}{
+		{"overwrite", func() error {
+			return WriteFile(ctx, filepath.Join(root, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+		}},
+		{"overwrite twice", func() error {
+			return WriteFile(ctx, filepath.Join(root, "main.go"), []byte("package main // v2\n"), 0644)
+		}},
+		{"keep mode", func() error { return WriteFile(ctx, filepath.Join(root, "run.sh"), []byte("#!/bin/sh\n"), 0644) }},
+		{"mkdir", func() error { return MkdirAll(ctx, filepath.Join(root, "gen", "deep"), 0755) }},
+		{"create in new dir", func() error {
+			return WriteFile(ctx, filepath.Join(root, "gen", "deep", "new.go"), []byte("package deep\n"), 0644)
+		}},
+		{"create", func() error { return WriteFile(ctx, filepath.Join(root, "new.go"), []byte("package main\n"), 0644) }},
+		{"remove tree", func() error { return RemoveAll(ctx, filepath.Join(root, "old")) }},
+		{"recreate removed dir", func() error { return MkdirAll(ctx, filepath.Join(root, "old"), 0700) }},
+		{"recreate removed file", func() error { return WriteFile(ctx, filepath.Join(root, "old", "a.txt"), []byte("replaced\n"), 0644) }},
+	}
+	for _, s := range steps {
+		if err := s.do(); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+	}
+	if maps.Equal(before, snapshot(t, root)) {
+		t.Fatal("the journaled mutations did not change the tree")
+	}
+
+	if _, err := Undo(runID); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	after := snapshot(t, root)
+	if !maps.Equal(before, after) {
+		t.Errorf("tree after undo differs\nbefore: %v\nafter:  %v", before, after)
+	}
+
+	if _, err := Undo(runID); err == nil {
+		t.Error("a journal was undone twice")
+	}
+}
+
+func TestAtomicWriteFileKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.sh")
+	writeFile(t, path, "old\n", 0750)
+
+	if err := AtomicWriteFile(path, []byte("new\n"), 0644); err != nil {
+		t.Fatalf("AtomicWriteFile: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("mode = %v, want 0750", info.Mode().Perm())
+	}
+	if content, _ := os.ReadFile(path); string(content) != "new\n" {
+		t.Errorf("content = %q", content)
+	}
+}
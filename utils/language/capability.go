@@ -0,0 +1,54 @@
+package language
+
+import "slices"
+
+// Capability is a tool or flow that works with code of some languages only. Its value is
+// the name the tool or flow is registered under.
+type Capability string
+
+const (
+	FindDefinition               Capability = "FindDefinition"
+	FindUsage                    Capability = "FindUsage"
+	FindStructs                  Capability = "FindStructs"
+	FindInterfaceImplementations Capability = "FindInterfaceImplementations"
+	CallGraph                    Capability = "CallGraph"
+	WrapGoErrorFlow              Capability = "WrapGoErrorFlow"
+	ErrorContextFlow             Capability = "ErrorContextFlow"
+	LogPrismFlow                 Capability = "LogPrismFlow"
+)
+
+// capabilities are the languages each tool and flow supports. Go is type-checked by the
+// code tools; the other languages are searched with ast-grep or rewritten with a prompt
+// of their own.
+var capabilities = map[Capability][]Language{
+	FindDefinition:               {Go, C, Cpp, CSharp, Java, JavaScript, Lua, Php, Python, Ruby, Rust, Scala, Swift, TypeScript, Tsx},
+	FindUsage:                    {Go, Bash, C, Cpp, CSharp, Java, JavaScript, Kotlin, Lua, Php, Python, Ruby, Rust, Scala, Swift, TypeScript, Tsx},
+	FindStructs:                  {Go, C, Cpp, CSharp, Java, JavaScript, Kotlin, Python, Rust, Swift, TypeScript, Tsx},
+	FindInterfaceImplementations: {Go, CSharp, Java, Kotlin, Php, Python, Rust, Swift, TypeScript, Tsx},
+	CallGraph:                    {Go},
+	WrapGoErrorFlow:              {Go},
+	ErrorContextFlow:             {Go, Python, Rust, TypeScript, Tsx},
+	LogPrismFlow:                 {Go, JavaScript, Python, Rust, TypeScript, Tsx},
+}
+
+// Supports reports whether the tool or flow c supports lang.
+func Supports(lang Language, c Capability) bool {
+	return slices.Contains(capabilities[c], lang)
+}
+
+// Supported returns the languages the tool or flow c supports.
+func Supported(c Capability) []Language {
+	return slices.Clone(capabilities[c])
+}
+
+// Capabilities returns the tools and flows supporting lang, sorted by name.
+func Capabilities(lang Language) []Capability {
+	var caps []Capability
+	for c, langs := range capabilities {
+		if slices.Contains(langs, lang) {
+			caps = append(caps, c)
+		}
+	}
+	slices.Sort(caps)
+	return caps
+}
@@ -0,0 +1,274 @@
+package language
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/gitignore"
+)
+
+// ErrNotDetected is returned when no language is found for a path.
+var ErrNotDetected = errors.New("could not detect the language")
+
+// maxDetectFiles bounds the files counted when detecting the language of a directory.
+const maxDetectFiles = 5000
+
+// maxShebangLength bounds the first line read from a file for its shebang.
+const maxShebangLength = 256
+
+// errDetectLimit stops the walk once enough files are counted.
+var errDetectLimit = errors.New("file limit reached")
+
+// extensions are the file extensions of each language, as ast-grep maps them, the most
+// common first.
+var extensions = map[Language][]string{
+	Bash:       {".sh", ".bash", ".zsh", ".ksh", ".bats", ".command"},
+	C:          {".c", ".h"},
+	Cpp:        {".cpp", ".cc", ".cxx", ".c++", ".hpp", ".hh", ".hxx", ".cu", ".ino"},
+	CSharp:     {".cs"},
+	Css:        {".css", ".scss"},
+	Dockerfile: {".dockerfile"},
+	Elixir:     {".ex", ".exs"},
+	Go:         {".go"},
+	Haskell:    {".hs"},
+	Hcl:        {".hcl", ".tf", ".tfvars", ".nomad"},
+	Html:       {".html", ".htm", ".xhtml"},
+	Java:       {".java"},
+	JavaScript: {".js", ".mjs", ".cjs", ".jsx"},
+	Json:       {".json"},
+	Kotlin:     {".kt", ".kts", ".ktm"},
+	Lua:        {".lua"},
+	Nix:        {".nix"},
+	Php:        {".php"},
+	Python:     {".py", ".pyi", ".py3", ".bzl"},
+	Ruby:       {".rb", ".rbw", ".gemspec", ".rake"},
+	Rust:       {".rs"},
+	Scala:      {".scala", ".sc", ".sbt"},
+	Solidity:   {".sol"},
+	Swift:      {".swift"},
+	TypeScript: {".ts", ".mts", ".cts"},
+	Tsx:        {".tsx"},
+	Yaml:       {".yaml", ".yml"},
+}
+
+// byExtension is the reverse of extensions.
+var byExtension = func() map[string]Language {
+	m := make(map[string]Language)
+	for lang, exts := range extensions {
+		for _, ext := range exts {
+			m[ext] = lang
+		}
+	}
+	return m
+}()
+
+// filenames are files named without, or regardless of, an extension.
+var filenames = map[string]Language{
+	"Dockerfile":    Dockerfile,
+	"Containerfile": Dockerfile,
+	"go.mod":        Go,
+	"go.sum":        Go,
+	"go.work":       Go,
+	"Gemfile":       Ruby,
+	"Rakefile":      Ruby,
+	"Vagrantfile":   Ruby,
+	"BUILD":         Python,
+	"BUILD.bazel":   Python,
+	"WORKSPACE":     Python,
+	".bashrc":       Bash,
+	".bash_profile": Bash,
+	".zshrc":        Bash,
+	".profile":      Bash,
+}
+
+// interpreters are the programs named by shebang lines, without a version suffix.
+var interpreters = map[string]Language{
+	"sh":         Bash,
+	"bash":       Bash,
+	"zsh":        Bash,
+	"ksh":        Bash,
+	"dash":       Bash,
+	"ash":        Bash,
+	"python":     Python,
+	"pypy":       Python,
+	"node":       JavaScript,
+	"nodejs":     JavaScript,
+	"deno":       TypeScript,
+	"bun":        TypeScript,
+	"ts-node":    TypeScript,
+	"tsx":        TypeScript,
+	"ruby":       Ruby,
+	"php":        Php,
+	"lua":        Lua,
+	"luajit":     Lua,
+	"elixir":     Elixir,
+	"runhaskell": Haskell,
+	"runghc":     Haskell,
+	"scala":      Scala,
+	"swift":      Swift,
+	"kotlin":     Kotlin,
+}
+
+// data are the languages of configuration and markup files, which do not decide the
+// language of a directory holding code.
+var data = []Language{Css, Dockerfile, Html, Json, Yaml}
+
+// Extensions returns the file extensions of lang, with the leading dot, the most common first.
+func Extensions(lang Language) []string {
+	return slices.Clone(extensions[lang])
+}
+
+// FromExtension returns the language of files with the extension ext, like ".go".
+func FromExtension(ext string) (Language, bool) {
+	lang, ok := byExtension[strings.ToLower(ext)]
+	return lang, ok
+}
+
+// notSource are the endings of files written in the syntax of a language that hold no
+// program code: Python type stubs, Starlark build files and TypeScript declarations.
+var notSource = []string{".pyi", ".bzl", ".d.ts"}
+
+// FromSourceExtension returns the language of the source file named name by its extension
+// alone. Unlike FromFilename, it leaves out files like go.mod and BUILD, and those in
+// notSource, which tools rewriting code must not treat as code.
+func FromSourceExtension(name string) (Language, bool) {
+	name = strings.ToLower(filepath.Base(name))
+	for _, suffix := range notSource {
+		if strings.HasSuffix(name, suffix) {
+			return "", false
+		}
+	}
+	return FromExtension(filepath.Ext(name))
+}
+
+// FromFilename returns the language of the file named name, checking well-known names
+// like "Dockerfile" and "go.mod" before the extension.
+func FromFilename(name string) (Language, bool) {
+	name = filepath.Base(name)
+	if lang, ok := filenames[name]; ok {
+		return lang, true
+	}
+	// Variants like "Dockerfile.dev".
+	if strings.HasPrefix(name, "Dockerfile.") || strings.HasPrefix(name, "Containerfile.") {
+		return Dockerfile, true
+	}
+	return FromExtension(filepath.Ext(name))
+}
+
+// FromShebang returns the language of a script from its first line, like
+// "#!/usr/bin/env python3" or "#!/bin/bash -e".
+func FromShebang(line string) (Language, bool) {
+	line, ok := strings.CutPrefix(strings.TrimSpace(line), "#!")
+	if !ok {
+		return "", false
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", false
+	}
+	program := filepath.Base(fields[0])
+	if program == "env" {
+		// Skip the options of env, like "-S", to the program it runs.
+		program = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				program = field
+				break
+			}
+		}
+	}
+	// "python3.12" runs python.
+	program = strings.TrimRight(program, "0123456789.")
+	lang, ok := interpreters[program]
+	return lang, ok
+}
+
+// Detect returns the language of the file or directory at path. A file is detected by
+// its name, then by its shebang line. A directory is detected by the most common language
+// of the files in it, skipping files ignored by .gitignore and hidden directories, and
+// counting configuration and markup files only when it holds no code. When candidates are
+// given, the language of a directory is chosen among them.
+func Detect(path string, candidates ...Language) (Language, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return detectFile(path)
+	}
+
+	counts := make(map[Language]int)
+	files := 0
+	err = gitignore.Walk(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != path && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		lang, ok := FromFilename(d.Name())
+		if !ok || (len(candidates) > 0 && !slices.Contains(candidates, lang)) {
+			return nil
+		}
+		counts[lang]++
+		files++
+		if files >= maxDetectFiles {
+			return errDetectLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDetectLimit) {
+		return "", fmt.Errorf("failed to walk directory %s: %w", path, err)
+	}
+
+	var best Language
+	for _, code := range []bool{true, false} {
+		for lang, n := range counts {
+			if slices.Contains(data, lang) == code {
+				continue
+			}
+			// Ties go to the first name, so the result does not depend on map order.
+			if best == "" || n > counts[best] || (n == counts[best] && lang < best) {
+				best = lang
+			}
+		}
+		if best != "" {
+			return best, nil
+		}
+	}
+	return "", fmt.Errorf("%w in %s", ErrNotDetected, path)
+}
+
+// detectFile detects the language of the file at path by its name or shebang line.
+func detectFile(path string) (Language, error) {
+	if lang, ok := FromFilename(path); ok {
+		return lang, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// Only the start of the file is read, which may not be text.
+	head := make([]byte, maxShebangLength)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	line, _, _ := strings.Cut(string(head[:n]), "\n")
+	if lang, ok := FromShebang(line); ok {
+		return lang, nil
+	}
+	return "", fmt.Errorf("%w of %s", ErrNotDetected, path)
+}
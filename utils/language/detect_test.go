@@ -0,0 +1,173 @@
+package language
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFromShebang(t *testing.T) {
+	tests := []struct {
+		line string
+		want Language
+		ok   bool
+	}{
+		{"#!/bin/sh", Bash, true},
+		{"#!/bin/bash -e", Bash, true},
+		{"  #! /usr/bin/zsh", Bash, true},
+		{"#!/usr/bin/env python3", Python, true},
+		{"#!/usr/bin/python3.12", Python, true},
+		{"#!/usr/bin/env python3.12 -u", Python, true},
+		{"#!/usr/bin/env -S node --experimental-modules", JavaScript, true},
+		{"#!/usr/bin/env -S NODE_OPTIONS=--trace deno run", TypeScript, true},
+		{"#!/usr/bin/env -i PATH=/bin ruby", Ruby, true},
+		{"#!/usr/local/bin/luajit2.1", Lua, true},
+		{"#!/usr/bin/env", "", false},
+		{"#!/usr/bin/env -S", "", false},
+		{"#!/usr/bin/perl", "", false},
+		{"#!", "", false},
+		{"package main", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FromShebang(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FromShebang(%q) = %q, %v, want %q, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFromSourceExtension(t *testing.T) {
+	tests := []struct {
+		name string
+		want Language
+		ok   bool
+	}{
+		{"main.go", Go, true},
+		{"dir/Main.JAVA", Java, true},
+		{"app.py", Python, true},
+		{"index.ts", TypeScript, true},
+		{"App.tsx", Tsx, true},
+		{"go.mod", "", false},
+		{"go.sum", "", false},
+		{"go.work", "", false},
+		{"BUILD", "", false},
+		{"BUILD.bazel", "", false},
+		{"WORKSPACE", "", false},
+		{"Dockerfile", "", false},
+		{"Gemfile", "", false},
+		{"defs.bzl", "", false},
+		{"types.pyi", "", false},
+		{"index.d.ts", "", false},
+		{"README", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FromSourceExtension(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FromSourceExtension(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// writeFiles creates the files under dir, each holding its name.
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      []string
+		candidates []Language
+		want       Language
+	}{
+		{"most common", []string{"a.py", "b.py", "c.go"}, nil, Python},
+		{"tie goes to the first name", []string{"a.rb", "b.py"}, nil, Python},
+		{"known file names", []string{"go.mod", "go.sum", "main.py"}, nil, Go},
+		{"code over data", []string{"a.json", "b.json", "c.yaml", "main.rs"}, nil, Rust},
+		{"data alone", []string{"a.yaml", "b.yml", "c.json"}, nil, Yaml},
+		{"candidates", []string{"a.py", "b.py", "c.go"}, []Language{Go, Rust}, Go},
+		{"data among candidates", []string{"a.py", "b.json"}, []Language{Json}, Json},
+		{"hidden and vendored directories", []string{"main.go", ".venv/a.py", ".venv/b.py", "node_modules/a.js", "node_modules/b.js", "vendor/x/a.rb", "vendor/x/b.rb"}, nil, Go},
+		{"gitignored files", []string{"main.go", "gen/a.py", "gen/b.py"}, nil, Go},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files...)
+			if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("gen/\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Detect(dir, tt.candidates...)
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Detect = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectNothing(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Detect(dir); !errors.Is(err, ErrNotDetected) {
+		t.Errorf("Detect of an empty directory error = %v, want ErrNotDetected", err)
+	}
+	writeFiles(t, dir, "a.py", "notes.txt")
+	if _, err := Detect(dir, Go); !errors.Is(err, ErrNotDetected) {
+		t.Errorf("Detect without candidate files error = %v, want ErrNotDetected", err)
+	}
+	if _, err := Detect(filepath.Join(dir, "missing")); err == nil || errors.Is(err, ErrNotDetected) {
+		t.Errorf("Detect of a missing path error = %v, want a stat error", err)
+	}
+}
+
+func TestDetectFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "main.go", "Dockerfile.dev", "notes.txt")
+	scripts := map[string]string{
+		"deploy": "#!/usr/bin/env -S bash -eu\necho hi\n",
+		"tool":   "#!/usr/bin/python3.11\nprint('hi')\n",
+		"data":   "\x00\x01\x02",
+	}
+	for name, content := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		file string
+		want Language
+	}{
+		{"main.go", Go},
+		{"Dockerfile.dev", Dockerfile},
+		{"deploy", Bash},
+		{"tool", Python},
+		{"data", ""},
+		{"notes.txt", ""},
+	}
+	for _, tt := range tests {
+		got, err := Detect(filepath.Join(dir, tt.file))
+		if tt.want == "" {
+			if !errors.Is(err, ErrNotDetected) {
+				t.Errorf("Detect(%s) = %q, %v, want ErrNotDetected", tt.file, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Detect(%s) = %q, %v, want %s", tt.file, got, err, tt.want)
+		}
+	}
+}
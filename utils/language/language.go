@@ -1,5 +1,10 @@
+// Package language names the languages the code tools work with, using the names ast-grep
+// knows them by, detects the language of files and directories, and records which tools
+// and flows support each language.
 package language
 
+import "strings"
+
 type Language string
 
 const (
@@ -8,6 +13,7 @@ const (
 	Cpp        Language = "cpp"
 	CSharp     Language = "csharp"
 	Css        Language = "css"
+	Dockerfile Language = "dockerfile"
 	Elixir     Language = "elixir"
 	Go         Language = "go"
 	Haskell    Language = "haskell"
@@ -30,3 +36,41 @@ const (
 	Tsx        Language = "tsx"
 	Yaml       Language = "yaml"
 )
+
+// aliases are other names the languages go by.
+var aliases = map[string]Language{
+	"sh":        Bash,
+	"shell":     Bash,
+	"zsh":       Bash,
+	"c++":       Cpp,
+	"cxx":       Cpp,
+	"c#":        CSharp,
+	"cs":        CSharp,
+	"docker":    Dockerfile,
+	"golang":    Go,
+	"terraform": Hcl,
+	"js":        JavaScript,
+	"jsx":       JavaScript,
+	"node":      JavaScript,
+	"py":        Python,
+	"rb":        Ruby,
+	"rs":        Rust,
+	"ts":        TypeScript,
+	"yml":       Yaml,
+}
+
+// Parse returns the language named s, accepting common aliases like "golang", "c++" and
+// "ts" as well as file extensions, in any case.
+func Parse(s string) (Language, bool) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return "", false
+	}
+	if _, ok := extensions[Language(name)]; ok {
+		return Language(name), true
+	}
+	if lang, ok := aliases[name]; ok {
+		return lang, true
+	}
+	return FromExtension("." + strings.TrimPrefix(name, "."))
+}
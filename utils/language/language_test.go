@@ -0,0 +1,37 @@
+package language
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Language
+		ok   bool
+	}{
+		{"go", Go, true},
+		{"Go", Go, true},
+		{" golang ", Go, true},
+		{"C++", Cpp, true},
+		{"c#", CSharp, true},
+		{"ts", TypeScript, true},
+		{"tsx", Tsx, true},
+		{"typescript", TypeScript, true},
+		{"JS", JavaScript, true},
+		{"shell", Bash, true},
+		{"terraform", Hcl, true},
+		{".py", Python, true},
+		{"kt", Kotlin, true},
+		{"mjs", JavaScript, true},
+		{"yml", Yaml, true},
+		{"", "", false},
+		{"  ", "", false},
+		{"cobol", "", false},
+		{".", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.s)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Parse(%q) = %q, %v, want %q, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package loglib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snowmerak/useful-genkit/utils/language"
+)
+
+// Library is the logging library to instrument code with.
+type Library struct {
+	Name string `json:"name"`
+	// Detected reports whether the project already depends on the library. When false,
+	// Name is the default library for the language.
+	Detected bool `json:"detected"`
+}
+
+type candidate struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+type ecosystem struct {
+	manifests  []string
+	candidates []candidate
+	fallback   string
+}
+
+func cargoDependency(name string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^\s*(?:\[(?:workspace\.)?dependencies\.)?` + regexp.QuoteMeta(name) + `\s*[=\]]`)
+}
+
+func npmDependency(name string) *regexp.Regexp {
+	return regexp.MustCompile(`"` + regexp.QuoteMeta(name) + `"\s*:`)
+}
+
+func pythonDependency(name string) *regexp.Regexp {
+	return regexp.MustCompile(`(?mi)(?:^|["'\s])` + regexp.QuoteMeta(name) + `(?:$|["'\s<>=~!\[;,])`)
+}
+
+func goModule(path string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^\s*(?:require\s+)?` + regexp.QuoteMeta(path) + `(?:/v\d+)?\s+v`)
+}
+
+var (
+	goEcosystem = ecosystem{
+		manifests: []string{"go.mod"},
+		candidates: []candidate{
+			{"zerolog", goModule("github.com/rs/zerolog")},
+			{"zap", goModule("go.uber.org/zap")},
+			{"logrus", goModule("github.com/sirupsen/logrus")},
+		},
+		fallback: "slog",
+	}
+	pythonEcosystem = ecosystem{
+		manifests: []string{"pyproject.toml", "requirements.txt", "setup.cfg", "Pipfile"},
+		candidates: []candidate{
+			{"structlog", pythonDependency("structlog")},
+			{"loguru", pythonDependency("loguru")},
+		},
+		fallback: "structlog",
+	}
+	nodeEcosystem = ecosystem{
+		manifests: []string{"package.json"},
+		candidates: []candidate{
+			{"logtape", npmDependency("@logtape/logtape")},
+			{"pino", npmDependency("pino")},
+			{"winston", npmDependency("winston")},
+			{"bunyan", npmDependency("bunyan")},
+		},
+		fallback: "logtape",
+	}
+	rustEcosystem = ecosystem{
+		manifests: []string{"Cargo.toml"},
+		candidates: []candidate{
+			{"tracing", cargoDependency("tracing")},
+			{"slog", cargoDependency("slog")},
+			{"log", cargoDependency("log")},
+		},
+		fallback: "tracing",
+	}
+)
+
+var ecosystems = map[language.Language]ecosystem{
+	language.Go:         goEcosystem,
+	language.Python:     pythonEcosystem,
+	language.TypeScript: nodeEcosystem,
+	language.Tsx:        nodeEcosystem,
+	language.JavaScript: nodeEcosystem,
+	language.Rust:       rustEcosystem,
+}
+
+// Detect finds the logging library used by the project of file. It reads the manifests of
+// the language (go.mod, pyproject.toml, package.json, Cargo.toml, ...) from the file's
+// directory up to base, and falls back to the language's default library.
+func Detect(base, file string, lang language.Language) (Library, error) {
+	eco, ok := ecosystems[lang]
+	if !ok {
+		return Library{}, fmt.Errorf("no logging libraries known for %s", lang)
+	}
+
+	root, err := filepath.Abs(base)
+	if err != nil {
+		return Library{}, fmt.Errorf("failed to resolve %s: %w", base, err)
+	}
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return Library{}, fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+
+	for {
+		for _, name := range eco.manifests {
+			manifest, err := os.ReadFile(filepath.Join(dir, name))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return Library{}, fmt.Errorf("failed to read %s in %s: %w", name, dir, err)
+			}
+			for _, c := range eco.candidates {
+				if c.pattern.Match(manifest) {
+					return Library{Name: c.name, Detected: true}, nil
+				}
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir || !strings.HasPrefix(parent, root) {
+			return Library{Name: eco.fallback}, nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,289 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MatchKind tells how a block's search text was located.
+type MatchKind string
+
+const (
+	MatchExact      MatchKind = "exact"
+	MatchWhitespace MatchKind = "whitespace"
+	MatchFuzzy      MatchKind = "fuzzy"
+)
+
+const (
+	DefaultMinConfidence   = 0.85
+	DefaultAmbiguityMargin = 0.05
+)
+
+// Options tunes fuzzy matching.
+type Options struct {
+	// MinConfidence is the lowest similarity, between 0 and 1, a fuzzy match may have.
+	MinConfidence float64
+	// AmbiguityMargin is how much better the best fuzzy match must be than the runner-up.
+	AmbiguityMargin float64
+}
+
+func (o Options) withDefaults() Options {
+	if o.MinConfidence <= 0 {
+		o.MinConfidence = DefaultMinConfidence
+	}
+	if o.AmbiguityMargin <= 0 {
+		o.AmbiguityMargin = DefaultAmbiguityMargin
+	}
+	return o
+}
+
+// Result reports how a single block was applied.
+type Result struct {
+	Block      int       `json:"block"`
+	Applied    bool      `json:"applied"`
+	Match      MatchKind `json:"match,omitempty"`
+	Confidence float64   `json:"confidence"`
+	StartLine  int       `json:"start_line,omitempty"`
+	EndLine    int       `json:"end_line,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ErrNotApplied is returned when at least one block could not be applied.
+var ErrNotApplied = errors.New("not all blocks could be applied")
+
+// Apply applies the blocks in order. Each block is located exactly first, then ignoring
+// whitespace, then fuzzily; ambiguous matches are rejected. When any block fails, Apply
+// returns ErrNotApplied and the content should be discarded.
+func Apply(content string, blocks []Block, opts Options) (string, []Result, error) {
+	opts = opts.withDefaults()
+
+	crlf := strings.Contains(content, "\r\n")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	results := make([]Result, 0, len(blocks))
+	failed := false
+	for i, b := range blocks {
+		res := Result{Block: i + 1}
+		next, err := applyBlock(content, b, opts, &res)
+		if err != nil {
+			res.Error = err.Error()
+			failed = true
+		} else {
+			res.Applied = true
+			content = next
+		}
+		results = append(results, res)
+	}
+
+	if crlf {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	if failed {
+		return content, results, ErrNotApplied
+	}
+	return content, results, nil
+}
+
+func applyBlock(content string, b Block, opts Options, res *Result) (string, error) {
+	search := strings.ReplaceAll(b.Search, "\r\n", "\n")
+	replace := strings.ReplaceAll(b.Replace, "\r\n", "\n")
+	if strings.TrimSpace(search) == "" {
+		return "", fmt.Errorf("empty search text")
+	}
+
+	lines := strings.Split(content, "\n")
+	searchLines := strings.Split(strings.TrimSuffix(search, "\n"), "\n")
+	var replaceLines []string
+	if replace != "" {
+		replaceLines = strings.Split(strings.TrimSuffix(replace, "\n"), "\n")
+	}
+
+	// 1. Exact match on whole lines.
+	var hits []int
+	if n := len(searchLines); n <= len(lines) {
+		for start := 0; start+n <= len(lines); start++ {
+			if slices.Equal(lines[start:start+n], searchLines) {
+				hits = append(hits, start)
+			}
+		}
+	}
+	switch {
+	case len(hits) == 1:
+		res.Match = MatchExact
+		res.Confidence = 1
+		return spliceLines(lines, hits[0], len(searchLines), searchLines, replaceLines, res), nil
+	case len(hits) > 1:
+		return "", fmt.Errorf("search text is ambiguous: found %d exact matches", len(hits))
+	}
+
+	// The later stages ignore blank lines around the search text, so the same number of
+	// blank lines is dropped around the replacement, which would otherwise add them again.
+	leading, trailing := trimBlankEdges(&searchLines)
+	replaceLines = trimBlankLines(replaceLines, leading, trailing)
+	n := len(searchLines)
+	if n == 0 || n > len(lines) {
+		return "", fmt.Errorf("search text not found")
+	}
+
+	// 2. Whitespace-insensitive match.
+	hits = nil
+	for start := 0; start+n <= len(lines); start++ {
+		if equalIgnoringSpace(lines[start:start+n], searchLines) {
+			hits = append(hits, start)
+		}
+	}
+	switch {
+	case len(hits) == 1:
+		res.Match = MatchWhitespace
+		res.Confidence = 1
+		return spliceLines(lines, hits[0], n, searchLines, replaceLines, res), nil
+	case len(hits) > 1:
+		return "", fmt.Errorf("search text is ambiguous: found %d matches ignoring whitespace", len(hits))
+	}
+
+	// 3. Fuzzy match.
+	best, second := -1, -1
+	scores := make([]float64, len(lines)-n+1)
+	for start := range scores {
+		scores[start] = similarity(lines[start:start+n], searchLines)
+		if best < 0 || scores[start] > scores[best] {
+			best = start
+		}
+	}
+	for start, score := range scores {
+		// Windows overlapping the best one are the same location, not a rival.
+		if start > best-n && start < best+n {
+			continue
+		}
+		if second < 0 || score > scores[second] {
+			second = start
+		}
+	}
+
+	res.Confidence = round(scores[best])
+	if scores[best] < opts.MinConfidence {
+		return "", fmt.Errorf("search text not found: best fuzzy match at line %d has confidence %.2f, below %.2f", best+1, scores[best], opts.MinConfidence)
+	}
+	if second >= 0 && scores[best]-scores[second] < opts.AmbiguityMargin {
+		return "", fmt.Errorf("search text is ambiguous: fuzzy matches at lines %d (%.2f) and %d (%.2f)", best+1, scores[best], second+1, scores[second])
+	}
+	res.Match = MatchFuzzy
+	return spliceLines(lines, best, n, searchLines, replaceLines, res), nil
+}
+
+// spliceLines replaces lines[start:start+n] with replaceLines, moving their indentation to that of the match.
+func spliceLines(lines []string, start, n int, searchLines, replaceLines []string, res *Result) string {
+	res.StartLine = start + 1
+	res.EndLine = start + n
+
+	replaceLines = reindent(replaceLines, indentOf(searchLines[0]), indentOf(lines[start]))
+
+	out := make([]string, 0, len(lines)-n+len(replaceLines))
+	out = append(out, lines[:start]...)
+	out = append(out, replaceLines...)
+	out = append(out, lines[start+n:]...)
+	return strings.Join(out, "\n")
+}
+
+func reindent(lines []string, from, to string) []string {
+	if from == to {
+		return lines
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.HasPrefix(line, from) {
+			out[i] = to + strings.TrimPrefix(line, from)
+		} else {
+			out[i] = line
+		}
+	}
+	return out
+}
+
+func indentOf(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// trimBlankEdges removes the blank lines at both ends and returns how many it removed from each.
+func trimBlankEdges(lines *[]string) (leading, trailing int) {
+	l := *lines
+	for len(l) > 0 && strings.TrimSpace(l[0]) == "" {
+		l = l[1:]
+		leading++
+	}
+	for len(l) > 0 && strings.TrimSpace(l[len(l)-1]) == "" {
+		l = l[:len(l)-1]
+		trailing++
+	}
+	*lines = l
+	return leading, trailing
+}
+
+// trimBlankLines removes up to leading blank lines from the start of lines and up to
+// trailing blank lines from the end.
+func trimBlankLines(lines []string, leading, trailing int) []string {
+	for ; leading > 0 && len(lines) > 0 && strings.TrimSpace(lines[0]) == ""; leading-- {
+		lines = lines[1:]
+	}
+	for ; trailing > 0 && len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == ""; trailing-- {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func equalIgnoringSpace(a, b []string) bool {
+	for i := range a {
+		if normalize(a[i]) != normalize(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// similarity is the mean normalized Levenshtein similarity of corresponding lines.
+func similarity(a, b []string) float64 {
+	total := 0.0
+	for i := range a {
+		total += lineSimilarity(normalize(a[i]), normalize(b[i]))
+	}
+	return total / float64(len(a))
+}
+
+func lineSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func round(f float64) float64 {
+	return float64(int(f*100+0.5)) / 100
+}
@@ -0,0 +1,93 @@
+package patch
+
+import "testing"
+
+func TestApplyExactMatchesWholeLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		block   Block
+		want    string
+		match   MatchKind
+	}{
+		{
+			name:    "substring of a line is not an exact match",
+			content: "func f() {\n\tmax := 1\n}\n",
+			block:   Block{Search: "x := 1", Replace: "y := 2"},
+			want:    "",
+		},
+		{
+			name:    "whole line wins over a substring elsewhere",
+			content: "x := 1\nmax := 1\n",
+			block:   Block{Search: "x := 1", Replace: "x := 2"},
+			want:    "x := 2\nmax := 1\n",
+			match:   MatchExact,
+		},
+		{
+			name:    "indented line found ignoring whitespace",
+			content: "func f() {\n\tx := 1\n}\n",
+			block:   Block{Search: "x := 1", Replace: "x := 2"},
+			want:    "func f() {\n\tx := 2\n}\n",
+			match:   MatchWhitespace,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, results, err := Apply(tt.content, []Block{tt.block}, Options{})
+			if tt.want == "" {
+				if err == nil && results[0].Match == MatchExact {
+					t.Fatalf("Apply matched exactly inside a line: %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v (%+v)", err, results)
+			}
+			if got != tt.want {
+				t.Errorf("Apply = %q, want %q", got, tt.want)
+			}
+			if results[0].Match != tt.match {
+				t.Errorf("match = %s, want %s", results[0].Match, tt.match)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsAmbiguousExactMatch(t *testing.T) {
+	_, _, err := Apply("x := 1\nx := 1\n", []Block{{Search: "x := 1", Replace: "x := 2"}}, Options{})
+	if err == nil {
+		t.Fatal("Apply accepted an ambiguous block")
+	}
+}
+
+func TestApplyPaddedBlockAddsNoBlankLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		block Block
+		want  string
+	}{
+		{
+			name:  "padding on both sides",
+			block: Block{Search: "\n  x := 1\n\n", Replace: "\n  x := 2\n\n"},
+			want:  "func f() {\n\tx := 2\n}\n",
+		},
+		{
+			name:  "replacement adds a blank line of its own",
+			block: Block{Search: "\n  x := 1", Replace: "\n\n  x := 2"},
+			want:  "func f() {\n\n\tx := 2\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, results, err := Apply("func f() {\n\tx := 1\n}\n", []Block{tt.block}, Options{})
+			if err != nil {
+				t.Fatalf("Apply: %v (%+v)", err, results)
+			}
+			if got != tt.want {
+				t.Errorf("Apply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package patch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Block replaces the text Search with Replace.
+type Block struct {
+	Search  string `json:"search"`
+	Replace string `json:"replace"`
+}
+
+var (
+	searchMarker  = regexp.MustCompile(`^<{5,9} ?SEARCH\s*$`)
+	dividerMarker = regexp.MustCompile(`^={5,9}\s*$`)
+	replaceMarker = regexp.MustCompile(`^>{5,9} ?REPLACE\s*$`)
+)
+
+// Parse extracts the blocks of text written in the SEARCH/REPLACE format:
+//
+//	<<<<<<< SEARCH
+//	old lines
+//	=======
+//	new lines
+//	>>>>>>> REPLACE
+//
+// Anything outside the blocks, such as file names or code fences, is ignored.
+func Parse(text string) ([]Block, error) {
+	const (
+		outside = iota
+		inSearch
+		inReplace
+	)
+
+	var blocks []Block
+	var search, replace []string
+	state := outside
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		switch state {
+		case outside:
+			if searchMarker.MatchString(line) {
+				state = inSearch
+				search, replace = nil, nil
+			}
+		case inSearch:
+			if dividerMarker.MatchString(line) {
+				state = inReplace
+				continue
+			}
+			if searchMarker.MatchString(line) || replaceMarker.MatchString(line) {
+				return nil, fmt.Errorf("line %d: unexpected marker %q inside SEARCH section", i+1, line)
+			}
+			search = append(search, line)
+		case inReplace:
+			if replaceMarker.MatchString(line) {
+				blocks = append(blocks, Block{Search: strings.Join(search, "\n"), Replace: strings.Join(replace, "\n")})
+				state = outside
+				continue
+			}
+			if searchMarker.MatchString(line) || dividerMarker.MatchString(line) {
+				return nil, fmt.Errorf("line %d: unexpected marker %q inside REPLACE section", i+1, line)
+			}
+			replace = append(replace, line)
+		}
+	}
+	if state != outside {
+		return nil, fmt.Errorf("unterminated SEARCH/REPLACE block")
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no SEARCH/REPLACE blocks found")
+	}
+	return blocks, nil
+}
@@ -0,0 +1,481 @@
+// Package lint checks that code uses the Prism logging API correctly.
+package lint
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const doc = `check Prism span and state logging
+
+The prismlint analyzer reports:
+- spans created with NewSpan that are not completed with Complete or failed with Fail
+  on every return path;
+- Fail calls whose error is not the error the function returns, and Complete calls
+  followed by returning an error;
+- request IDs passed to NewSpan or NewStateLogger that do not come from the context
+  through RequestID(ctx).`
+
+// Analyzer reports incorrect uses of the Prism logging API. Prism functions are recognized
+// by shape rather than import path: NewSpan returning *Span with Complete and Fail methods,
+// and NewStateLogger returning *StateLogger.
+var Analyzer = &analysis.Analyzer{
+	Name:     "prismlint",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodes := []ast.Node{(*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)}
+	insp.Preorder(nodes, func(n ast.Node) {
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			if fn.Body != nil {
+				checkFunc(pass, fn.Type, fn.Body)
+			}
+		case *ast.FuncLit:
+			checkFunc(pass, fn.Type, fn.Body)
+		}
+	})
+
+	for _, file := range pass.Files {
+		checkRequestIDs(pass, file)
+	}
+	return nil, nil
+}
+
+// spanVar is a span created and stored in a local variable.
+type spanVar struct {
+	obj  *types.Var
+	decl ast.Node
+}
+
+// checkFunc checks the spans created directly in a function body. Function literals are
+// checked on their own.
+func checkFunc(pass *analysis.Pass, typ *ast.FuncType, body *ast.BlockStmt) {
+	var spans []spanVar
+	inspectBody(body, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.ExprStmt:
+			if call, ok := n.X.(*ast.CallExpr); ok && createsSpan(pass, call) {
+				pass.Reportf(call.Pos(), "span is created but discarded, so it is never completed or failed")
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				return
+			}
+			for i, rhs := range n.Rhs {
+				if call, ok := rhs.(*ast.CallExpr); ok && createsSpan(pass, call) {
+					if v := localVar(pass, n.Lhs[i]); v != nil {
+						spans = append(spans, spanVar{obj: v, decl: n})
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) != len(n.Values) {
+				return
+			}
+			for i, rhs := range n.Values {
+				if call, ok := rhs.(*ast.CallExpr); ok && createsSpan(pass, call) {
+					if v, ok := pass.TypesInfo.Defs[n.Names[i]].(*types.Var); ok {
+						spans = append(spans, spanVar{obj: v, decl: n})
+					}
+				}
+			}
+		}
+	})
+	if len(spans) == 0 {
+		return
+	}
+
+	g := cfg.New(body, func(call *ast.CallExpr) bool { return mayReturn(pass, call) })
+	for _, s := range spans {
+		if escapes(pass, body, s.obj) {
+			continue
+		}
+		checkSpanPaths(pass, g, body, s)
+		checkSpanResults(pass, g, typ, s)
+	}
+}
+
+// checkSpanPaths reports every return reachable from the span's creation without passing
+// Complete or Fail, either called directly or deferred. Falling off the end of the body
+// counts as a return and is reported at the closing brace.
+func checkSpanPaths(pass *analysis.Pass, g *cfg.CFG, body *ast.BlockStmt, s spanVar) {
+	start, index := findNode(g, s.decl)
+	if start == nil {
+		return
+	}
+
+	visited := make(map[*cfg.Block]bool)
+	var walk func(b *cfg.Block, from int)
+	walk = func(b *cfg.Block, from int) {
+		for _, n := range b.Nodes[from:] {
+			if closesSpan(pass, n, s.obj) {
+				return
+			}
+		}
+		if ret := b.Return(); ret != nil {
+			if ret.Return == body.Rbrace {
+				// cfg makes falling off the end of the body an explicit, synthetic return.
+				pass.Reportf(body.Rbrace, "%s is neither completed nor failed when the function returns", s.obj.Name())
+			} else {
+				pass.Reportf(ret.Pos(), "%s is neither completed nor failed before this return", s.obj.Name())
+			}
+			return
+		}
+		for _, succ := range b.Succs {
+			if !visited[succ] {
+				visited[succ] = true
+				walk(succ, 0)
+			}
+		}
+	}
+	walk(start, index+1)
+}
+
+// checkSpanResults checks what the function returns right after the span is completed or failed.
+func checkSpanResults(pass *analysis.Pass, g *cfg.CFG, typ *ast.FuncType, s spanVar) {
+	if !returnsError(pass, typ) {
+		return
+	}
+
+	for _, b := range g.Blocks {
+		ret := b.Return()
+		if ret == nil || len(ret.Results) == 0 {
+			continue
+		}
+		// Only the last span call before the return is relevant.
+		var last *ast.CallExpr
+		for _, n := range b.Nodes {
+			if stmt, ok := n.(*ast.ExprStmt); ok {
+				if call, ok := stmt.X.(*ast.CallExpr); ok && spanMethod(pass, call, s.obj) != "" {
+					last = call
+				}
+			}
+		}
+		if last == nil {
+			continue
+		}
+
+		returned := ret.Results[len(ret.Results)-1]
+		switch spanMethod(pass, last, s.obj) {
+		case "Fail":
+			if len(last.Args) != 1 {
+				continue
+			}
+			arg := last.Args[0]
+			switch {
+			case isNil(pass, arg):
+				pass.Reportf(last.Pos(), "%s fails with a nil error", s.obj.Name())
+			case isNil(pass, returned):
+				pass.Reportf(ret.Pos(), "%s fails but the function returns a nil error", s.obj.Name())
+			case !references(pass, returned, arg):
+				pass.Reportf(last.Pos(), "%s fails with %s but the function returns %s", s.obj.Name(), types.ExprString(arg), types.ExprString(returned))
+			}
+		case "Complete":
+			if !isNil(pass, returned) {
+				pass.Reportf(last.Pos(), "%s completes but the function returns the error %s; use Fail", s.obj.Name(), types.ExprString(returned))
+			}
+		}
+	}
+}
+
+// checkRequestIDs reports request IDs of NewSpan and NewStateLogger that do not come from
+// the context.
+func checkRequestIDs(pass *analysis.Pass, file *ast.File) {
+	// Values assigned to local variables, to follow "id := RequestID(ctx)".
+	assigned := make(map[types.Object][]ast.Expr)
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) {
+				for i, lhs := range n.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						if obj := pass.TypesInfo.ObjectOf(id); obj != nil {
+							assigned[obj] = append(assigned[obj], n.Rhs[i])
+						}
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == len(n.Values) {
+				for i, name := range n.Names {
+					if obj := pass.TypesInfo.Defs[name]; obj != nil {
+						assigned[obj] = append(assigned[obj], n.Values[i])
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var index int
+		switch {
+		case prismConstructor(pass, call, "NewSpan", "Span"):
+			index = 3
+		case prismConstructor(pass, call, "NewStateLogger", "StateLogger"):
+			index = 2
+		default:
+			return true
+		}
+		if len(call.Args) > index && !fromContext(pass, call.Args[index], assigned, 0) {
+			pass.Reportf(call.Args[index].Pos(), "request ID %s should come from the context: use RequestID(ctx)", types.ExprString(call.Args[index]))
+		}
+		return true
+	})
+}
+
+// fromContext reports whether expr is RequestID(ctx), a RequestID field, or a variable
+// only ever assigned such values.
+func fromContext(pass *analysis.Pass, expr ast.Expr, assigned map[types.Object][]ast.Expr, depth int) bool {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.CallExpr:
+		fn, ok := typeutil.Callee(pass.TypesInfo, e).(*types.Func)
+		return ok && fn.Name() == "RequestID" && len(e.Args) == 1 && isContext(pass.TypesInfo.TypeOf(e.Args[0]))
+	case *ast.SelectorExpr:
+		if _, ok := pass.TypesInfo.Selections[e]; ok {
+			return e.Sel.Name == "RequestID"
+		}
+		return false
+	case *ast.Ident:
+		values := assigned[pass.TypesInfo.ObjectOf(e)]
+		if len(values) == 0 || depth > 4 {
+			return false
+		}
+		for _, v := range values {
+			if !fromContext(pass, v, assigned, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// createsSpan reports whether call is NewSpan(...) or a chain on it such as NewSpan(...).Start().
+func createsSpan(pass *analysis.Pass, call *ast.CallExpr) bool {
+	for {
+		if prismConstructor(pass, call, "NewSpan", "Span") {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isSpan(pass.TypesInfo.TypeOf(call)) {
+			return false
+		}
+		inner, ok := ast.Unparen(sel.X).(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		call = inner
+	}
+}
+
+// prismConstructor reports whether call calls a function named name returning *typeName.
+func prismConstructor(pass *analysis.Pass, call *ast.CallExpr, name, typeName string) bool {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Name() != name {
+		return false
+	}
+	results := fn.Type().(*types.Signature).Results()
+	if results.Len() != 1 {
+		return false
+	}
+	named := pointee(results.At(0).Type())
+	return named != nil && named.Obj().Name() == typeName
+}
+
+// isSpan reports whether t is *Span with Complete and Fail methods.
+func isSpan(t types.Type) bool {
+	named := pointee(t)
+	if named == nil || named.Obj().Name() != "Span" {
+		return false
+	}
+	ms := types.NewMethodSet(t)
+	return ms.Lookup(named.Obj().Pkg(), "Complete") != nil && ms.Lookup(named.Obj().Pkg(), "Fail") != nil
+}
+
+func pointee(t types.Type) *types.Named {
+	ptr, ok := t.(*types.Pointer)
+	if !ok {
+		return nil
+	}
+	named, _ := ptr.Elem().(*types.Named)
+	return named
+}
+
+// spanMethod returns "Complete" or "Fail" when call calls that method on the span.
+func spanMethod(pass *analysis.Pass, call *ast.CallExpr, span *types.Var) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || (sel.Sel.Name != "Complete" && sel.Sel.Name != "Fail") {
+		return ""
+	}
+	id, ok := ast.Unparen(sel.X).(*ast.Ident)
+	if !ok || pass.TypesInfo.Uses[id] != span {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+// closesSpan reports whether n completes or fails the span, directly or in a deferred call.
+func closesSpan(pass *analysis.Pass, n ast.Node, span *types.Var) bool {
+	_, deferred := n.(*ast.DeferStmt)
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// A closure only closes the span when it is deferred.
+			return deferred
+		case *ast.CallExpr:
+			if spanMethod(pass, n, span) != "" {
+				found = true
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// escapes reports whether the span is used other than by calling its methods, for example
+// returned or passed to another function, which then owns completing it.
+func escapes(pass *analysis.Pass, body *ast.BlockStmt, span *types.Var) bool {
+	receivers := make(map[*ast.Ident]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := ast.Unparen(sel.X).(*ast.Ident); ok {
+				receivers[id] = true
+			}
+		}
+		return true
+	})
+
+	escaped := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == span && !receivers[id] {
+			escaped = true
+		}
+		return !escaped
+	})
+	return escaped
+}
+
+// inspectBody visits the nodes of body without descending into function literals.
+func inspectBody(body *ast.BlockStmt, visit func(ast.Node)) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		if n != nil {
+			visit(n)
+		}
+		return true
+	})
+}
+
+func findNode(g *cfg.CFG, target ast.Node) (*cfg.Block, int) {
+	for _, b := range g.Blocks {
+		for i, n := range b.Nodes {
+			if n == target {
+				return b, i
+			}
+			// A var declaration appears as its ValueSpec.
+			if decl, ok := n.(*ast.DeclStmt); ok {
+				if gen, ok := decl.Decl.(*ast.GenDecl); ok {
+					for _, spec := range gen.Specs {
+						if spec == target {
+							return b, i
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, 0
+}
+
+func localVar(pass *analysis.Pass, expr ast.Expr) *types.Var {
+	id, ok := expr.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	v, _ := pass.TypesInfo.ObjectOf(id).(*types.Var)
+	if v == nil || v.Parent() == v.Pkg().Scope() {
+		return nil
+	}
+	return v
+}
+
+func returnsError(pass *analysis.Pass, typ *ast.FuncType) bool {
+	if typ.Results == nil || len(typ.Results.List) == 0 {
+		return false
+	}
+	last := pass.TypesInfo.TypeOf(typ.Results.List[len(typ.Results.List)-1].Type)
+	return last != nil && types.Identical(last, types.Universe.Lookup("error").Type())
+}
+
+func isNil(pass *analysis.Pass, expr ast.Expr) bool {
+	tv, ok := pass.TypesInfo.Types[expr]
+	return ok && tv.IsNil()
+}
+
+// references reports whether expr mentions the variable arg refers to, so that returning
+// fmt.Errorf("...: %w", err) counts as returning err.
+func references(pass *analysis.Pass, expr, arg ast.Expr) bool {
+	id, ok := ast.Unparen(arg).(*ast.Ident)
+	if !ok {
+		return types.ExprString(expr) == types.ExprString(arg)
+	}
+	obj := pass.TypesInfo.ObjectOf(id)
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if x, ok := n.(*ast.Ident); ok && pass.TypesInfo.ObjectOf(x) == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func isContext(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
+
+// mayReturn reports whether a call statement may return, so paths ending in panic or
+// os.Exit are not reported.
+func mayReturn(pass *analysis.Pass, call *ast.CallExpr) bool {
+	if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok && id.Name == "panic" {
+		_, builtin := pass.TypesInfo.Uses[id].(*types.Builtin)
+		return !builtin
+	}
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return true
+	}
+	switch fn.Pkg().Path() + "." + fn.Name() {
+	case "os.Exit", "log.Fatal", "log.Fatalf", "log.Fatalln", "log.Panic", "log.Panicf", "log.Panicln":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package lint
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// Diagnostic is a finding of Analyzer, or a load error of a checked package.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// Run checks the packages of the given Go files and returns the findings in those files.
+// Packages that fail to load or type-check are reported as diagnostics, since the analysis
+// needs type information.
+func Run(files ...string) ([]Diagnostic, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	wanted := make(map[string]bool)
+	patterns := make([]string, 0, len(files))
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", file, err)
+		}
+		wanted[abs] = true
+		patterns = append(patterns, "file="+abs)
+	}
+
+	cfg := &packages.Config{
+		Mode: packages.LoadAllSyntax,
+		Dir:  filepath.Dir(files[0]),
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	var diags []Diagnostic
+	var ok []*packages.Package
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) == 0 {
+			ok = append(ok, pkg)
+			continue
+		}
+		for _, e := range pkg.Errors {
+			diags = append(diags, loadDiagnostic(e))
+		}
+	}
+	if len(ok) == 0 {
+		return diags, nil
+	}
+
+	graph, err := checker.Analyze([]*analysis.Analyzer{Analyzer}, ok, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze packages: %w", err)
+	}
+	for _, act := range graph.Roots {
+		if act.Err != nil {
+			return nil, fmt.Errorf("failed to analyze %s: %w", act.Package.PkgPath, act.Err)
+		}
+		for _, d := range act.Diagnostics {
+			pos := act.Package.Fset.Position(d.Pos)
+			if !wanted[pos.Filename] {
+				continue
+			}
+			diags = append(diags, Diagnostic{File: pos.Filename, Line: pos.Line, Column: pos.Column, Message: d.Message})
+		}
+	}
+	return diags, nil
+}
+
+// loadDiagnostic converts a load error, whose position is "file:line:col", "file:line" or empty.
+func loadDiagnostic(e packages.Error) Diagnostic {
+	d := Diagnostic{File: e.Pos, Message: e.Msg}
+	parts := strings.Split(e.Pos, ":")
+	nums := 0
+	for nums < 2 && len(parts)-nums > 1 {
+		if _, err := strconv.Atoi(parts[len(parts)-1-nums]); err != nil {
+			break
+		}
+		nums++
+	}
+	if nums == 0 {
+		return d
+	}
+	d.File = strings.Join(parts[:len(parts)-nums], ":")
+	d.Line, _ = strconv.Atoi(parts[len(parts)-nums])
+	if nums == 2 {
+		d.Column, _ = strconv.Atoi(parts[len(parts)-1])
+	}
+	return d
+}
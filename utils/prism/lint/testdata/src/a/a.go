@@ -0,0 +1,145 @@
+package a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type Span struct{}
+
+func (s *Span) Complete()      {}
+func (s *Span) Fail(err error) {}
+
+func NewSpan(ctx context.Context, name, method, requestID string) *Span { return &Span{} }
+
+func RequestID(ctx context.Context) string { return "" }
+
+type StateLogger struct{}
+
+func NewStateLogger(ctx context.Context, name, requestID string) *StateLogger { return &StateLogger{} }
+
+type request struct {
+	RequestID string
+}
+
+var errFailed = errors.New("failed")
+
+func work() error { return nil }
+
+func completed(ctx context.Context) {
+	span := NewSpan(ctx, "a", "completed", RequestID(ctx))
+	span.Complete()
+}
+
+func deferred(ctx context.Context) {
+	span := NewSpan(ctx, "a", "deferred", RequestID(ctx))
+	defer span.Complete()
+}
+
+func fallsOff(ctx context.Context) {
+	span := NewSpan(ctx, "a", "fallsOff", RequestID(ctx))
+	if ctx == nil {
+		span.Fail(nil)
+	}
+} // want `span is neither completed nor failed when the function returns`
+
+func fallsOffBranch(ctx context.Context, ok bool) {
+	span := NewSpan(ctx, "a", "fallsOffBranch", RequestID(ctx))
+	if ok {
+		span.Complete()
+		return
+	}
+} // want `span is neither completed nor failed when the function returns`
+
+func panics(ctx context.Context) {
+	span := NewSpan(ctx, "a", "panics", RequestID(ctx))
+	if ctx != nil {
+		span.Complete()
+		return
+	}
+	panic("unreachable")
+}
+
+func returns(ctx context.Context, ok bool) error {
+	span := NewSpan(ctx, "a", "returns", RequestID(ctx))
+	if ok {
+		return nil // want `span is neither completed nor failed before this return`
+	}
+	span.Complete()
+	return nil
+}
+
+func fallsOffLit(ctx context.Context) {
+	func() {
+		span := NewSpan(ctx, "a", "fallsOffLit", RequestID(ctx))
+		if ctx == nil {
+			span.Complete()
+		}
+	}() // want `span is neither completed nor failed when the function returns`
+}
+
+func discarded(ctx context.Context) {
+	NewSpan(ctx, "a", "discarded", RequestID(ctx)) // want `span is created but discarded, so it is never completed or failed`
+}
+
+func failsWithNil(ctx context.Context) error {
+	span := NewSpan(ctx, "a", "failsWithNil", RequestID(ctx))
+	if err := work(); err != nil {
+		span.Fail(nil) // want `span fails with a nil error`
+		return err
+	}
+	span.Complete()
+	return nil
+}
+
+func failsButReturnsNil(ctx context.Context) error {
+	span := NewSpan(ctx, "a", "failsButReturnsNil", RequestID(ctx))
+	if err := work(); err != nil {
+		span.Fail(err)
+		return nil // want `span fails but the function returns a nil error`
+	}
+	span.Complete()
+	return nil
+}
+
+func failsWithOtherError(ctx context.Context) error {
+	span := NewSpan(ctx, "a", "failsWithOtherError", RequestID(ctx))
+	if err := work(); err != nil {
+		span.Fail(err) // want `span fails with err but the function returns errFailed`
+		return errFailed
+	}
+	span.Complete()
+	return nil
+}
+
+func failsWithWrappedError(ctx context.Context) (int, error) {
+	span := NewSpan(ctx, "a", "failsWithWrappedError", RequestID(ctx))
+	if err := work(); err != nil {
+		span.Fail(err)
+		return 0, fmt.Errorf("failed to work: %w", err)
+	}
+	span.Complete()
+	return 1, nil
+}
+
+func completesThenFails(ctx context.Context) error {
+	span := NewSpan(ctx, "a", "completesThenFails", RequestID(ctx))
+	err := work()
+	span.Complete() // want `span completes but the function returns the error err; use Fail`
+	return err
+}
+
+func requestIDs(ctx context.Context, req request) {
+	id := RequestID(ctx)
+	fixed := "fixed"
+
+	NewStateLogger(ctx, "fromContext", RequestID(ctx))
+	NewStateLogger(ctx, "fromVariable", id)
+	NewStateLogger(ctx, "fromField", req.RequestID)
+	NewStateLogger(ctx, "literal", "id")   // want `request ID "id" should come from the context: use RequestID\(ctx\)`
+	NewStateLogger(ctx, "variable", fixed) // want `request ID fixed should come from the context: use RequestID\(ctx\)`
+
+	span := NewSpan(ctx, "a", "requestIDs", "id") // want `request ID "id" should come from the context: use RequestID\(ctx\)`
+	defer span.Complete()
+}
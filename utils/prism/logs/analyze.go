@@ -0,0 +1,449 @@
+package logs
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Options tunes Analyze.
+type Options struct {
+	// MaxTimelines caps the request timelines in the report. Defaults to 20.
+	MaxTimelines int
+	// MaxTimelineEntries caps the entries of each timeline. Defaults to 50.
+	MaxTimelineEntries int
+	// MaxFailures caps the failure groups in the report. Defaults to 50.
+	MaxFailures int
+	// RareTransitionRatio flags transitions of an entity that make up at most this share of
+	// its transitions. Defaults to 0.01.
+	RareTransitionRatio float64
+	// MinTransitions is the number of transitions an entity needs before rare transitions
+	// are flagged. Defaults to 20.
+	MinTransitions int
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxTimelines <= 0 {
+		o.MaxTimelines = 20
+	}
+	if o.MaxTimelineEntries <= 0 {
+		o.MaxTimelineEntries = 50
+	}
+	if o.MaxFailures <= 0 {
+		o.MaxFailures = 50
+	}
+	if o.RareTransitionRatio <= 0 {
+		o.RareTransitionRatio = 0.01
+	}
+	if o.MinTransitions <= 0 {
+		o.MinTransitions = 20
+	}
+	return o
+}
+
+// Report holds the facts computed from Prism logs.
+type Report struct {
+	Stats Stats     `json:"stats"`
+	Start time.Time `json:"start,omitzero"`
+	End   time.Time `json:"end,omitzero"`
+	// Requests counts the distinct request IDs; FailedRequests those with a failed span.
+	Requests       int `json:"requests"`
+	FailedRequests int `json:"failed_requests"`
+	// MissingRequestID counts entries without a request ID, which cannot be correlated.
+	MissingRequestID int `json:"missing_request_id,omitempty"`
+	// Latencies is sorted by descending p95.
+	Latencies []Latency `json:"latencies"`
+	// Failures groups failed spans by span and error, most frequent first.
+	Failures  []Failure  `json:"failures,omitempty"`
+	Anomalies []Anomaly  `json:"anomalies,omitempty"`
+	Timelines []Timeline `json:"timelines,omitempty"`
+}
+
+// Latency summarizes the durations of one span, identified by service, scope and method.
+type Latency struct {
+	Service string  `json:"service"`
+	Scope   string  `json:"scope"`
+	Method  string  `json:"method"`
+	Count   int     `json:"count"`
+	Failed  int     `json:"failed"`
+	MeanMs  float64 `json:"mean_ms"`
+	P50Ms   float64 `json:"p50_ms"`
+	P90Ms   float64 `json:"p90_ms"`
+	P95Ms   float64 `json:"p95_ms"`
+	P99Ms   float64 `json:"p99_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// Failure is a group of failed spans with the same error.
+type Failure struct {
+	Service string    `json:"service"`
+	Scope   string    `json:"scope"`
+	Method  string    `json:"method"`
+	Error   string    `json:"error"`
+	Count   int       `json:"count"`
+	First   time.Time `json:"first,omitzero"`
+	Last    time.Time `json:"last,omitzero"`
+	// RequestIDs lists up to five of the failed requests.
+	RequestIDs []string `json:"request_ids"`
+}
+
+// AnomalyKind classifies an odd state transition.
+type AnomalyKind string
+
+const (
+	// AnomalyNoopTransition is a transition to the state the entity is already in.
+	AnomalyNoopTransition AnomalyKind = "noop_transition"
+	// AnomalyDiscontinuousTransition is a transition from a state other than the one the
+	// entity last moved to within the same request.
+	AnomalyDiscontinuousTransition AnomalyKind = "discontinuous_transition"
+	// AnomalyRareTransition is a transition that is rare compared to the other transitions
+	// of the entity.
+	AnomalyRareTransition AnomalyKind = "rare_transition"
+)
+
+// Anomaly is an odd state transition.
+type Anomaly struct {
+	Kind      AnomalyKind `json:"kind"`
+	Service   string      `json:"service,omitempty"`
+	Entity    string      `json:"entity"`
+	From      string      `json:"from"`
+	To        string      `json:"to"`
+	RequestID string      `json:"request_id,omitempty"`
+	// Count is how often a rare transition was seen.
+	Count  int    `json:"count,omitempty"`
+	Detail string `json:"detail"`
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+}
+
+// Timeline is the ordered entries of a single request.
+type Timeline struct {
+	RequestID  string    `json:"request_id"`
+	Start      time.Time `json:"start,omitzero"`
+	End        time.Time `json:"end,omitzero"`
+	DurationMs float64   `json:"duration_ms"`
+	Services   []string  `json:"services"`
+	Failed     bool      `json:"failed"`
+	Anomalies  int       `json:"anomalies,omitempty"`
+	Entries    []Entry   `json:"entries"`
+	// Truncated counts the entries left out of Entries.
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// Analyze builds a report from entries.
+func Analyze(entries []Entry, stats Stats, opts Options) Report {
+	opts = opts.withDefaults()
+	report := Report{Stats: stats}
+
+	requests := make(map[string][]Entry)
+	var order []string
+	for _, e := range entries {
+		if !e.Time.IsZero() {
+			if report.Start.IsZero() || e.Time.Before(report.Start) {
+				report.Start = e.Time
+			}
+			if e.Time.After(report.End) {
+				report.End = e.Time
+			}
+		}
+		if e.RequestID == "" {
+			report.MissingRequestID++
+			continue
+		}
+		if _, ok := requests[e.RequestID]; !ok {
+			order = append(order, e.RequestID)
+		}
+		requests[e.RequestID] = append(requests[e.RequestID], e)
+	}
+	report.Requests = len(requests)
+
+	report.Latencies = latencies(entries)
+	report.Failures = failures(entries, opts.MaxFailures)
+
+	anomaliesByRequest := make(map[string]int)
+	for _, id := range order {
+		for _, a := range requestAnomalies(requests[id]) {
+			report.Anomalies = append(report.Anomalies, a)
+			anomaliesByRequest[id]++
+		}
+	}
+	report.Anomalies = append(report.Anomalies, rareTransitions(entries, opts)...)
+
+	var timelines []Timeline
+	for _, id := range order {
+		t := timeline(id, requests[id])
+		t.Anomalies = anomaliesByRequest[id]
+		if t.Failed {
+			report.FailedRequests++
+		}
+		timelines = append(timelines, t)
+	}
+	report.Timelines = selectTimelines(timelines, opts.MaxTimelines)
+	for i := range report.Timelines {
+		report.Timelines[i].truncate(opts.MaxTimelineEntries)
+	}
+	return report
+}
+
+type spanKey struct {
+	service, scope, method string
+}
+
+func latencies(entries []Entry) []Latency {
+	durations := make(map[spanKey][]float64)
+	failed := make(map[spanKey]int)
+	var keys []spanKey
+	for _, e := range entries {
+		if e.Kind != KindSpan {
+			continue
+		}
+		key := spanKey{e.Service, e.Scope, e.Method}
+		if _, ok := durations[key]; !ok {
+			keys = append(keys, key)
+		}
+		durations[key] = append(durations[key], e.DurationMs)
+		if e.Failed() {
+			failed[key]++
+		}
+	}
+
+	result := make([]Latency, 0, len(keys))
+	for _, key := range keys {
+		d := durations[key]
+		slices.Sort(d)
+		sum := 0.0
+		for _, v := range d {
+			sum += v
+		}
+		result = append(result, Latency{
+			Service: key.service,
+			Scope:   key.scope,
+			Method:  key.method,
+			Count:   len(d),
+			Failed:  failed[key],
+			MeanMs:  round(sum / float64(len(d))),
+			P50Ms:   percentile(d, 50),
+			P90Ms:   percentile(d, 90),
+			P95Ms:   percentile(d, 95),
+			P99Ms:   percentile(d, 99),
+			MaxMs:   d[len(d)-1],
+		})
+	}
+	slices.SortStableFunc(result, func(a, b Latency) int { return cmp.Compare(b.P95Ms, a.P95Ms) })
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func failures(entries []Entry, limit int) []Failure {
+	type failureKey struct {
+		spanKey
+		err string
+	}
+	groups := make(map[failureKey]*Failure)
+	var keys []failureKey
+	for _, e := range entries {
+		if !e.Failed() {
+			continue
+		}
+		key := failureKey{spanKey{e.Service, e.Scope, e.Method}, e.Error}
+		f, ok := groups[key]
+		if !ok {
+			f = &Failure{Service: e.Service, Scope: e.Scope, Method: e.Method, Error: e.Error, First: e.Time}
+			groups[key] = f
+			keys = append(keys, key)
+		}
+		f.Count++
+		if !e.Time.IsZero() && (f.First.IsZero() || e.Time.Before(f.First)) {
+			f.First = e.Time
+		}
+		if e.Time.After(f.Last) {
+			f.Last = e.Time
+		}
+		if len(f.RequestIDs) < 5 && e.RequestID != "" && !slices.Contains(f.RequestIDs, e.RequestID) {
+			f.RequestIDs = append(f.RequestIDs, e.RequestID)
+		}
+	}
+
+	result := make([]Failure, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *groups[key])
+	}
+	slices.SortStableFunc(result, func(a, b Failure) int { return cmp.Compare(b.Count, a.Count) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// requestAnomalies finds no-op and discontinuous transitions within one request.
+func requestAnomalies(entries []Entry) []Anomaly {
+	var anomalies []Anomaly
+	last := make(map[string]string)
+	for _, e := range sortedEntries(entries) {
+		if e.Kind != KindTransition {
+			continue
+		}
+		anomaly := Anomaly{
+			Service:   e.Service,
+			Entity:    e.Entity,
+			From:      e.From,
+			To:        e.To,
+			RequestID: e.RequestID,
+			Source:    e.Source,
+			Line:      e.Line,
+		}
+		prev, seen := last[e.Entity]
+		switch {
+		case e.From == e.To:
+			anomaly.Kind = AnomalyNoopTransition
+			anomaly.Detail = fmt.Sprintf("%s moved from %q to the same state", e.Entity, e.From)
+			anomalies = append(anomalies, anomaly)
+		case seen && prev != e.From:
+			anomaly.Kind = AnomalyDiscontinuousTransition
+			anomaly.Detail = fmt.Sprintf("%s moved from %q, but its last transition in this request was to %q", e.Entity, e.From, prev)
+			anomalies = append(anomalies, anomaly)
+		}
+		last[e.Entity] = e.To
+	}
+	return anomalies
+}
+
+// rareTransitions finds transitions that are rare for their entity across all requests.
+func rareTransitions(entries []Entry, opts Options) []Anomaly {
+	type edge struct {
+		entity, from, to string
+	}
+	totals := make(map[string]int)
+	counts := make(map[edge]int)
+	firsts := make(map[edge]Entry)
+	var edges []edge
+	for _, e := range entries {
+		if e.Kind != KindTransition {
+			continue
+		}
+		key := edge{e.Entity, e.From, e.To}
+		totals[e.Entity]++
+		if counts[key] == 0 {
+			firsts[key] = e
+			edges = append(edges, key)
+		}
+		counts[key]++
+	}
+
+	var anomalies []Anomaly
+	for _, key := range edges {
+		total := totals[key.entity]
+		if total < opts.MinTransitions || float64(counts[key]) > opts.RareTransitionRatio*float64(total) {
+			continue
+		}
+		e := firsts[key]
+		anomalies = append(anomalies, Anomaly{
+			Kind:      AnomalyRareTransition,
+			Service:   e.Service,
+			Entity:    key.entity,
+			From:      key.from,
+			To:        key.to,
+			RequestID: e.RequestID,
+			Count:     counts[key],
+			Detail:    fmt.Sprintf("%s moved from %q to %q in %d of %d transitions", key.entity, key.from, key.to, counts[key], total),
+			Source:    e.Source,
+			Line:      e.Line,
+		})
+	}
+	return anomalies
+}
+
+// sortedEntries orders entries by time. Entries without a time come last, ordered by
+// source and line, and entries with the same time keep their log order.
+func sortedEntries(entries []Entry) []Entry {
+	sorted := slices.Clone(entries)
+	slices.SortStableFunc(sorted, func(a, b Entry) int {
+		switch {
+		case a.Time.IsZero() && b.Time.IsZero():
+			return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Line, b.Line))
+		case a.Time.IsZero():
+			return 1
+		case b.Time.IsZero():
+			return -1
+		}
+		return a.Time.Compare(b.Time)
+	})
+	return sorted
+}
+
+func timeline(id string, entries []Entry) Timeline {
+	t := Timeline{RequestID: id, Entries: sortedEntries(entries)}
+	for _, e := range t.Entries {
+		if !slices.Contains(t.Services, e.Service) && e.Service != "" {
+			t.Services = append(t.Services, e.Service)
+		}
+		if e.Failed() {
+			t.Failed = true
+		}
+		if e.Time.IsZero() {
+			continue
+		}
+		// Spans are logged when they end, so they started duration_ms earlier.
+		start := e.Time
+		if e.Kind == KindSpan {
+			start = e.Time.Add(-time.Duration(e.DurationMs * float64(time.Millisecond)))
+		}
+		if t.Start.IsZero() || start.Before(t.Start) {
+			t.Start = start
+		}
+		if e.Time.After(t.End) {
+			t.End = e.Time
+		}
+	}
+	if !t.Start.IsZero() {
+		t.DurationMs = float64(t.End.Sub(t.Start).Microseconds()) / 1000
+	} else {
+		// Without times, the longest span is the best estimate.
+		for _, e := range t.Entries {
+			t.DurationMs = max(t.DurationMs, e.DurationMs)
+		}
+	}
+	return t
+}
+
+// truncate keeps the first and last entries of a timeline longer than limit.
+func (t *Timeline) truncate(limit int) {
+	if len(t.Entries) <= limit {
+		return
+	}
+	head := limit / 2
+	tail := limit - head
+	t.Truncated = len(t.Entries) - limit
+	t.Entries = append(t.Entries[:head:head], t.Entries[len(t.Entries)-tail:]...)
+}
+
+// selectTimelines keeps failed and anomalous requests first, then the slowest ones.
+func selectTimelines(timelines []Timeline, limit int) []Timeline {
+	slices.SortStableFunc(timelines, func(a, b Timeline) int {
+		if a.Failed != b.Failed {
+			if a.Failed {
+				return -1
+			}
+			return 1
+		}
+		if c := cmp.Compare(b.Anomalies, a.Anomalies); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.DurationMs, a.DurationMs)
+	})
+	if len(timelines) > limit {
+		timelines = timelines[:limit]
+	}
+	return timelines
+}
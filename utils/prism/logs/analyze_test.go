@@ -0,0 +1,165 @@
+package logs
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{10, 1},
+		{11, 2},
+		{50, 5},
+		{90, 9},
+		{95, 10},
+		{100, 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(values, tt.p); got != tt.want {
+			t.Errorf("percentile(p%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile([]float64{42}, 99); got != 42 {
+		t.Errorf("percentile of a single value = %v, want 42", got)
+	}
+}
+
+func TestLatencies(t *testing.T) {
+	var entries []Entry
+	for i := 1; i <= 100; i++ {
+		entries = append(entries, Entry{Kind: KindSpan, Service: "api", Method: "Fast", Status: "ok", DurationMs: float64(i)})
+	}
+	entries = append(entries,
+		Entry{Kind: KindSpan, Service: "api", Method: "Slow", Status: "ok", DurationMs: 500},
+		Entry{Kind: KindSpan, Service: "api", Method: "Slow", Status: "failed", DurationMs: 1000},
+		Entry{Kind: KindTransition, Service: "api", Entity: "order"},
+	)
+
+	got := latencies(entries)
+	if len(got) != 2 {
+		t.Fatalf("latencies returned %d spans, want 2", len(got))
+	}
+	slow, fast := got[0], got[1]
+	if slow.Method != "Slow" || slow.Count != 2 || slow.Failed != 1 || slow.P50Ms != 500 || slow.P95Ms != 1000 || slow.MeanMs != 750 {
+		t.Errorf("slow span = %+v", slow)
+	}
+	if fast.Method != "Fast" || fast.Count != 100 || fast.P50Ms != 50 || fast.P90Ms != 90 || fast.P99Ms != 99 || fast.MaxMs != 100 || fast.MeanMs != 50.5 {
+		t.Errorf("fast span = %+v", fast)
+	}
+}
+
+func transition(entity, from, to string, line int) Entry {
+	return Entry{Kind: KindTransition, RequestID: "r1", Entity: entity, From: from, To: to, Source: "app.log", Line: line}
+}
+
+func TestRequestAnomalies(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(e Entry, sec int) Entry {
+		e.Time = base.Add(time.Duration(sec) * time.Second)
+		return e
+	}
+	tests := []struct {
+		name    string
+		entries []Entry
+		want    []AnomalyKind
+	}{
+		{
+			name:    "continuous",
+			entries: []Entry{transition("order", "new", "paid", 1), transition("order", "paid", "shipped", 2)},
+		},
+		{
+			name:    "noop",
+			entries: []Entry{transition("order", "paid", "paid", 1)},
+			want:    []AnomalyKind{AnomalyNoopTransition},
+		},
+		{
+			name:    "discontinuous",
+			entries: []Entry{transition("order", "new", "paid", 1), transition("order", "new", "cancelled", 2)},
+			want:    []AnomalyKind{AnomalyDiscontinuousTransition},
+		},
+		{
+			name:    "entities are tracked separately",
+			entries: []Entry{transition("order", "new", "paid", 1), transition("user", "guest", "member", 2), transition("order", "paid", "shipped", 3)},
+		},
+		{
+			name: "ordered by time, not by log order",
+			entries: []Entry{
+				at(transition("order", "paid", "shipped", 1), 2),
+				at(transition("order", "new", "paid", 2), 1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []AnomalyKind
+			for _, a := range requestAnomalies(tt.entries) {
+				got = append(got, a.Kind)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("anomalies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRareTransitions(t *testing.T) {
+	var entries []Entry
+	for i := range 199 {
+		entries = append(entries, transition("order", "new", "paid", i))
+	}
+	entries = append(entries, transition("order", "paid", "new", 199))
+	for i := range 10 {
+		entries = append(entries, transition("user", "guest", "banned", 200+i))
+	}
+	entries = append(entries, transition("user", "guest", "member", 210))
+
+	got := rareTransitions(entries, Options{}.withDefaults())
+	if len(got) != 1 {
+		t.Fatalf("rareTransitions = %+v, want one anomaly", got)
+	}
+	if a := got[0]; a.Kind != AnomalyRareTransition || a.Entity != "order" || a.From != "paid" || a.To != "new" || a.Count != 1 || a.Line != 199 {
+		t.Errorf("anomaly = %+v", a)
+	}
+
+	// Lowering the threshold lets the entity with few transitions be judged as well.
+	got = rareTransitions(entries, Options{MinTransitions: 5, RareTransitionRatio: 0.1}.withDefaults())
+	if len(got) != 2 || got[1].Entity != "user" || got[1].To != "member" {
+		t.Errorf("rareTransitions with a lower threshold = %+v", got)
+	}
+}
+
+func TestSortedEntries(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry := func(source string, line, sec int) Entry {
+		e := Entry{Source: source, Line: line}
+		if sec >= 0 {
+			e.Time = base.Add(time.Duration(sec) * time.Second)
+		}
+		return e
+	}
+	entries := []Entry{
+		entry("b.log", 2, -1),
+		entry("a.log", 1, 3),
+		entry("a.log", 9, -1),
+		entry("b.log", 1, 1),
+		entry("a.log", 2, -1),
+		entry("b.log", 3, 1),
+		entry("a.log", 3, 2),
+	}
+	want := []string{"b.log:1", "b.log:3", "a.log:3", "a.log:1", "a.log:2", "a.log:9", "b.log:2"}
+
+	var got []string
+	for _, e := range sortedEntries(entries) {
+		got = append(got, fmt.Sprintf("%s:%d", e.Source, e.Line))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("sortedEntries = %v, want %v", got, want)
+	}
+}
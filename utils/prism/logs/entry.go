@@ -0,0 +1,257 @@
+// Package logs reads Prism logs back and derives per-request timelines, span latencies and
+// anomalies from them.
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of a Prism log entry.
+type Kind string
+
+const (
+	KindSpan       Kind = "span"
+	KindTransition Kind = "transition"
+	KindSnapshot   Kind = "snapshot"
+)
+
+// Entry is a single Prism log line. Lines written by slog ("msg", upper-case levels),
+// zerolog ("message", lower-case levels) and similar JSON loggers are all read into it.
+type Entry struct {
+	Time      time.Time `json:"time,omitzero"`
+	Level     string    `json:"level,omitempty"`
+	Kind      Kind      `json:"kind"`
+	Service   string    `json:"service,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+
+	// Span fields.
+	Status     string  `json:"status,omitempty"`
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	Error      string  `json:"error,omitempty"`
+
+	// State fields.
+	Entity string `json:"entity,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Reason string `json:"reason,omitempty"`
+
+	// Source and Line locate the entry in its log file.
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+}
+
+// Failed reports whether the entry is a failed span.
+func (e Entry) Failed() bool {
+	return e.Kind == KindSpan && e.Status == "failed"
+}
+
+// Stats counts the lines read from log files.
+type Stats struct {
+	Lines int `json:"lines"`
+	// Entries counts the Prism entries.
+	Entries int `json:"entries"`
+	// Ignored counts JSON lines that are not Prism logs.
+	Ignored int `json:"ignored"`
+	// Invalid counts lines that are not JSON objects.
+	Invalid int `json:"invalid"`
+}
+
+func (s *Stats) add(o Stats) {
+	s.Lines += o.Lines
+	s.Entries += o.Entries
+	s.Ignored += o.Ignored
+	s.Invalid += o.Invalid
+}
+
+// maxLineSize bounds a single log line; longer lines are truncated and counted as invalid.
+const maxLineSize = 4 << 20
+
+// Read reads the Prism entries of a JSONL stream. source names the stream in the entries.
+// Blank lines are not counted.
+func Read(r io.Reader, source string) ([]Entry, Stats, error) {
+	var entries []Entry
+	var stats Stats
+
+	reader := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := readLine(reader)
+		if len(bytes.TrimSpace(line)) > 0 {
+			stats.Lines++
+			entry, ok, valid := Parse(line)
+			switch {
+			case !valid:
+				stats.Invalid++
+			case !ok:
+				stats.Ignored++
+			default:
+				entry.Source = source
+				entry.Line = n
+				entries = append(entries, entry)
+				stats.Entries++
+			}
+		}
+		if err == io.EOF {
+			return entries, stats, nil
+		}
+		if err != nil {
+			return nil, stats, fmt.Errorf("failed to read %s: %w", source, err)
+		}
+	}
+}
+
+// readLine reads a line without its line ending.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if len(line) < maxLineSize {
+			line = append(line, chunk...)
+		}
+		if err != nil || !isPrefix {
+			return line, err
+		}
+	}
+}
+
+// ReadFile reads the Prism entries of a JSONL log file.
+func ReadFile(path string) ([]Entry, Stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, Stats{}, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	return Read(f, path)
+}
+
+// ReadFiles reads the Prism entries of several log files in order.
+func ReadFiles(paths ...string) ([]Entry, Stats, error) {
+	var entries []Entry
+	var stats Stats
+	for _, path := range paths {
+		e, s, err := ReadFile(path)
+		if err != nil {
+			return nil, stats, err
+		}
+		entries = append(entries, e...)
+		stats.add(s)
+	}
+	return entries, stats, nil
+}
+
+// Parse parses a single log line. ok reports whether it is a Prism entry and valid whether
+// it is a JSON object at all.
+func Parse(line []byte) (entry Entry, ok, valid bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return Entry{}, false, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return Entry{}, false, false
+	}
+
+	entry = Entry{
+		Time:      parseTime(first(fields, "time", "timestamp", "ts", "@timestamp")),
+		Level:     strings.ToLower(str(first(fields, "level", "severity"))),
+		Service:   str(fields["service"]),
+		Scope:     str(fields["scope"]),
+		Method:    str(fields["method"]),
+		RequestID: str(fields["request_id"]),
+	}
+
+	switch typ := str(fields["type"]); {
+	case typ == string(KindTransition) || typ == string(KindSnapshot):
+		entry.Kind = Kind(typ)
+		entry.Entity = str(fields["entity"])
+		entry.From = str(fields["from"])
+		entry.To = str(fields["to"])
+		entry.Reason = str(fields["reason"])
+	case fields["status"] != nil && fields["duration_ms"] != nil:
+		duration, ok := number(fields["duration_ms"])
+		if !ok {
+			return Entry{}, false, true
+		}
+		entry.Kind = KindSpan
+		entry.Status = strings.ToLower(str(fields["status"]))
+		entry.DurationMs = duration
+		entry.Error = str(fields["error"])
+	default:
+		return Entry{}, false, true
+	}
+	return entry, true, true
+}
+
+func first(fields map[string]any, keys ...string) any {
+	for _, key := range keys {
+		if v, ok := fields[key]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func str(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parseTime accepts RFC 3339 strings and Unix timestamps in seconds, milliseconds or
+// microseconds.
+func parseTime(v any) time.Time {
+	switch v := v.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02T15:04:05.999999999"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return unixTime(f)
+		}
+	case float64:
+		return unixTime(v)
+	}
+	return time.Time{}
+}
+
+func unixTime(f float64) time.Time {
+	switch {
+	case f > 1e15:
+		return time.UnixMicro(int64(f))
+	case f > 1e12:
+		return time.UnixMilli(int64(f))
+	default:
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9))
+	}
+}
@@ -0,0 +1,92 @@
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		line      string
+		want      Entry
+		ok, valid bool
+	}{
+		{
+			name: "slog span",
+			line: `{"time":"2025-03-01T12:00:00Z","level":"ERROR","msg":"span","service":"api","scope":"db","method":"Get","request_id":"r1","status":"FAILED","duration_ms":12.5,"error":"boom"}`,
+			want: Entry{Time: at, Level: "error", Kind: KindSpan, Service: "api", Scope: "db", Method: "Get", RequestID: "r1", Status: "failed", DurationMs: 12.5, Error: "boom"},
+			ok:   true, valid: true,
+		},
+		{
+			name: "zerolog transition",
+			line: `{"level":"info","time":1740830400,"message":"transition","type":"transition","service":"api","entity":"order","from":"new","to":"paid","reason":"charged","request_id":"r1"}`,
+			want: Entry{Time: at, Level: "info", Kind: KindTransition, Service: "api", RequestID: "r1", Entity: "order", From: "new", To: "paid", Reason: "charged"},
+			ok:   true, valid: true,
+		},
+		{
+			name: "snapshot with severity",
+			line: `{"severity":"DEBUG","ts":"1740830400","type":"snapshot","entity":"order","to":"paid"}`,
+			want: Entry{Time: at, Level: "debug", Kind: KindSnapshot, Entity: "order", To: "paid"},
+			ok:   true, valid: true,
+		},
+		{
+			name: "duration as string",
+			line: `{"status":"ok","duration_ms":"3"}`,
+			want: Entry{Kind: KindSpan, Status: "ok", DurationMs: 3},
+			ok:   true, valid: true,
+		},
+		{name: "not a duration", line: `{"status":"ok","duration_ms":"fast"}`, valid: true},
+		{name: "other json log", line: `{"level":"info","msg":"started"}`, valid: true},
+		{name: "span without duration", line: `{"status":"ok"}`, valid: true},
+		{name: "plain text", line: `started server on :8080`},
+		{name: "broken json", line: `{"level":`},
+		{name: "json array", line: `[1,2]`},
+		{name: "blank", line: "   "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, valid := Parse([]byte(tt.line))
+			if ok != tt.ok || valid != tt.valid {
+				t.Fatalf("Parse ok=%v valid=%v, want ok=%v valid=%v", ok, valid, tt.ok, tt.valid)
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("Time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time, tt.want.Time = time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 250_000_000, time.UTC)
+	tests := []struct {
+		name string
+		v    any
+		want time.Time
+	}{
+		{"rfc3339 nano", "2025-03-01T12:00:00.25Z", at},
+		{"rfc3339 offset", "2025-03-01T21:00:00.25+09:00", at},
+		{"space separated", "2025-03-01 12:00:00.25Z", at},
+		{"no zone", "2025-03-01T12:00:00.25", at},
+		{"seconds", 1740830400.25, at},
+		{"milliseconds", 1740830400250.0, at},
+		{"microseconds", 1740830400250000.0, at},
+		{"seconds string", "1740830400.25", at},
+		{"milliseconds string", "1740830400250", at},
+		{"garbage", "yesterday", time.Time{}},
+		{"bool", true, time.Time{}},
+		{"missing", nil, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTime(tt.v); !got.Equal(tt.want) {
+				t.Errorf("parseTime(%v) = %v, want %v", tt.v, got, tt.want)
+			}
+		})
+	}
+}
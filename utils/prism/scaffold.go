@@ -0,0 +1,99 @@
+package prism
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Libraries lists the Go logging libraries the package can be generated for.
+var Libraries = []string{"slog", "zerolog"}
+
+// Marker starts every generated file, so later runs can recognize the package.
+const Marker = "// Prism logging package generated by useful-genkit"
+
+// Supports reports whether the package can be generated for library.
+func Supports(library string) bool {
+	return slices.Contains(Libraries, library)
+}
+
+// IsGenerated reports whether src is a file of a generated Prism package and returns the
+// library it was generated for.
+func IsGenerated(src []byte) (string, bool) {
+	line, _, _ := bytes.Cut(src, []byte("\n"))
+	rest, ok := strings.CutPrefix(string(line), Marker+" for ")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(rest, "."), true
+}
+
+type templateData struct {
+	Header     string
+	Package    string
+	Library    string
+	Dir        string
+	ImportPath string
+}
+
+// Render returns the files of the Prism package for library, named package pkg, by file name.
+func Render(library, pkg string) (map[string][]byte, error) {
+	if !Supports(library) {
+		return nil, fmt.Errorf("no Prism templates for %s", library)
+	}
+
+	data := templateData{
+		Header:  fmt.Sprintf("%s for %s.", Marker, library),
+		Package: pkg,
+		Library: library,
+	}
+
+	files := make(map[string][]byte)
+	for _, dir := range []string{"templates/common", "templates/" + library} {
+		entries, err := templates.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read templates: %w", err)
+		}
+		for _, e := range entries {
+			out, err := execute(path.Join(dir, e.Name()), data)
+			if err != nil {
+				return nil, err
+			}
+			src, err := format.Source(out)
+			if err != nil {
+				return nil, fmt.Errorf("failed to format %s: %w", e.Name(), err)
+			}
+			files[strings.TrimSuffix(e.Name(), ".tmpl")] = src
+		}
+	}
+	return files, nil
+}
+
+// API describes the generated package to a model: where it is, how to import it and what to call.
+func API(library, dir, importPath string) (string, error) {
+	out, err := execute("templates/api.md.tmpl", templateData{Library: library, Dir: dir, ImportPath: importPath})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func execute(name string, data templateData) ([]byte, error) {
+	tmpl, err := template.ParseFS(templates, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
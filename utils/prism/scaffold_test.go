@@ -0,0 +1,69 @@
+package prism
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderBuilds(t *testing.T) {
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found on PATH")
+	}
+
+	for _, lib := range Libraries {
+		t.Run(lib, func(t *testing.T) {
+			files, err := Render(lib, "log")
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			dir := t.TempDir()
+			pkgDir := filepath.Join(dir, "utils", "log")
+			if err := os.MkdirAll(pkgDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/prismtest\n\ngo 1.22\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for name, src := range files {
+				got, ok := IsGenerated(src)
+				if !ok || got != lib {
+					t.Errorf("IsGenerated(%s) = %q, %v; want %q, true", name, got, ok, lib)
+				}
+				if err := os.WriteFile(filepath.Join(pkgDir, name), src, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			run := func(args ...string) ([]byte, error) {
+				cmd := exec.Command(gobin, args...)
+				cmd.Dir = dir
+				return cmd.CombinedOutput()
+			}
+			if out, err := run("mod", "tidy"); err != nil {
+				t.Skipf("cannot resolve dependencies of the %s package: %v\n%s", lib, err, out)
+			}
+			if out, err := run("build", "./..."); err != nil {
+				t.Fatalf("go build: %v\n%s", err, out)
+			}
+			if out, err := run("vet", "./..."); err != nil {
+				t.Fatalf("go vet: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	if _, ok := IsGenerated([]byte("package log\n")); ok {
+		t.Error("IsGenerated accepted a file without the marker")
+	}
+}
+
+func TestRenderUnsupported(t *testing.T) {
+	if _, err := Render("logrus", "log"); err == nil {
+		t.Error("Render accepted an unsupported library")
+	}
+}
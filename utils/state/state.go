@@ -0,0 +1,57 @@
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DirEnv overrides the directory where run state (checkpoints, journals) is kept.
+const DirEnv = "USEFUL_GENKIT_STATE_DIR"
+
+// Dir returns the root directory for persisted run state.
+func Dir() (string, error) {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cache, "useful-genkit"), nil
+}
+
+// NewRunID returns a new sortable run identifier.
+func NewRunID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b[:])
+}
+
+// ValidateRunID rejects run IDs that cannot be used as a single path element.
+func ValidateRunID(runID string) error {
+	if runID == "" || runID == "." || runID == ".." || strings.ContainsAny(runID, `/\`) {
+		return fmt.Errorf("invalid run id %q", runID)
+	}
+	return nil
+}
+
+// RunDir returns the state directory of the given run, creating it if needed.
+func RunDir(runID string) (string, error) {
+	if err := ValidateRunID(runID); err != nil {
+		return "", err
+	}
+	root, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "runs", runID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create run directory: %w", err)
+	}
+	return dir, nil
+}
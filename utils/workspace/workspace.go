@@ -0,0 +1,233 @@
+// Package workspace confines file access to a root directory. A workspace is carried in
+// the context of a flow and enforced by every file tool.
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrOutside is returned for paths outside the workspace root, paths resolving outside
+	// it through a symbolic link and paths containing "..".
+	ErrOutside = errors.New("path is outside the workspace")
+	// ErrReadOnly is returned for mutations of a read-only workspace.
+	ErrReadOnly = errors.New("workspace is read-only")
+	// ErrDenied is returned for paths matched by a deny rule.
+	ErrDenied = errors.New("path is denied in the workspace")
+	// ErrRoot is returned for removing the workspace root itself.
+	ErrRoot = errors.New("cannot remove the workspace root")
+)
+
+// Options are the optional rules of a workspace.
+type Options struct {
+	// ReadOnly rejects every mutation.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// Deny lists glob patterns of paths that can be neither read nor written. A pattern with
+	// a slash is matched against the path relative to the root and its parent directories,
+	// like "build/*.key"; a pattern without one against each path element, like ".git" or "*.pem".
+	Deny []string `json:"deny,omitempty"`
+}
+
+// Workspace is a root directory that file access is confined to.
+type Workspace struct {
+	// Root is the absolute root directory.
+	Root string
+	Options
+
+	// real is Root with symbolic links resolved.
+	real string
+}
+
+// New creates a workspace rooted at root, which must be an existing directory.
+func New(root string, opts Options) (*Workspace, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace root %s: %w", root, err)
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace root %s: %w", root, err)
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat workspace root %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("workspace root %s is not a directory", root)
+	}
+	for _, pattern := range opts.Deny {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid deny pattern %q: %w", pattern, err)
+		}
+	}
+	return &Workspace{Root: abs, Options: opts, real: real}, nil
+}
+
+// Read checks that p may be read and returns it as an absolute path. Relative paths are
+// relative to the root.
+func (w *Workspace) Read(p string) (string, error) {
+	return w.resolve(p)
+}
+
+// Write checks that p may be created or changed and returns it as an absolute path.
+func (w *Workspace) Write(p string) (string, error) {
+	if w.ReadOnly {
+		return "", fmt.Errorf("%s: %w", p, ErrReadOnly)
+	}
+	return w.resolve(p)
+}
+
+// Remove checks that p may be removed and returns it as an absolute path. The root itself
+// cannot be removed.
+func (w *Workspace) Remove(p string) (string, error) {
+	abs, err := w.Write(p)
+	if err != nil {
+		return "", err
+	}
+	real, err := resolveExisting(abs)
+	if err != nil {
+		return "", err
+	}
+	if real == w.real {
+		return "", fmt.Errorf("%s: %w", p, ErrRoot)
+	}
+	return abs, nil
+}
+
+func (w *Workspace) resolve(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("empty path: %w", ErrOutside)
+	}
+	// ".." is resolved lexically by filepath.Clean but after symbolic links by the file
+	// system, so "dir/link/../x" could name a file outside the workspace.
+	if slices.Contains(strings.Split(filepath.ToSlash(p), "/"), "..") {
+		return "", fmt.Errorf("%s: must not contain \"..\": %w", p, ErrOutside)
+	}
+	abs := p
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(w.Root, abs)
+	}
+	abs = filepath.Clean(abs)
+
+	rel, ok := within(w.Root, abs)
+	if !ok {
+		if rel, ok = within(w.real, abs); !ok {
+			return "", fmt.Errorf("%s: %w", p, ErrOutside)
+		}
+	}
+
+	real, err := resolveExisting(abs)
+	if err != nil {
+		return "", err
+	}
+	realRel, ok := within(w.real, real)
+	if !ok {
+		return "", fmt.Errorf("%s resolves to %s: %w", p, real, ErrOutside)
+	}
+
+	if w.denied(rel) || w.denied(realRel) {
+		return "", fmt.Errorf("%s: %w", p, ErrDenied)
+	}
+	return abs, nil
+}
+
+// denied reports whether the slash-separated relative path rel matches a deny rule.
+func (w *Workspace) denied(rel string) bool {
+	if rel == "." {
+		return false
+	}
+	elems := strings.Split(rel, "/")
+	for _, pattern := range w.Deny {
+		pattern = strings.Trim(pattern, "/")
+		if strings.Contains(pattern, "/") {
+			for i := range elems {
+				if ok, _ := path.Match(pattern, strings.Join(elems[:i+1], "/")); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for _, elem := range elems {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// within returns the slash-separated path of abs relative to root, if abs is inside root.
+func within(root, abs string) (string, bool) {
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// resolveExisting resolves the symbolic links of the longest existing prefix of abs, so
+// paths of files that are about to be created can be checked too.
+func resolveExisting(abs string) (string, error) {
+	existing := abs
+	var rest []string
+	for {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to resolve %s: %w", abs, err)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		rest = append([]string{filepath.Base(existing)}, rest...)
+		existing = parent
+	}
+}
+
+type contextKey struct{}
+
+// WithWorkspace returns a context whose file tools are confined to w.
+func WithWorkspace(ctx context.Context, w *Workspace) context.Context {
+	return context.WithValue(ctx, contextKey{}, w)
+}
+
+// FromContext returns the workspace carried by ctx, or nil.
+func FromContext(ctx context.Context) *Workspace {
+	w, _ := ctx.Value(contextKey{}).(*Workspace)
+	return w
+}
+
+// Read checks p against the workspace in ctx. Without one, p is returned unchanged.
+func Read(ctx context.Context, p string) (string, error) {
+	if w := FromContext(ctx); w != nil {
+		return w.Read(p)
+	}
+	return p, nil
+}
+
+// Write checks p against the workspace in ctx. Without one, p is returned unchanged.
+func Write(ctx context.Context, p string) (string, error) {
+	if w := FromContext(ctx); w != nil {
+		return w.Write(p)
+	}
+	return p, nil
+}
+
+// Remove checks p against the workspace in ctx. Without one, p is returned unchanged.
+func Remove(ctx context.Context, p string) (string, error) {
+	if w := FromContext(ctx); w != nil {
+		return w.Remove(p)
+	}
+	return p, nil
+}
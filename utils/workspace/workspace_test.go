@@ -0,0 +1,161 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTree creates a workspace root with a few files, a symbolic link escaping it and one
+// staying inside it.
+func newTree(t *testing.T) (root, outside string) {
+	t.Helper()
+	root, outside = t.TempDir(), t.TempDir()
+	for _, name := range []string{"main.go", "pkg/a/a.go", "pkg/a/secret.pem", "build/out.key", "pkg/build/out.key"} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "passwd"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "pkg", "a"), filepath.Join(root, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	return root, outside
+}
+
+func TestResolve(t *testing.T) {
+	root, outside := newTree(t)
+	w, err := New(root, Options{Deny: []string{"*.pem", "build/*.key", "pkg/*/gen", ".git"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{"relative file", "main.go", nil},
+		{"nested file", "pkg/a/a.go", nil},
+		{"absolute file", filepath.Join(root, "pkg", "a", "a.go"), nil},
+		{"root", ".", nil},
+		{"symlink inside", "alias/a.go", nil},
+		{"missing file", "pkg/a/new.go", nil},
+		{"missing parent", "gen/deep/new.go", nil},
+		{"empty", "", ErrOutside},
+		{"parent traversal", "../x", ErrOutside},
+		{"traversal back inside", "pkg/../main.go", ErrOutside},
+		{"traversal through symlink", "escape/../x", ErrOutside},
+		{"absolute outside", filepath.Join(outside, "passwd"), ErrOutside},
+		{"symlink escaping", "escape/passwd", ErrOutside},
+		{"symlink escaping to a missing file", "escape/missing/new.go", ErrOutside},
+		{"denied element", "pkg/a/secret.pem", ErrDenied},
+		{"denied element of a missing file", "deep/new.pem", ErrDenied},
+		{"denied directory", ".git/config", ErrDenied},
+		{"denied glob at the root", "build/out.key", ErrDenied},
+		{"denied glob of a missing file", "build/new.key", ErrDenied},
+		{"denied glob at nested depth", "pkg/a/gen/deep/x.go", ErrDenied},
+		{"glob anchored at the root", "pkg/build/out.key", nil},
+		{"denied through a symlink", "alias/secret.pem", ErrDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := w.Read(tt.path)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Read(%q) error = %v, want %v", tt.path, err, tt.want)
+			}
+			if err == nil && !filepath.IsAbs(got) {
+				t.Errorf("Read(%q) = %q, want an absolute path", tt.path, got)
+			}
+		})
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	root, _ := newTree(t)
+	w, err := New(root, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := w.Read("main.go"); err != nil {
+		t.Errorf("Read: %v", err)
+	}
+	if _, err := w.Write("main.go"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write error = %v, want ErrReadOnly", err)
+	}
+	if _, err := w.Write("gen/new.go"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write of a new file error = %v, want ErrReadOnly", err)
+	}
+	if _, err := w.Remove("main.go"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Remove error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	root, _ := newTree(t)
+	w, err := New(root, Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, p := range []string{".", root, root + "/"} {
+		if _, err := w.Remove(p); !errors.Is(err, ErrRoot) {
+			t.Errorf("Remove(%q) error = %v, want ErrRoot", p, err)
+		}
+	}
+	if _, err := w.Remove("pkg/a"); err != nil {
+		t.Errorf("Remove(pkg/a): %v", err)
+	}
+	if got, err := w.Write("gen/deep/new.go"); err != nil || got != filepath.Join(root, "gen", "deep", "new.go") {
+		t.Errorf("Write of a file whose parent does not exist = %q, %v", got, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	root, _ := newTree(t)
+	if _, err := New(filepath.Join(root, "main.go"), Options{}); err == nil {
+		t.Error("New accepted a file as the root")
+	}
+	if _, err := New(filepath.Join(root, "missing"), Options{}); err == nil {
+		t.Error("New accepted a missing root")
+	}
+	if _, err := New(root, Options{Deny: []string{"["}}); err == nil {
+		t.Error("New accepted an invalid deny pattern")
+	}
+}
+
+func TestContext(t *testing.T) {
+	root, _ := newTree(t)
+	ctx := context.Background()
+	if got, err := Read(ctx, "../x"); err != nil || got != "../x" {
+		t.Errorf("Read without a workspace = %q, %v", got, err)
+	}
+
+	w, err := New(root, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx = WithWorkspace(ctx, w)
+	if FromContext(ctx) != w {
+		t.Fatal("FromContext does not return the workspace")
+	}
+	if _, err := Read(ctx, "../x"); !errors.Is(err, ErrOutside) {
+		t.Errorf("Read error = %v, want ErrOutside", err)
+	}
+	if _, err := Write(ctx, "main.go"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write error = %v, want ErrReadOnly", err)
+	}
+	if _, err := Remove(ctx, "main.go"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Remove error = %v, want ErrReadOnly", err)
+	}
+}